@@ -0,0 +1,306 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/image"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+Measure what each transformation costs: for each one, compile the circuit with a policy that enables only
+that transformation, run the setup, prove an edit of a converted photo with it, and verify the edit.
+The check of every transformation stays in the circuit, a policy only forbids its flag, so the circuits
+of the rows are nearly the same: the cost of the transformation itself is the Apply column, the constraints
+of its Apply() counted by the profiler (see Profile()). The benchmarks of bench_test.go time the same steps
+with `go test -bench . ./src/bench`.
+
+The image size is the constant image.N, compiled into the circuit: to compare sizes, run the benchmark
+again with another image.N. Every Result records the size it was measured with. The hash of image digests
+and signatures is chosen per Case, see With_Hash() to compare MiMC and Poseidon2.
+*/
+
+// A transformation to measure, with the parameters of the measured edit and the hash of the circuit.
+type Case struct {
+	Transformation photoproof.Transformation
+	Params         photoproof.Transformation_Parameters
+	Hash_Function  image.Hash_Function
+	Apply          Gadget // The Apply() of the transformation in the circuit
+}
+
+// The transformations of the circuit, see photoproof.Transformation_Index().
+var Cases = []Case{
+	{Transformation: photoproof.Identity_Transformation{}, Params: photoproof.Identity_Tr_Params{}, Apply: Gadgets[0]},
+	{Transformation: photoproof.Brightness_Transformation{}, Params: photoproof.Brightness_Tr_Params{Delta: 20}, Apply: Gadgets[1]},
+}
+
+// The cases with each hash function in turn, e.g. With_Hash(Cases, image.MiMC, image.Poseidon2).
+func With_Hash(cases []Case, hash_functions ...image.Hash_Function) []Case {
+	hashed := []Case{}
+	for _, hash_function := range hash_functions {
+		for _, c := range cases {
+			c.Hash_Function = hash_function
+			hashed = append(hashed, c)
+		}
+	}
+	return hashed
+}
+
+// The cost of one transformation. Durations are in nanoseconds in JSON.
+type Result struct {
+	Transformation string              `json:"transformation"`
+	Hash_Function  image.Hash_Function `json:"hash"`
+	Image_Size     uint64              `json:"image_size"` // Pixels per side, image.N
+	Constraints    int                 `json:"constraints"`
+	Apply          int                 `json:"apply_constraints"` // Constraints of the transformation's Apply(), the rest is shared by every row
+	Public         int                 `json:"public"`            // Public variables of the witness, including the constant 1
+	Secret         int                 `json:"secret"`
+
+	Compile       time.Duration      `json:"compile_ns"`
+	Setup         time.Duration      `json:"setup_ns"`
+	Prove         time.Duration      `json:"prove_ns"` // Assigning, solving and proving, without compiling
+	Verify        time.Duration      `json:"verify_ns"`
+	Prove_Phases  photoproof.Timings `json:"prove_phases"`
+	Verify_Phases photoproof.Timings `json:"verify_phases"`
+
+	Proving_Key_Bytes   int64  `json:"proving_key_bytes"`
+	Verifying_Key_Bytes int64  `json:"verifying_key_bytes"`
+	Proof_Bytes         int64  `json:"proof_bytes"`
+	Peak_Heap_Bytes     uint64 `json:"peak_heap_bytes"` // Largest heap in use (live and unswept objects), from compiling to verifying
+}
+
+// Measure each case in turn, calling progress (if not nil) after each one.
+func Run(ctx context.Context, cases []Case, progress func(Result)) ([]Result, error) {
+	results := []Result{}
+	for _, c := range cases {
+		result, err := Measure(ctx, c)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+		if progress != nil {
+			progress(result)
+		}
+	}
+	return results, nil
+}
+
+// Measure one transformation. gnark's profiler is global: do not compile other circuits at the same time.
+func Measure(ctx context.Context, c Case) (Result, error) {
+	name := c.Transformation.GetName()
+	result := Result{Transformation: name, Hash_Function: c.Hash_Function, Image_Size: image.N}
+
+	circuit, err := case_circuit(c)
+	if err != nil {
+		return result, fmt.Errorf("[Measure()] %s: %w", name, err)
+	}
+
+	// The cost of the transformation itself, before the heap is measured
+	if result.Apply, err = apply_constraints(&circuit, c.Apply); err != nil {
+		return result, fmt.Errorf("[Measure()] %s: %w", name, err)
+	}
+
+	peak := start_peak_heap()
+	defer peak.stop()
+
+	// Compile
+	prover := case_prover(circuit)
+	start := time.Now()
+	if err := prover.Compile(); err != nil {
+		return result, fmt.Errorf("[Measure()] %s: %w", name, err)
+	}
+	result.Compile = time.Since(start)
+	result.Constraints = prover.Compiled.GetNbConstraints()
+	result.Public = prover.Compiled.GetNbPublicVariables()
+	result.Secret = prover.Compiled.GetNbSecretVariables()
+
+	// Setup
+	start = time.Now()
+	proving_key, verifying_key, err := groth16.Setup(prover.Compiled)
+	if err != nil {
+		return result, fmt.Errorf("[Measure()] %s: %w: %w", name, photoproof.ErrSetup, err)
+	}
+	result.Setup = time.Since(start)
+
+	if result.Proving_Key_Bytes, err = proving_key.WriteTo(io.Discard); err != nil {
+		return result, fmt.Errorf("[Measure()] %s: writing the proving key: %w", name, err)
+	}
+	if result.Verifying_Key_Bytes, err = verifying_key.WriteTo(io.Discard); err != nil {
+		return result, fmt.Errorf("[Measure()] %s: writing the verifying key: %w", name, err)
+	}
+
+	edit, err := new_fixture(circuit, prover, proving_key, verifying_key)
+	if err != nil {
+		return result, fmt.Errorf("[Measure()] %s: %w", name, err)
+	}
+
+	// Prove and verify the edit
+	z, proof, timings, err := edit.editor.Prove_Context(ctx, edit.prover, edit.z, c.Transformation, c.Params, edit.proof)
+	if err != nil {
+		return result, fmt.Errorf("[Measure()] %s: %w", name, err)
+	}
+	result.Prove_Phases, result.Prove = timings, timings.Total()
+
+	if result.Proof_Bytes, err = proof.PCD_Proof.WriteTo(io.Discard); err != nil {
+		return result, fmt.Errorf("[Measure()] %s: writing the proof: %w", name, err)
+	}
+
+	ok, checks, err := photoproof.User{}.Verify_Context(ctx, edit.verifier, z, proof) // Verifying needs no key
+	if !ok {
+		return result, fmt.Errorf("[Measure()] %s: the edit does not verify: %w", name, err)
+	}
+	result.Verify_Phases, result.Verify = checks, checks.Total()
+
+	result.Peak_Heap_Bytes = peak.stop()
+	return result, nil
+}
+
+// The circuit of a case: a policy that enables only its transformation, with its hash.
+func case_circuit(c Case) (photoproof.Permissible_Transformations, error) {
+	name := c.Transformation.GetName()
+	policy := photoproof.Policy{Name: "bench " + name, Transformations: map[string]photoproof.Transformation_Policy{name: {}}, Hash_Function: c.Hash_Function}
+	circuit := photoproof.Permissible_Transformations{}
+	err := policy.Apply(&circuit)
+	return circuit, err
+}
+
+// The prover keys of circuit, without the proving key.
+func case_prover(circuit photoproof.Permissible_Transformations) photoproof.ProverKeys {
+	return photoproof.ProverKeys{Bounds: circuit.Bounds, Params: circuit.Params, Policy_ID: circuit.Policy_ID, Hash_Function: circuit.Hash_Function}
+}
+
+// Constraints of the gadget in the circuit, compiled once more with the profiler.
+func apply_constraints(circuit *photoproof.Permissible_Transformations, gadget Gadget) (int, error) {
+	dir, err := os.MkdirTemp("", "bench")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	report, err := Profile(circuit, filepath.Join(dir, "constraints.pprof"), []Gadget{gadget})
+	if err != nil {
+		return 0, err
+	}
+	return report.Gadgets[0].Total, nil
+}
+
+// Keys of a case, and a converted photo to edit with them.
+type fixture struct {
+	prover   photoproof.ProverKeys
+	verifier photoproof.VerifierKeys
+	editor   photoproof.User
+	z        image.Z
+	proof    photoproof.Proof
+}
+
+// Complete the compiled prover with the keys of a setup, and convert a photo, none of it measured.
+func new_fixture(circuit photoproof.Permissible_Transformations, prover photoproof.ProverKeys, proving_key groth16.ProvingKey, verifying_key groth16.VerifyingKey) (fixture, error) {
+	keys, verifier, admin, err := photoproof.Generator_From_Keys(&circuit, proving_key, verifying_key)
+	if err != nil {
+		return fixture{}, err
+	}
+	prover.ProvingKey, prover.Original_PublicKey = keys.ProvingKey, keys.Original_PublicKey
+
+	cam := camera.Camera{Admin: admin, Prover: prover, Verifier: verifier}
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		return fixture{}, err
+	}
+	editor := admin // The only authorised editor of the keys
+	z, proof, err := editor.Prove(prover, photo.Z, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, photo.Proof)
+	if err != nil {
+		return fixture{}, fmt.Errorf("converting the photo: %w", err)
+	}
+
+	return fixture{prover: prover, verifier: verifier, editor: editor, z: z, proof: proof}, nil
+}
+
+// Write the results as a table, one transformation and hash per row.
+func Write_Table(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "transformation\thash\tsize\tconstraints\tapply\tcompile\tsetup\tprove\tverify\tproving key\tverifying key\tproof\tpeak heap\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%dx%d\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			r.Transformation, r.Hash_Function, r.Image_Size, r.Image_Size, r.Constraints, r.Apply,
+			r.Compile.Round(time.Millisecond), r.Setup.Round(time.Millisecond), r.Prove.Round(time.Millisecond), r.Verify.Round(time.Millisecond),
+			size(uint64(r.Proving_Key_Bytes)), size(uint64(r.Verifying_Key_Bytes)), size(uint64(r.Proof_Bytes)), size(r.Peak_Heap_Bytes))
+	}
+	return tw.Flush()
+}
+
+// Write the results as indented JSON.
+func Write_JSON(w io.Writer, results []Result) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+func size(bytes uint64) string {
+	switch {
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(bytes)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
+
+// Samples the live heap until stopped, see start_peak_heap().
+type peak_heap struct {
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+	peak uint64
+}
+
+const heap_metric = "/memory/classes/heap/objects:bytes"
+
+// Collect the garbage of previous measures, then sample the live heap every few milliseconds.
+func start_peak_heap() *peak_heap {
+	runtime.GC()
+
+	peak := &peak_heap{done: make(chan struct{})}
+	sample := []metrics.Sample{{Name: heap_metric}}
+	read := func() {
+		metrics.Read(sample)
+		peak.peak = max(peak.peak, sample[0].Value.Uint64())
+	}
+
+	read()
+	peak.wg.Add(1)
+	go func() {
+		defer peak.wg.Done()
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-peak.done:
+				read()
+				return
+			case <-ticker.C:
+				read()
+			}
+		}
+	}()
+
+	return peak
+}
+
+// Stop sampling and return the peak. Safe to call more than once.
+func (peak *peak_heap) stop() uint64 {
+	peak.once.Do(func() { close(peak.done) })
+	peak.wg.Wait()
+	return peak.peak
+}
@@ -0,0 +1,145 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/logger"
+	"github.com/drakstik/Photognark_V3/src/image"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+Benchmarks of each transformation of Cases, with each hash function:
+
+	go test -run '^$' -bench . -benchtime 3x ./src/bench
+
+A setup takes over a minute, so each case is set up once and shared by BenchmarkProve and BenchmarkVerify.
+*/
+
+var benchmark_cases = With_Hash(Cases, image.MiMC, image.Poseidon2)
+
+func case_name(c Case) string {
+	return fmt.Sprintf("%s/%s", c.Transformation.GetName(), c.Hash_Function)
+}
+
+// The fixtures of the cases already set up, by case_name().
+var fixtures = map[string]fixture{}
+
+func case_fixture(b *testing.B, c Case) fixture {
+	b.Helper()
+	if edit, found := fixtures[case_name(c)]; found {
+		return edit
+	}
+
+	logger.Disable()
+	circuit, err := case_circuit(c)
+	if err != nil {
+		b.Fatal(err)
+	}
+	prover := case_prover(circuit)
+	if err := prover.Compile(); err != nil {
+		b.Fatal(err)
+	}
+	proving_key, verifying_key, err := groth16.Setup(prover.Compiled)
+	if err != nil {
+		b.Fatal(err)
+	}
+	edit, err := new_fixture(circuit, prover, proving_key, verifying_key)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	fixtures[case_name(c)] = edit
+	return edit
+}
+
+// Compiling the circuit of each case, which also reports the constraints of the circuit and of the transformation.
+func BenchmarkCompile(b *testing.B) {
+	logger.Disable()
+	for _, c := range benchmark_cases {
+		b.Run(case_name(c), func(b *testing.B) {
+			circuit, err := case_circuit(c)
+			if err != nil {
+				b.Fatal(err)
+			}
+			apply, err := apply_constraints(&circuit, c.Apply)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			var prover photoproof.ProverKeys
+			for b.Loop() {
+				prover = case_prover(circuit)
+				if err := prover.Compile(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(prover.Compiled.GetNbConstraints()), "constraints")
+			b.ReportMetric(float64(apply), "apply-constraints")
+		})
+	}
+}
+
+// Proving an edit with the transformation of each case.
+func BenchmarkProve(b *testing.B) {
+	for _, c := range benchmark_cases {
+		b.Run(case_name(c), func(b *testing.B) {
+			edit := case_fixture(b, c)
+			for b.Loop() {
+				if _, _, err := edit.editor.Prove(edit.prover, edit.z, c.Transformation, c.Params, edit.proof); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Verifying an edit with the transformation of each case.
+func BenchmarkVerify(b *testing.B) {
+	for _, c := range benchmark_cases {
+		b.Run(case_name(c), func(b *testing.B) {
+			edit := case_fixture(b, c)
+			z, proof, err := edit.editor.Prove(edit.prover, edit.z, c.Transformation, c.Params, edit.proof)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			viewer := photoproof.User{} // Verifying needs no key
+			for b.Loop() {
+				if ok, _, err := viewer.Verify_Context(context.Background(), edit.verifier, z, proof); !ok {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// The transformations cost different constraints, which the rows of Measure() report apart from the shared circuit.
+func TestApplyConstraints(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles the circuit of each transformation with the profiler")
+	}
+	logger.Disable()
+
+	counts := map[string]int{}
+	for _, c := range Cases {
+		circuit, err := case_circuit(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		apply, err := apply_constraints(&circuit, c.Apply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if apply == 0 {
+			t.Errorf("%s: no constraints in its Apply()", c.Transformation.GetName())
+		}
+		counts[c.Transformation.GetName()] = apply
+	}
+	if counts["identity"] == counts["brightness"] {
+		t.Errorf("identity and brightness cost the same: %v", counts)
+	}
+}
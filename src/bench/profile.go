@@ -0,0 +1,151 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/profile"
+	pprof_profile "github.com/google/pprof/profile"
+)
+
+/*
+Where the constraints of a circuit come from: compile it with gnark's profiler, which records the call stack
+of every constraint in a pprof file (see `go tool pprof -top <file>`), and add up the constraints of each Gadget.
+*/
+
+// A part of the circuit, found in the call stacks by the names of its functions.
+type Gadget struct {
+	Name      string
+	Functions []string // Function names as in the pprof file, a trailing "." matches a whole package
+}
+
+// The parts of Permissible_Transformations to report, outermost first.
+var Gadgets = []Gadget{
+	{Name: "Fr_Identity_Transformation.Apply", Functions: []string{"photoproof.Fr_Identity_Transformation.Apply"}},
+	{Name: "Fr_Brightness_Transformation.Apply", Functions: []string{"photoproof.Fr_Brightness_Transformation.Apply"}},
+	{Name: "Verify_Signature", Functions: []string{"photoproof.Verify_Signature"}},
+	{Name: "Fr_Image.Hash", Functions: []string{"image.Fr_Image.Hash"}},
+	{Name: "range checks", Functions: []string{"bits.", "cmp.", "rangecheck.", "r1cs.(*builder[T]).ToBinary", "r1cs.(*builder[T]).AssertIsLessOrEqual", "r1cs.(*builder[T]).MustBeLessOrEqCst"}},
+}
+
+// The constraints of one gadget, or of one check called by Define().
+type Gadget_Count struct {
+	Name  string
+	Total int // Constraints with the gadget anywhere in their stack
+	Self  int // Constraints whose innermost gadget it is, so that the Self of all gadgets and Other add up to the total
+}
+
+type Profile_Report struct {
+	Circuit     string
+	Constraints int
+	Gadgets     []Gadget_Count // In the order of Gadgets
+	Other       int            // Constraints of no gadget
+	Checks      []Gadget_Count // By function called by Define(), largest first. Self is not set
+}
+
+// Compile circuit with the profiler, write the pprof file to path and add up the constraints of gadgets.
+// gnark's profiler is global: do not compile other circuits at the same time.
+func Profile(circuit frontend.Circuit, path string, gadgets []Gadget) (Profile_Report, error) {
+	session := profile.Start(profile.WithPath(path))
+	compiled, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+	session.Stop()
+	if err != nil {
+		return Profile_Report{}, fmt.Errorf("[Profile()] compiling the circuit: %w", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return Profile_Report{}, fmt.Errorf("[Profile()] %w", err)
+	}
+	defer file.Close()
+
+	pprof, err := pprof_profile.Parse(file)
+	if err != nil {
+		return Profile_Report{}, fmt.Errorf("[Profile()] reading %s: %w", path, err)
+	}
+
+	report := Profile_Report{Constraints: compiled.GetNbConstraints(), Gadgets: make([]Gadget_Count, len(gadgets))}
+	if len(pprof.Mapping) > 0 {
+		report.Circuit = pprof.Mapping[0].File
+	}
+	for i, gadget := range gadgets {
+		report.Gadgets[i].Name = gadget.Name
+	}
+
+	checks := map[string]int{}
+	for _, sample := range pprof.Sample {
+		count := int(sample.Value[0])
+
+		// Locations go from the innermost call to Define()
+		functions := []string{}
+		for _, location := range sample.Location {
+			for _, line := range location.Line {
+				functions = append(functions, line.Function.Name)
+			}
+		}
+
+		innermost := -1
+		for i, gadget := range gadgets {
+			if slices.ContainsFunc(functions, gadget.matches) {
+				report.Gadgets[i].Total += count
+				innermost = i // Gadgets are listed outermost first
+			}
+		}
+		if innermost >= 0 {
+			report.Gadgets[innermost].Self += count
+		} else {
+			report.Other += count
+		}
+
+		if i := slices.IndexFunc(functions, func(function string) bool { return strings.HasSuffix(function, ".Define") }); i > 0 {
+			checks[functions[i-1]] += count
+		} else {
+			checks["Define"] += count
+		}
+	}
+
+	for name, total := range checks {
+		report.Checks = append(report.Checks, Gadget_Count{Name: name, Total: total})
+	}
+	slices.SortFunc(report.Checks, func(a, b Gadget_Count) int { return b.Total - a.Total })
+
+	return report, nil
+}
+
+func (gadget Gadget) matches(function string) bool {
+	for _, name := range gadget.Functions {
+		if function == name || (strings.HasSuffix(name, ".") && strings.HasPrefix(function, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write the report for humans: the gadgets, then the checks of Define().
+func (report Profile_Report) Write(w io.Writer) error {
+	percent := func(n int) string {
+		return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(max(report.Constraints, 1)))
+	}
+
+	fmt.Fprintf(w, "%s: %d constraints\n\n", report.Circuit, report.Constraints)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "gadget\ttotal\t\tself\t")
+	for _, gadget := range report.Gadgets {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\n", gadget.Name, gadget.Total, percent(gadget.Total), gadget.Self, percent(gadget.Self))
+	}
+	fmt.Fprintf(tw, "other\t\t\t%d\t%s\n", report.Other, percent(report.Other))
+	fmt.Fprintln(tw, "\t\t\t\t")
+	fmt.Fprintln(tw, "check of Define()\ttotal\t\t\t")
+	for _, check := range report.Checks {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t\t\n", check.Name, check.Total, percent(check.Total))
+	}
+	return tw.Flush()
+}
@@ -1,8 +1,14 @@
 package camera
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/consensys/gnark/backend/groth16"
 	"github.com/drakstik/Photognark_V3/src/image"
 	"github.com/drakstik/Photognark_V3/src/photoproof"
 )
@@ -12,6 +18,10 @@ type Photograph struct {
 	Proof        photoproof.Proof
 	ProverKeys   photoproof.ProverKeys
 	VerifierKeys photoproof.VerifierKeys
+	Metadata     *image.Metadata_Opening // Opening of Z.MetadataCommitment, keep it private
+	Disclosure   *image.Disclosure       // The metadata fields shown to viewers, see Disclose()
+	Predicate    *photoproof.Predicate   // The claim about the hidden capture location and time proven by Proof, if any
+	History      []History_Entry         // From the capture to this photo, see Verify_History()
 }
 
 type Camera struct {
@@ -19,47 +29,214 @@ type Camera struct {
 	Photographs []Photograph
 	Prover      photoproof.ProverKeys
 	Verifier    photoproof.VerifierKeys
+	Certificate *photoproof.Certificate // Certificate of the Admin key, issued by the manufacturer
+	Counter     uint64                  // Monotonic counter of the photos taken
+	Metadata    image.Metadata          // Capture metadata committed in the next photos, e.g. set the location before taking one
 }
 
-func NewCamera(circuit *photoproof.Permissible_Transformations) Camera {
-	prover, verifier, admin := photoproof.Generator(circuit)
+// Create a camera and its keys, see photoproof.Generator() for the options.
+func NewCamera(circuit *photoproof.Permissible_Transformations, options ...photoproof.Generator_Option) (Camera, error) {
+	prover, verifier, admin, err := photoproof.Generator(circuit, options...)
+	if err != nil {
+		return Camera{}, fmt.Errorf("[NewCamera()] %w", err)
+	}
+
 	return Camera{
 		Admin:       admin,
 		Photographs: []Photograph{},
 		Prover:      prover,
 		Verifier:    verifier,
+	}, nil
+}
+
+// Create a camera whose keys come from a multi-party ceremony on circuit, see package ceremony.
+func NewCamera_From_Keys(circuit *photoproof.Permissible_Transformations, provingKey groth16.ProvingKey, verifyingKey groth16.VerifyingKey, options ...photoproof.Generator_Option) (Camera, error) {
+	prover, verifier, admin, err := photoproof.Generator_From_Keys(circuit, provingKey, verifyingKey, options...)
+	if err != nil {
+		return Camera{}, fmt.Errorf("[NewCamera_From_Keys()] %w", err)
 	}
+
+	return Camera{
+		Admin:       admin,
+		Photographs: []Photograph{},
+		Prover:      prover,
+		Verifier:    verifier,
+	}, nil
 }
 
 func (cam *Camera) TakePhotograph(flag string) (Photograph, error) {
 	img, err := image.NewImage("random")
 	if err != nil {
-		fmt.Println("[TakePhotograph()] Error while creating a NewImage()")
-		return Photograph{}, err
+		return Photograph{}, fmt.Errorf("[TakePhotograph()] creating the image: %w", err)
 	}
+	img.Hash_Function = cam.Prover.Hash_Function // Hash and sign the image like the circuit of the keys
 
 	signature, err := cam.Admin.Sign(img)
 	if err != nil {
-		fmt.Println("[TakePhotograph()] Error while signing a new image")
-		return Photograph{}, err
+		return Photograph{}, fmt.Errorf("[TakePhotograph()] signing the image: %w", err)
+	}
+
+	// Commit to the capture metadata with fresh salts
+	opening, err := image.NewMetadata_Opening(cam.Metadata)
+	if err != nil {
+		return Photograph{}, fmt.Errorf("[TakePhotograph()] salting the metadata: %w", err)
+	}
+
+	metadata_commitment, err := opening.Commitment()
+	if err != nil {
+		return Photograph{}, fmt.Errorf("[TakePhotograph()] committing to the metadata: %w", err)
+	}
+
+	cam.Counter++
+	z := image.Z{
+		Img:          img,
+		PublicKey:    cam.Admin.PublicKey,
+		OriginalHash: img.Hash(),
+		Timestamp:    uint64(time.Now().Unix()),
+		Counter:      cam.Counter,
+
+		MetadataCommitment: metadata_commitment,
+	}
+
+	// Sign the original hash together with the capture time, counter and metadata
+	z.OriginalSignature, err = cam.Admin.Sign_Digest(z.Original_Digest(), img.Hash_Function)
+	if err != nil {
+		return Photograph{}, fmt.Errorf("[TakePhotograph()] signing the original digest: %w", err)
 	}
 
 	photo := Photograph{
-		Z: image.Z{
-			Img:               img,
-			PublicKey:         cam.Admin.PublicKey,
-			OriginalSignature: signature,
-			OriginalHash:      img.Hash(),
-		},
+		Z: z,
 		Proof: photoproof.Proof{
-			PCD_Proof: nil, // No PCD_Proof yet.
-			Signature: signature,
+			PCD_Proof:   nil, // No PCD_Proof yet.
+			Signature:   signature,
+			Certificate: cam.Certificate, // Kept by every edit, so that edited photos still link to the root key
 		},
 		ProverKeys:   cam.Prover,
 		VerifierKeys: cam.Verifier,
+		Metadata:     &opening,
+	}
+
+	if err := photo.record("capture", nil, cam.Admin.PublicKey); err != nil {
+		return Photograph{}, fmt.Errorf("[TakePhotograph()] recording the capture: %w", err)
 	}
 
 	cam.Photographs = append(cam.Photographs, photo)
+	photoproof.Logger().Debug("photograph taken", "counter", z.Counter)
+
+	return photo, nil
+}
+
+// Rotate the camera key: the manufacturer certifies a new Admin key, valid for validity.
+// Photos taken before the rotation keep the certificate of the old key, so they still verify.
+func (cam *Camera) Rotate(manufacturer *photoproof.Manufacturer, device_id string, validity time.Duration) error {
+	admin, err := photoproof.NewUser()
+	if err != nil {
+		return fmt.Errorf("[Rotate()] %w", err)
+	}
+
+	certificate, err := manufacturer.Issue(device_id, admin.PublicKey, validity)
+	if err != nil {
+		return fmt.Errorf("[Rotate()] issuing a certificate: %w", err)
+	}
+
+	cam.Admin = admin
+	cam.Certificate = &certificate
+	cam.Prover.Original_PublicKey = admin.PublicKey
+	cam.Verifier.Original_PublicKey = admin.PublicKey
+
+	// The new key edits like the old one, whose edits are still authorised.
+	if cam.Verifier.Editors != nil {
+		cam.Verifier.Editors.Add(photoproof.CameraKey{DeviceID: device_id, PublicKey: admin.PublicKey})
+	}
+
+	return nil
+}
+
+/*
+The identity of a camera is saved in a directory, so that the rotate command can replace it:
+
+	camera_key.json            The Admin key, in an encrypted keystore, see photoproof.User.Save()
+	certificate.json           The Certificate of the Admin key, if any
+	certificate-<serial>.json  The certificates of the previous keys, which verifiers of older photos need
+*/
+
+const (
+	Camera_Key_File  = "camera_key.json"
+	Certificate_File = "certificate.json"
+)
+
+// Write the Admin key and Certificate of the camera to dir, see Load_Identity(). The previous certificate in dir
+// is kept as certificate-<serial>.json. The previous key is replaced at once, never left half written.
+func (cam *Camera) Save_Identity(dir string, passphrase []byte) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("[Save_Identity()] %w", err)
+	}
+
+	key_path := filepath.Join(dir, Camera_Key_File)
+	if err := cam.Admin.Save(key_path+".tmp", passphrase); err != nil {
+		return fmt.Errorf("[Save_Identity()] %w", err)
+	}
+	if err := os.Rename(key_path+".tmp", key_path); err != nil {
+		return fmt.Errorf("[Save_Identity()] %w", err)
+	}
+
+	if cam.Certificate == nil {
+		return nil
+	}
+	certificate_path := filepath.Join(dir, Certificate_File)
+	previous, err := photoproof.LoadCertificate(certificate_path)
+	if err == nil && previous.Serial != cam.Certificate.Serial {
+		if err := os.Rename(certificate_path, filepath.Join(dir, fmt.Sprintf("certificate-%d.json", previous.Serial))); err != nil {
+			return fmt.Errorf("[Save_Identity()] keeping the previous certificate: %w", err)
+		}
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("[Save_Identity()] %s: %w", Certificate_File, err)
+	}
+	if err := cam.Certificate.Save(certificate_path); err != nil {
+		return fmt.Errorf("[Save_Identity()] %w", err)
+	}
+
+	return nil
+}
+
+// Read the Admin key and Certificate written by Save_Identity() into the camera.
+// The certificate, if any, must be of the Admin key.
+func (cam *Camera) Load_Identity(dir string, passphrase []byte) error {
+	admin, err := photoproof.LoadUser(filepath.Join(dir, Camera_Key_File), passphrase)
+	if err != nil {
+		return fmt.Errorf("[Load_Identity()] %w", err)
+	}
+
+	var certificate *photoproof.Certificate
+	loaded, err := photoproof.LoadCertificate(filepath.Join(dir, Certificate_File))
+	if err == nil {
+		if !bytes.Equal(loaded.PublicKey.Bytes(), admin.PublicKey.Bytes()) {
+			return fmt.Errorf("[Load_Identity()] %s is not the certificate of %s", Certificate_File, Camera_Key_File)
+		}
+		certificate = &loaded
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("[Load_Identity()] %s: %w", Certificate_File, err)
+	}
+
+	cam.Admin = admin
+	cam.Certificate = certificate
+	cam.Prover.Original_PublicKey = admin.PublicKey
+	cam.Verifier.Original_PublicKey = admin.PublicKey
+	return nil
+}
+
+// A copy of photo for publishing, which discloses only the named metadata fields (see image.Metadata_Fields).
+func (photo Photograph) Disclose(fields ...string) (Photograph, error) {
+	if photo.Metadata == nil {
+		return Photograph{}, errors.New("[Disclose()] photograph has no metadata opening")
+	}
+
+	disclosure, err := photo.Metadata.Disclose(fields...)
+	if err != nil {
+		return Photograph{}, err
+	}
 
-	return photo, err
+	photo.Metadata = nil
+	photo.Disclosure = &disclosure
+	return photo, nil
 }
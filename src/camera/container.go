@@ -0,0 +1,334 @@
+package camera
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	goimage "image"
+	"image/color"
+	"image/png"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/drakstik/Photognark_V3/src/image"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+A photo container carries what a viewer needs to verify a Photograph: its Z, Proof, and claims
+(Predicate, Disclosure), as JSON. It can be sent on its own, or embedded in a PNG of the image, in an
+iTXt chunk with keyword Container_Keyword. An embedded container has no pixels, the PNG pixels are verified.
+The verifier keys are not in the container, only the ID of the keys to verify it with, see package server.
+*/
+
+const Container_Keyword = "photognark"
+
+type container_file struct {
+	Key_ID              string                  `json:"key_id,omitempty"`
+	Hash                image.Hash_Function     `json:"hash,omitempty"`   // Of the image digest, MiMC if omitted
+	Pixels              string                  `json:"pixels,omitempty"` // Hex of the RGB bytes, row by row
+	Public_Key          string                  `json:"public_key"`       // Hex encoded, like every other []byte
+	Original_Signature  string                  `json:"original_signature"`
+	Original_Hash       string                  `json:"original_hash"`
+	Timestamp           uint64                  `json:"timestamp"`
+	Counter             uint64                  `json:"counter"`
+	Metadata_Commitment string                  `json:"metadata_commitment"`
+	Provenance          provenance_file         `json:"provenance"`
+	Editor              string                  `json:"editor,omitempty"`
+	Editors             string                  `json:"editors,omitempty"`
+	PCD_Proof           string                  `json:"pcd_proof,omitempty"` // Empty for an original photo
+	Signature           string                  `json:"signature"`
+	Certificate         *photoproof.Certificate `json:"certificate,omitempty"` // Of the camera key, see photoproof.Proof
+	Predicate           *photoproof.Predicate   `json:"predicate,omitempty"`
+	Disclosure          *image.Disclosure       `json:"disclosure,omitempty"`
+}
+
+type provenance_file struct {
+	Edits      uint64   `json:"edits"`
+	Uses       []uint64 `json:"uses"` // Indexed as in photoproof.Transformation_Index()
+	Brightness uint64   `json:"brightness"`
+}
+
+// The container of photo, to be verified with the keys of key_id.
+func (photo Photograph) Container(key_id string) ([]byte, error) {
+	file, err := photo.container_file(key_id)
+	if err != nil {
+		return nil, fmt.Errorf("[Container()] %w", err)
+	}
+
+	var pixels []byte
+	for _, px := range photo.Z.Img.Pxls {
+		pixels = append(pixels, px.RGB[:]...)
+	}
+	file.Pixels = hex.EncodeToString(pixels)
+
+	return json.Marshal(file)
+}
+
+// Read a container written by Photograph.Container(), and return its photo and key ID.
+// The photo has no prover or verifier keys.
+func Open_Container(data []byte) (Photograph, string, error) {
+	var file container_file
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Photograph{}, "", fmt.Errorf("[Open_Container()] %w", err)
+	}
+
+	pixels, err := hex.DecodeString(file.Pixels)
+	if err != nil || len(pixels) != int(image.N2)*3 {
+		return Photograph{}, "", fmt.Errorf("[Open_Container()] expected %d hex encoded pixels", image.N2)
+	}
+
+	var img image.Image
+	for i := range img.Pxls {
+		img.Pxls[i] = image.Pixel{
+			RGB: [3]uint8{pixels[3*i], pixels[3*i+1], pixels[3*i+2]},
+			Loc: image.PixelLocation{X: uint64(i) % image.N, Y: uint64(i) / image.N},
+		}
+	}
+
+	photo, err := file.photograph(img)
+	if err != nil {
+		return Photograph{}, "", fmt.Errorf("[Open_Container()] %w", err)
+	}
+	return photo, file.Key_ID, nil
+}
+
+func (photo Photograph) container_file(key_id string) (container_file, error) {
+	public_key_hex := func(public_key signature.PublicKey) string {
+		if public_key == nil {
+			return ""
+		}
+		return hex.EncodeToString(public_key.Bytes())
+	}
+
+	z := photo.Z
+	file := container_file{
+		Key_ID:              key_id,
+		Hash:                z.Img.Hash_Function,
+		Public_Key:          public_key_hex(z.PublicKey),
+		Original_Signature:  hex.EncodeToString(z.OriginalSignature),
+		Original_Hash:       hex.EncodeToString(z.OriginalHash),
+		Timestamp:           z.Timestamp,
+		Counter:             z.Counter,
+		Metadata_Commitment: hex.EncodeToString(z.MetadataCommitment),
+		Provenance: provenance_file{
+			Edits:      z.Provenance.Edits,
+			Uses:       z.Provenance.Uses[:],
+			Brightness: z.Provenance.Brightness,
+		},
+		Editor:      public_key_hex(z.Editor),
+		Editors:     hex.EncodeToString(z.Editors),
+		Signature:   hex.EncodeToString(photo.Proof.Signature),
+		Certificate: photo.Proof.Certificate,
+		Predicate:   photo.Predicate,
+		Disclosure:  photo.Disclosure,
+	}
+
+	if photo.Proof.PCD_Proof != nil {
+		var buf bytes.Buffer
+		if _, err := photo.Proof.PCD_Proof.WriteTo(&buf); err != nil {
+			return container_file{}, err
+		}
+		file.PCD_Proof = hex.EncodeToString(buf.Bytes())
+	}
+
+	return file, nil
+}
+
+// The photo of the container, with the image img.
+func (file container_file) photograph(img image.Image) (Photograph, error) {
+	var err error
+	decode := func(name string, s string) []byte {
+		b, decode_err := hex.DecodeString(s)
+		if decode_err != nil && err == nil {
+			err = fmt.Errorf("%s: %w", name, decode_err)
+		}
+		if len(b) == 0 {
+			return nil
+		}
+		return b
+	}
+	public_key := func(name string, s string) signature.PublicKey {
+		b := decode(name, s)
+		if b == nil || err != nil {
+			return nil
+		}
+		public_key, key_err := photoproof.PublicKey_From_Bytes(b)
+		if key_err != nil {
+			err = fmt.Errorf("%s: %w", name, key_err)
+		}
+		return public_key
+	}
+
+	if len(file.Provenance.Uses) > image.Provenance_Transformations {
+		return Photograph{}, fmt.Errorf("provenance counts %d transformations", len(file.Provenance.Uses))
+	}
+	provenance := image.Provenance{Edits: file.Provenance.Edits, Brightness: file.Provenance.Brightness}
+	copy(provenance.Uses[:], file.Provenance.Uses)
+
+	img.Hash_Function = file.Hash
+	photo := Photograph{
+		Z: image.Z{
+			Img:                img,
+			PublicKey:          public_key("public_key", file.Public_Key),
+			OriginalSignature:  decode("original_signature", file.Original_Signature),
+			OriginalHash:       decode("original_hash", file.Original_Hash),
+			Timestamp:          file.Timestamp,
+			Counter:            file.Counter,
+			MetadataCommitment: decode("metadata_commitment", file.Metadata_Commitment),
+			Provenance:         provenance,
+			Editor:             public_key("editor", file.Editor),
+			Editors:            decode("editors", file.Editors),
+		},
+		Proof:      photoproof.Proof{Signature: decode("signature", file.Signature), Certificate: file.Certificate},
+		Predicate:  file.Predicate,
+		Disclosure: file.Disclosure,
+	}
+	pcd_proof := decode("pcd_proof", file.PCD_Proof)
+	if err != nil {
+		return Photograph{}, err
+	}
+	if photo.Z.PublicKey == nil {
+		return Photograph{}, errors.New("container has no public key")
+	}
+
+	if pcd_proof != nil {
+		photo.Proof.PCD_Proof = groth16.NewProof(ecc.BN254)
+		if _, err := photo.Proof.PCD_Proof.ReadFrom(bytes.NewReader(pcd_proof)); err != nil {
+			return Photograph{}, fmt.Errorf("pcd_proof: %w", err)
+		}
+	}
+
+	return photo, nil
+}
+
+/*------------------------------------------ Container embedded in a PNG --------------------------------------*/
+
+// A PNG of the image of photo, with its container embedded.
+func (photo Photograph) Embed_PNG(key_id string) ([]byte, error) {
+	file, err := photo.container_file(key_id)
+	if err != nil {
+		return nil, fmt.Errorf("[Embed_PNG()] %w", err)
+	}
+	container, err := json.Marshal(file)
+	if err != nil {
+		return nil, err
+	}
+
+	rgba := goimage.NewNRGBA(goimage.Rect(0, 0, int(image.N), int(image.N)))
+	for _, px := range photo.Z.Img.Pxls {
+		rgba.SetNRGBA(int(px.Loc.X), int(px.Loc.Y), color.NRGBA{R: px.RGB[0], G: px.RGB[1], B: px.RGB[2], A: 255})
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, rgba); err != nil {
+		return nil, err
+	}
+
+	// iTXt: keyword, null, no compression, null language tag, null translated keyword, UTF-8 text
+	var itxt bytes.Buffer
+	itxt.WriteString(Container_Keyword)
+	itxt.Write([]byte{0, 0, 0, 0, 0})
+	itxt.Write(container)
+
+	// Insert the chunk before IEND, the last 12 bytes written by png.Encode().
+	encoded := buf.Bytes()
+	iend := len(encoded) - 12
+	var out bytes.Buffer
+	out.Write(encoded[:iend])
+	write_chunk(&out, "iTXt", itxt.Bytes())
+	out.Write(encoded[iend:])
+
+	return out.Bytes(), nil
+}
+
+// Read a PNG written by Photograph.Embed_PNG(), and return its photo and key ID.
+// The image of the photo is the pixels of the PNG, which must be an N x N image.
+func Extract_PNG(data []byte) (Photograph, string, error) {
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Photograph{}, "", fmt.Errorf("[Extract_PNG()] %w", err)
+	}
+	if decoded.Bounds() != goimage.Rect(0, 0, int(image.N), int(image.N)) {
+		return Photograph{}, "", fmt.Errorf("[Extract_PNG()] image is %s, expected %dx%d", decoded.Bounds().Size(), image.N, image.N)
+	}
+
+	var img image.Image
+	for y := 0; y < int(image.N); y++ {
+		for x := 0; x < int(image.N); x++ {
+			c := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+			loc := image.PixelLocation{X: uint64(x), Y: uint64(y)}
+			img.Pxls[loc.To_1D_Index()] = image.Pixel{RGB: [3]uint8{c.R, c.G, c.B}, Loc: loc}
+		}
+	}
+
+	container, err := find_container(data)
+	if err != nil {
+		return Photograph{}, "", fmt.Errorf("[Extract_PNG()] %w", err)
+	}
+
+	var file container_file
+	if err := json.Unmarshal(container, &file); err != nil {
+		return Photograph{}, "", fmt.Errorf("[Extract_PNG()] %w", err)
+	}
+
+	photo, err := file.photograph(img)
+	if err != nil {
+		return Photograph{}, "", fmt.Errorf("[Extract_PNG()] %w", err)
+	}
+	return photo, file.Key_ID, nil
+}
+
+func write_chunk(out *bytes.Buffer, chunk_type string, data []byte) {
+	binary.Write(out, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunk_type))
+	crc.Write(data)
+	out.WriteString(chunk_type)
+	out.Write(data)
+	binary.Write(out, binary.BigEndian, crc.Sum32())
+}
+
+// The text of the uncompressed iTXt chunk with keyword Container_Keyword.
+func find_container(data []byte) ([]byte, error) {
+	data = data[8:] // PNG signature, checked by png.Decode()
+	for len(data) >= 12 {
+		length := binary.BigEndian.Uint32(data[:4])
+		if uint64(length) > uint64(len(data)-12) {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		chunk_type, chunk := string(data[4:8]), data[8:8+length]
+		crc := binary.BigEndian.Uint32(data[8+length : 12+length])
+		data = data[12+length:]
+
+		if chunk_type != "iTXt" {
+			continue
+		}
+		if crc32.ChecksumIEEE(append([]byte(chunk_type), chunk...)) != crc {
+			return nil, errors.New("corrupted iTXt chunk")
+		}
+
+		prefix := append([]byte(Container_Keyword), 0)
+		if !bytes.HasPrefix(chunk, prefix) {
+			continue
+		}
+		chunk = chunk[len(prefix):]
+		if len(chunk) < 2 || chunk[0] != 0 {
+			return nil, errors.New("compressed container")
+		}
+
+		// Skip the compression method, language tag and translated keyword.
+		fields := bytes.SplitN(chunk[2:], []byte{0}, 3)
+		if len(fields) != 3 {
+			return nil, errors.New("malformed iTXt chunk")
+		}
+		return fields[2], nil
+	}
+
+	return nil, errors.New("PNG has no photognark container")
+}
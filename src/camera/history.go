@@ -0,0 +1,242 @@
+package camera
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/drakstik/Photognark_V3/src/image"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+// One step of the history of a Photograph, from its capture to its last edit.
+// Each entry is hash-chained to the previous one, see Verify_History().
+type History_Entry struct {
+	Transformation string                               // "capture" for the original photo
+	Parameters     photoproof.Transformation_Parameters // nil for the original photo
+	Predicate      *photoproof.Predicate                // The claim proven by this step, if any
+	Editor         signature.PublicKey                  // The key that signed this step
+	Z              image.Z                              // The photo after this step
+	Proof          photoproof.Proof
+	Timestamp      time.Time // When the step was recorded. Nobody signs it, so it is for display only.
+	Previous       []byte    // Hash of the previous entry, nil for the original photo
+	Hash           []byte
+}
+
+// Append a step to the history of photo, which already holds the Z and Proof of the step.
+func (photo *Photograph) record(transformation string, params photoproof.Transformation_Parameters, editor signature.PublicKey) error {
+	entry := History_Entry{
+		Transformation: transformation,
+		Parameters:     params,
+		Predicate:      photo.Predicate,
+		Editor:         editor,
+		Z:              photo.Z,
+		Proof:          photo.Proof,
+		Timestamp:      time.Now(),
+	}
+	if len(photo.History) > 0 {
+		entry.Previous = photo.History[len(photo.History)-1].Hash
+	}
+
+	hash, err := entry.digest()
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+
+	// Do not share the backing array with the photo that was edited.
+	photo.History = append(photo.History[:len(photo.History):len(photo.History)], entry)
+	return nil
+}
+
+// Record an edit in the history of photo, see editor.Edit().
+func (photo *Photograph) Record(tr photoproof.Transformation, params photoproof.Transformation_Parameters, editor signature.PublicKey) error {
+	return photo.record(tr.GetName(), params, editor)
+}
+
+// The hash of the entry, over every field except Hash.
+func (entry History_Entry) digest() ([]byte, error) {
+	h := sha256.New()
+	write := func(data []byte) {
+		binary.Write(h, binary.BigEndian, uint64(len(data)))
+		h.Write(data)
+	}
+
+	write(entry.Previous)
+	write([]byte(entry.Transformation))
+	if entry.Parameters != nil {
+		write([]byte(fmt.Sprintf("%s%+v", entry.Parameters.GetName(), entry.Parameters)))
+	} else {
+		write(nil)
+	}
+	if entry.Predicate != nil {
+		write([]byte(fmt.Sprintf("%+v", *entry.Predicate)))
+	} else {
+		write(nil)
+	}
+	if entry.Editor != nil {
+		write(entry.Editor.Bytes())
+	} else {
+		write(nil)
+	}
+
+	z_digest, err := z_digest(entry.Z)
+	if err != nil {
+		return nil, err
+	}
+	write(z_digest)
+
+	proof_bytes, err := proof_bytes(entry.Proof)
+	if err != nil {
+		return nil, err
+	}
+	write(proof_bytes)
+
+	binary.Write(h, binary.BigEndian, entry.Timestamp.UnixNano())
+	return h.Sum(nil), nil
+}
+
+// A digest of every field of z.
+func z_digest(z image.Z) ([]byte, error) {
+	h := sha256.New()
+	h.Write(z.Img.Hash())
+	if z.PublicKey != nil {
+		h.Write(z.PublicKey.Bytes())
+	}
+	h.Write(z.OriginalSignature)
+	h.Write(z.Original_Digest())
+	if err := binary.Write(h, binary.BigEndian, z.Provenance); err != nil {
+		return nil, err
+	}
+	if z.Editor != nil {
+		h.Write(z.Editor.Bytes())
+	}
+	h.Write(z.Editors)
+	return h.Sum(nil), nil
+}
+
+// The signature and the serialized PCD proof of proof.
+func proof_bytes(proof photoproof.Proof) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(proof.Signature)
+	if proof.PCD_Proof != nil {
+		if _, err := proof.PCD_Proof.WriteTo(&buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Check that the history of photo is an unbroken hash chain from its capture to photo itself, in which each edit
+// transforms the image of the previous entry, and whose editors are authorised by the VerifierKeys of photo now:
+// the Timestamp of an entry is not signed, so whoever holds the history could move it into the window of an editor.
+// If reverify is set, every intermediate photo is also verified by viewer against the VerifierKeys of photo.
+func Verify_History(viewer photoproof.User, photo Photograph, reverify bool) error {
+	if len(photo.History) == 0 {
+		return errors.New("[Verify_History()] photograph has no history")
+	}
+
+	if photo.History[0].Transformation != "capture" {
+		return errors.New("[Verify_History()] history does not start with a capture")
+	}
+
+	var previous []byte
+	for i, entry := range photo.History {
+		if !bytes.Equal(entry.Previous, previous) {
+			return fmt.Errorf("[Verify_History()] entry %d does not follow entry %d", i, i-1)
+		}
+
+		hash, err := entry.digest()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(entry.Hash, hash) {
+			return fmt.Errorf("[Verify_History()] entry %d was modified", i)
+		}
+
+		if !bytes.Equal(entry.Z.OriginalHash, photo.Z.OriginalHash) {
+			return fmt.Errorf("[Verify_History()] entry %d has another original", i)
+		}
+
+		// The editor chain of each edit extends the chain of the previous entry with the editor of the edit.
+		if i > 0 {
+			editors := photo.History[i-1].Z.Editors
+			if entry.Z.Editor != nil {
+				if editors, err = photoproof.Next_Editors(editors, entry.Z.Editor); err != nil {
+					return err
+				}
+			}
+			if !bytes.Equal(entry.Z.Editors, editors) {
+				return fmt.Errorf("[Verify_History()] entry %d does not record its editor", i)
+			}
+		}
+
+		// The image of each edit is the image of the previous entry, transformed as recorded: the proof of an edit
+		// only shows that its input was signed, not that it was the previous image.
+		if i > 0 {
+			tr := photoproof.Transformation_Named(entry.Transformation)
+			if tr == nil {
+				return fmt.Errorf("[Verify_History()] entry %d has an unknown transformation %q", i, entry.Transformation)
+			}
+			params := entry.Parameters
+			if params == nil {
+				return fmt.Errorf("[Verify_History()] entry %d has no parameters", i)
+			}
+			if !bytes.Equal(tr.Apply(photo.History[i-1].Z.Img, &params).Hash(), entry.Z.Img.Hash()) {
+				return fmt.Errorf("[Verify_History()] entry %d does not edit the image of entry %d", i, i-1)
+			}
+		}
+
+		// The editor of each edit signed it, and is still authorised.
+		if i > 0 {
+			if entry.Z.Editor != nil && (entry.Editor == nil || !bytes.Equal(entry.Editor.Bytes(), entry.Z.Editor.Bytes())) {
+				return fmt.Errorf("[Verify_History()] entry %d was not signed by its editor", i)
+			}
+			if err := photo.VerifierKeys.Check_Editor(entry.Z, time.Now()); err != nil {
+				return fmt.Errorf("[Verify_History()] entry %d: %w: %w", i, photoproof.ErrUntrustedKey, err)
+			}
+		}
+
+		if reverify {
+			var ok bool
+			if entry.Predicate != nil {
+				ok, err = viewer.Verify_Predicate(photo.VerifierKeys, entry.Z, entry.Proof, *entry.Predicate)
+			} else {
+				ok, err = viewer.Verify(photo.VerifierKeys, entry.Z, entry.Proof)
+			}
+			if !ok || err != nil {
+				return fmt.Errorf("[Verify_History()] entry %d (%s) does not verify: %v", i, entry.Transformation, err)
+			}
+		}
+
+		previous = entry.Hash
+	}
+
+	// The last entry is the photo itself.
+	last := photo.History[len(photo.History)-1]
+	z_last, err := z_digest(last.Z)
+	if err != nil {
+		return err
+	}
+	z_photo, err := z_digest(photo.Z)
+	if err != nil {
+		return err
+	}
+	proof_last, err := proof_bytes(last.Proof)
+	if err != nil {
+		return err
+	}
+	proof_photo, err := proof_bytes(photo.Proof)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(z_last, z_photo) || !bytes.Equal(proof_last, proof_photo) {
+		return errors.New("[Verify_History()] history does not end with the photograph")
+	}
+
+	return nil
+}
@@ -0,0 +1,282 @@
+package ceremony
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/groth16/bn254/mpcsetup"
+	cs "github.com/consensys/gnark/constraint/bn254"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+)
+
+/*
+Multi-party trusted setup of the Groth16 keys, so that no single party running
+the Generator can forge proofs. Every participant contributes randomness in turn,
+and the keys are secure as long as at least one participant destroyed theirs.
+
+All state lives in the transcript files of a ceremony directory:
+
+	phase1_0001.bin, phase1_0002.bin, ...   Powers of Tau contributions (circuit independent)
+	srs_commons.bin                         Sealed output of phase 1
+	phase2_0001.bin, phase2_0002.bin, ...   Contributions for the compliance predicate
+	proving_key.bin, verifying_key.bin      Final keys, after Finalize()
+
+Each participant only needs the ceremony directory, so participants can be separate
+processes (or newsrooms), as long as they take turns.
+*/
+
+const (
+	SRS_Commons_File   = "srs_commons.bin"
+	Proving_Key_File   = "proving_key.bin"
+	Verifying_Key_File = "verifying_key.bin"
+)
+
+type Ceremony struct {
+	Dir  string
+	r1cs *cs.R1CS // The compiled compliance predicate
+}
+
+// Compile the circuit (aka compliance_predicate) for a ceremony kept in dir.
+func New(dir string, circuit frontend.Circuit) (Ceremony, error) {
+	compliance_predicate, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+	if err != nil {
+		return Ceremony{}, fmt.Errorf("[ceremony.New()] error while compiling constraint system: %w", err)
+	}
+
+	r1cs, ok := compliance_predicate.(*cs.R1CS)
+	if !ok {
+		return Ceremony{}, errors.New("[ceremony.New()] constraint system is not a BN254 R1CS")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Ceremony{}, err
+	}
+
+	return Ceremony{Dir: dir, r1cs: r1cs}, nil
+}
+
+// The FFT domain size of the compliance predicate.
+func (c Ceremony) Domain_Size() uint64 {
+	return ecc.NextPowerOfTwo(uint64(c.r1cs.GetNbConstraints()))
+}
+
+/*------------------------------------------ Phase 1 (Powers of Tau) --------------------------------------*/
+
+// Add a phase 1 contribution on top of the latest one, and return its transcript file.
+func (c Ceremony) Contribute_Phase1() (string, error) {
+	n := c.count("phase1")
+
+	prev := mpcsetup.NewPhase1(c.Domain_Size())
+	if n > 0 {
+		if err := read(c.path("phase1", n), prev); err != nil {
+			return "", err
+		}
+	}
+
+	prev.Contribute()
+
+	path := c.path("phase1", n+1)
+	return path, write(path, prev)
+}
+
+// Verify all phase 1 contributions, and seal them with a public random beacon into srs_commons.bin.
+func (c Ceremony) Seal_Phase1(beacon []byte) error {
+	n := c.count("phase1")
+	if n == 0 {
+		return errors.New("[Seal_Phase1()] no phase 1 contributions")
+	}
+
+	contributions := make([]*mpcsetup.Phase1, n)
+	for i := range contributions {
+		contributions[i] = new(mpcsetup.Phase1)
+		if err := read(c.path("phase1", i+1), contributions[i]); err != nil {
+			return err
+		}
+	}
+
+	commons, err := mpcsetup.VerifyPhase1(c.Domain_Size(), beacon, contributions...)
+	if err != nil {
+		return fmt.Errorf("[Seal_Phase1()] invalid phase 1 contribution: %w", err)
+	}
+
+	return write(filepath.Join(c.Dir, SRS_Commons_File), &commons)
+}
+
+/*------------------------------------------ Phase 2 (Circuit specific) --------------------------------------*/
+
+func (c Ceremony) commons() (*mpcsetup.SrsCommons, error) {
+	var commons mpcsetup.SrsCommons
+	if err := read(filepath.Join(c.Dir, SRS_Commons_File), &commons); err != nil {
+		return nil, fmt.Errorf("phase 1 is not sealed: %w", err)
+	}
+	return &commons, nil
+}
+
+// Return the i-th phase 2 contribution. The 0-th is the initial state every verifier can recompute.
+func (c Ceremony) phase2(commons *mpcsetup.SrsCommons, i int) (*mpcsetup.Phase2, error) {
+	p := new(mpcsetup.Phase2)
+	if i == 0 {
+		p.Initialize(c.r1cs, commons)
+		return p, nil
+	}
+	return p, read(c.path("phase2", i), p)
+}
+
+// Add a phase 2 contribution on top of the latest one, and return its transcript file.
+func (c Ceremony) Contribute() (string, error) {
+	commons, err := c.commons()
+	if err != nil {
+		return "", err
+	}
+
+	n := c.count("phase2")
+	prev, err := c.phase2(commons, n)
+	if err != nil {
+		return "", err
+	}
+
+	prev.Contribute()
+
+	path := c.path("phase2", n+1)
+	return path, write(path, prev)
+}
+
+// Verify the i-th phase 2 contribution against the previous one.
+func (c Ceremony) Verify_Contribution(i int) error {
+	if i < 1 || i > c.count("phase2") {
+		return fmt.Errorf("[Verify_Contribution()] no phase 2 contribution %d", i)
+	}
+
+	commons, err := c.commons()
+	if err != nil {
+		return err
+	}
+
+	prev, err := c.phase2(commons, i-1)
+	if err != nil {
+		return err
+	}
+
+	next, err := c.phase2(commons, i)
+	if err != nil {
+		return err
+	}
+
+	if err := prev.Verify(next); err != nil {
+		return fmt.Errorf("[Verify_Contribution()] contribution %d is invalid: %w", i, err)
+	}
+
+	return nil
+}
+
+// Verify every phase 2 contribution, seal them with a public random beacon, and write the final keys.
+func (c Ceremony) Finalize(beacon []byte) (groth16.ProvingKey, groth16.VerifyingKey, error) {
+	commons, err := c.commons()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n := c.count("phase2")
+	if n == 0 {
+		return nil, nil, errors.New("[Finalize()] no phase 2 contributions")
+	}
+
+	contributions := make([]*mpcsetup.Phase2, n)
+	for i := range contributions {
+		if contributions[i], err = c.phase2(commons, i+1); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	provingKey, verifyingKey, err := mpcsetup.VerifyPhase2(c.r1cs, commons, beacon, contributions...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[Finalize()] invalid phase 2 contribution: %w", err)
+	}
+
+	if err := write(filepath.Join(c.Dir, Proving_Key_File), provingKey); err != nil {
+		return nil, nil, err
+	}
+	if err := write(filepath.Join(c.Dir, Verifying_Key_File), verifyingKey); err != nil {
+		return nil, nil, err
+	}
+
+	return provingKey, verifyingKey, nil
+}
+
+// Load the final keys written by Finalize().
+func Load_Keys(dir string) (groth16.ProvingKey, groth16.VerifyingKey, error) {
+	provingKey := groth16.NewProvingKey(ecc.BN254)
+	if err := read(filepath.Join(dir, Proving_Key_File), provingKey); err != nil {
+		return nil, nil, err
+	}
+
+	verifyingKey := groth16.NewVerifyingKey(ecc.BN254)
+	if err := read(filepath.Join(dir, Verifying_Key_File), verifyingKey); err != nil {
+		return nil, nil, err
+	}
+
+	return provingKey, verifyingKey, nil
+}
+
+/*------------------------------------------ Transcript files --------------------------------------*/
+
+func (c Ceremony) path(phase string, i int) string {
+	return filepath.Join(c.Dir, fmt.Sprintf("%s_%04d.bin", phase, i))
+}
+
+// Number of contributions in a phase; contributions are numbered from 1 without gaps.
+func (c Ceremony) count(phase string) int {
+	n := 0
+	for {
+		if _, err := os.Stat(c.path(phase, n+1)); err != nil {
+			return n
+		}
+		n++
+	}
+}
+
+// SHA256 of a transcript file, for participants to publish their contribution.
+func Hash_File(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func write(path string, v io.WriterTo) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := v.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func read(path string, v io.ReaderFrom) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = v.ReadFrom(f)
+	return err
+}
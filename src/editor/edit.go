@@ -1,7 +1,10 @@
 package editor
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/drakstik/Photognark_V3/src/camera"
 	"github.com/drakstik/Photognark_V3/src/photoproof"
@@ -12,16 +15,78 @@ type Editor struct {
 }
 
 func (editor Editor) Edit(photo camera.Photograph, tr photoproof.Transformation, params photoproof.Transformation_Parameters) (camera.Photograph, error) {
-	z_out, proof_out, err := editor.Editor.Prove(photo.ProverKeys, photo.Z, tr, params, photo.Proof)
+	return editor.edit(context.Background(), photo, photo.ProverKeys, tr, params)
+}
+
+// Edit photo, proving with prover instead of photo.ProverKeys, e.g. keys with a compiled circuit shared by a Pool.
+// The proof stops between its phases once ctx is done, see photoproof.User.Prove_Context().
+func (editor Editor) edit(ctx context.Context, photo camera.Photograph, prover photoproof.ProverKeys, tr photoproof.Transformation, params photoproof.Transformation_Parameters) (camera.Photograph, error) {
+	if err := check_input(photo); err != nil {
+		return camera.Photograph{}, fmt.Errorf("[Edit()] %w", err)
+	}
+
+	z_out, proof_out, _, err := editor.Editor.Prove_Context(ctx, prover, photo.Z, tr, params, photo.Proof)
+	if err != nil {
+		return camera.Photograph{}, fmt.Errorf("[Edit()] proving the %s edit: %w", tr.GetName(), err)
+	}
+
+	edited := camera.Photograph{
+		Z:            z_out,
+		Proof:        proof_out, // Keeps the certificate of the camera key, see photoproof.Proof
+		ProverKeys:   prover,
+		VerifierKeys: photo.VerifierKeys,
+		Metadata:     photo.Metadata, // The metadata commitment is unchanged by edits
+		Disclosure:   photo.Disclosure,
+		History:      photo.History,
+	}
+
+	if err := edited.Record(tr, params, editor.Editor.PublicKey); err != nil {
+		return camera.Photograph{}, fmt.Errorf("[Edit()] recording the edit: %w", err)
+	}
+
+	return edited, nil
+}
+
+// Prove that predicate holds for the hidden capture location of photo, with an identity transformation.
+// The capture time of photo stays public, so predicate cannot bound it, see photoproof.ErrPublicTime.
+// Only the holder of the metadata opening (the camera, or an editor it was shared with) can do this.
+func (editor Editor) Prove_Predicate(photo camera.Photograph, predicate photoproof.Predicate) (camera.Photograph, error) {
+	if photo.Metadata == nil {
+		return camera.Photograph{}, errors.New("[Prove_Predicate()] photograph has no metadata opening")
+	}
+
+	if err := check_input(photo); err != nil {
+		return camera.Photograph{}, fmt.Errorf("[Prove_Predicate()] %w", err)
+	}
+
+	z_out, proof_out, err := editor.Editor.Prove_Predicate(photo.ProverKeys, photo.Z, *photo.Metadata, predicate, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, photo.Proof)
 	if err != nil {
-		fmt.Println("[Edit()] Error while proving an edit")
-		return camera.Photograph{}, err
+		return camera.Photograph{}, fmt.Errorf("[Prove_Predicate()] proving the predicate: %w", err)
 	}
 
-	return camera.Photograph{
+	claimed := camera.Photograph{
 		Z:            z_out,
-		Proof:        proof_out,
+		Proof:        proof_out, // Likewise
 		ProverKeys:   photo.ProverKeys,
 		VerifierKeys: photo.VerifierKeys,
-	}, err
+		Metadata:     photo.Metadata,
+		Disclosure:   photo.Disclosure,
+		Predicate:    &predicate,
+		History:      photo.History,
+	}
+
+	if err := claimed.Record(photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, editor.Editor.PublicKey); err != nil {
+		return camera.Photograph{}, fmt.Errorf("[Prove_Predicate()] recording the predicate: %w", err)
+	}
+
+	return claimed, nil
+}
+
+// The circuit does not verify the proof of the photo it edits, only the signature of its last editor,
+// so only edit a photo whose last editor is authorised by its VerifierKeys, see photoproof.VerifierKeys.Check_Editor().
+func check_input(photo camera.Photograph) error {
+	if err := photo.VerifierKeys.Check_Editor(photo.Z, time.Now()); err != nil {
+		return fmt.Errorf("%w: %w", photoproof.ErrUntrustedKey, err)
+	}
+	return nil
 }
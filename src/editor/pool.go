@@ -0,0 +1,171 @@
+package editor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+// Heap used by one proof, per constraint of the circuit (measured on the main circuit, with some margin).
+const Bytes_Per_Constraint = 1536
+
+// An edit to prove, see Editor.Edit().
+type Job struct {
+	Photo          camera.Photograph
+	Transformation photoproof.Transformation
+	Parameters     photoproof.Transformation_Parameters
+}
+
+// The outcome of a Job: the edited photo, or the error that stopped it.
+// Jobs that were cancelled before they started have the error of the context.
+type Job_Result struct {
+	Photo    camera.Photograph
+	Err      error
+	Duration time.Duration
+}
+
+// Progress of a Pool, passed to Pool.Progress after each job.
+type Progress struct {
+	Job     int // Index of the job that just finished
+	Done    int // Jobs finished so far, including failed ones
+	Failed  int
+	Total   int
+	Elapsed time.Duration
+}
+
+/*
+A worker pool that proves many edits by the same editor, e.g. an entire shoot overnight.
+Every worker shares the proving key and the compiled circuit of the pool, which are only read while proving.
+A job only starts if its estimated memory fits in Memory_Limit next to the jobs already running.
+*/
+type Pool struct {
+	Editor       Editor
+	Prover       photoproof.ProverKeys // Shared by every job, with a compiled circuit
+	Workers      int                   // At most this many proofs at once
+	Memory_Limit uint64                // Bytes of heap for the running proofs, 0 for no limit
+	Job_Memory   uint64                // Estimated bytes of heap of one proof
+	Progress     func(Progress)        // Called after each job, one call at a time. Optional
+
+	mu      sync.Mutex
+	room    *sync.Cond
+	running uint64 // Memory reserved by the running jobs
+}
+
+// Create a pool proving with prover, compiling its circuit once for every worker.
+// workers <= 0 uses one worker per CPU.
+func NewPool(editor Editor, prover photoproof.ProverKeys, workers int) (*Pool, error) {
+	if prover.ProvingKey == nil {
+		return nil, errors.New("[NewPool()] prover has no proving key")
+	}
+
+	if prover.Compiled == nil {
+		if err := prover.Compile(); err != nil {
+			return nil, fmt.Errorf("[NewPool()] %w", err)
+		}
+	}
+
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	pool := &Pool{
+		Editor:     editor,
+		Prover:     prover,
+		Workers:    workers,
+		Job_Memory: uint64(prover.Compiled.GetNbConstraints()) * Bytes_Per_Constraint,
+	}
+	pool.room = sync.NewCond(&pool.mu)
+	return pool, nil
+}
+
+// Prove every job, returning a result per job in the same order.
+// Cancelling ctx stops the jobs that have not started yet, and the running proofs at the end of their current phase.
+func (pool *Pool) Run(ctx context.Context, jobs []Job) []Job_Result {
+	results := make([]Job_Result, len(jobs))
+	start := time.Now()
+
+	queue := make(chan int, len(jobs))
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+
+	progress := Progress{Total: len(jobs)}
+	var progress_mu sync.Mutex
+	report := func(i int) {
+		progress_mu.Lock()
+		defer progress_mu.Unlock()
+
+		progress.Job = i
+		progress.Done++
+		if results[i].Err != nil {
+			progress.Failed++
+		}
+		progress.Elapsed = time.Since(start)
+		if pool.Progress != nil {
+			pool.Progress(progress)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < pool.Workers && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				if err := pool.reserve(ctx); err != nil {
+					results[i] = Job_Result{Err: err}
+					report(i)
+					continue
+				}
+
+				job_start := time.Now()
+				photo, err := pool.Editor.edit(ctx, jobs[i].Photo, pool.Prover, jobs[i].Transformation, jobs[i].Parameters)
+				pool.release()
+
+				results[i] = Job_Result{Photo: photo, Err: err, Duration: time.Since(job_start)}
+				report(i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Wait until a proof fits in the memory limit and reserve its memory.
+// A proof always fits if nothing else is running, so that a low limit proves one photo at a time.
+func (pool *Pool) reserve(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		pool.room.Broadcast()
+	})
+	defer stop()
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if pool.Memory_Limit == 0 || pool.running == 0 || pool.running+pool.Job_Memory <= pool.Memory_Limit {
+			pool.running += pool.Job_Memory
+			return nil
+		}
+		pool.room.Wait()
+	}
+}
+
+func (pool *Pool) release() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.running -= pool.Job_Memory
+	pool.room.Broadcast()
+}
@@ -0,0 +1,123 @@
+package example
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/signature"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks the anonymous circuit: a photo of any camera of the fleet verifies
+against the Merkle root of the fleet's keys, without revealing which camera took it.
+The fleet can grow to 2^Keys_Depth cameras, a depth compiled into the circuit.
+*/
+func Test_Anonymous() bool {
+	const depth = 8
+
+	// A fleet of cameras sharing one key set, deeper than the default
+	fleet := []photoproof.User{new_user(), new_user()}
+	key_set := &photoproof.KeySet{PublicKeys: []signature.PublicKey{fleet[0].PublicKey, fleet[1].PublicKey}, Depth: depth}
+
+	// The key set must have the depth of the circuit.
+	if _, _, _, err := photoproof.Generator_Anonymous(&photoproof.Anonymous_Permissible_Transformations{}, key_set); err == nil {
+		fmt.Println("[Test_Anonymous] FAILED keys were generated for a key set deeper than the circuit")
+		return false
+	}
+
+	circuit := photoproof.Anonymous_Permissible_Transformations{Keys_Depth: depth}
+	prover, verifier, admin, err := photoproof.Generator_Anonymous(&circuit, key_set)
+	if err != nil {
+		fmt.Println("[Test_Anonymous] Error while generating the keys: " + err.Error())
+		return false
+	}
+	fleet = append(fleet, admin)
+
+	viewer := new_user()
+	passed := true
+
+	// Camera 1 takes a photo, and converts its signature to an anonymous proof.
+	cam := camera.Camera{Admin: fleet[1], Prover: prover, Verifier: verifier}
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Anonymous] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	z_out, proof_out, err := cam.Admin.Prove_Anonymous(prover, photo.Z, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, photo.Proof)
+	if err != nil {
+		fmt.Println("[Test_Anonymous] Error while proving an original photo: " + err.Error())
+		return false
+	}
+
+	// Then edits it, still anonymously.
+	z_out, proof_out, err = cam.Admin.Prove_Anonymous(prover, z_out, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, proof_out)
+	if err != nil {
+		fmt.Println("[Test_Anonymous] Error while proving an edit: " + err.Error())
+		return false
+	}
+
+	// Only the image, the original hash and the PCD proof are published.
+	published_z := z_out.Anonymous()
+	published_proof := photoproof.Proof{PCD_Proof: proof_out.PCD_Proof}
+
+	ok, err := viewer.Verify_Anonymous(verifier, published_z, published_proof)
+	if !ok || err != nil {
+		fmt.Printf("[Test_Anonymous] FAILED honest photo was rejected (err: %v)\n", err)
+		passed = false
+	}
+
+	// A flipped pixel must be rejected.
+	tampered_z := published_z
+	tampered_z.Img.Pxls[0].RGB[0] ^= 1
+	if ok, _ := viewer.Verify_Anonymous(verifier, tampered_z, published_proof); ok {
+		fmt.Println("[Test_Anonymous] FAILED tampered photo was accepted")
+		passed = false
+	}
+
+	// The proof must not verify against the root of another fleet.
+	other_root, _ := (&photoproof.KeySet{PublicKeys: []signature.PublicKey{new_user().PublicKey}, Depth: depth}).Root()
+	other_verifier := verifier
+	other_verifier.Keys_Root = other_root
+	if ok, _ := viewer.Verify_Anonymous(other_verifier, published_z, published_proof); ok {
+		fmt.Println("[Test_Anonymous] FAILED photo was accepted under another key set")
+		passed = false
+	}
+
+	// A camera outside the fleet cannot prove.
+	outsider := camera.Camera{Admin: new_user(), Prover: prover, Verifier: verifier}
+	outsider_photo, err := outsider.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Anonymous] Error while taking a photograph: " + err.Error())
+		return false
+	}
+	if _, _, err := outsider.Admin.Prove_Anonymous(prover, outsider_photo.Z, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, outsider_photo.Proof); err == nil {
+		fmt.Println("[Test_Anonymous] FAILED camera outside the fleet could prove")
+		passed = false
+	}
+
+	// A full key set refuses new cameras.
+	full := &photoproof.KeySet{Depth: 1}
+	for i := 0; i < 3; i++ {
+		if err := full.Add(new_user().PublicKey); err != nil {
+			if i < 2 || !errors.Is(err, photoproof.ErrKeySetFull) {
+				fmt.Printf("[Test_Anonymous] FAILED adding camera %d to a key set of depth 1 (err: %v)\n", i, err)
+				passed = false
+			}
+		} else if i == 2 {
+			fmt.Println("[Test_Anonymous] FAILED a third camera was added to a key set of depth 1")
+			passed = false
+		}
+	}
+
+	if passed {
+		fmt.Println("********Test_Anonymous was successful!********")
+	} else {
+		fmt.Println("********Test_Anonymous FAILED********")
+	}
+
+	return passed
+}
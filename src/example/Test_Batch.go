@@ -0,0 +1,112 @@
+package example
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/editor"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+	"github.com/drakstik/Photognark_V3/src/viewer"
+)
+
+/*
+This file checks that VerifyBatch agrees with User.Verify on a page of honest and tampered photographs,
+and compares their running times.
+*/
+func Test_Batch() bool {
+	circuit := photoproof.Permissible_Transformations{}
+	cam, err := camera.NewCamera(&circuit)
+	if err != nil {
+		fmt.Println("[Test_Batch] Error while creating the camera: " + err.Error())
+		return false
+	}
+	ed := editor.Editor{Editor: cam.Admin}
+
+	// Originals and edited photographs, and a few tampered ones.
+	var photos []camera.Photograph
+	for i := 0; i < 8; i++ {
+		photo, err := cam.TakePhotograph("random")
+		if err != nil {
+			fmt.Println("[Test_Batch] Error while taking a photograph: " + err.Error())
+			return false
+		}
+		if i%2 == 1 {
+			if photo, err = ed.Edit(photo, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}); err != nil {
+				fmt.Println("[Test_Batch] Error while proving a photograph: " + err.Error())
+				return false
+			}
+		}
+		photos = append(photos, photo)
+	}
+	photos[0].Z.Img.Pxls[0].RGB[0] ^= 1                           // Bad signature
+	photos[1].Z.Img.Pxls[0].RGB[0] ^= 1                           // Bad proof
+	photos[2].Z.PublicKey = new_user().PublicKey                  // Untrusted camera
+	photos[3].Z.OriginalSignature = photos[5].Z.OriginalSignature // Bad original signature
+
+	// A page of a hundred photographs.
+	var page []camera.Photograph
+	for len(page) < 100 {
+		page = append(page, photos...)
+	}
+	page = page[:100]
+
+	verifier_keys := cam.Verifier
+	user := new_user()
+
+	start := time.Now()
+	expected := make([]bool, len(page))
+	for i, photo := range page {
+		ok, err := user.Verify(verifier_keys, photo.Z, photo.Proof)
+		expected[i] = ok && err == nil
+	}
+	loop_time := time.Since(start)
+
+	start = time.Now()
+	results := viewer.Viewer{Viewer: user}.VerifyBatch(verifier_keys, page)
+	batch_time := time.Since(start)
+
+	passed := true
+	for i, result := range results {
+		if result.OK != expected[i] || (result.OK != (result.Err == nil)) {
+			fmt.Printf("[Test_Batch] FAILED photo %d: batch accepted=%t, Verify accepted=%t (%s: %v)\n", i, result.OK, expected[i], result.Stage, result.Err)
+			passed = false
+		}
+	}
+	for i := 0; i < len(photos); i++ {
+		fmt.Printf("[Test_Batch] photo %d: ok=%t stage=%q err=%v\n", i, results[i].OK, results[i].Stage, results[i].Err)
+	}
+
+	fmt.Printf("[Test_Batch] %d photographs: Verify loop %s, VerifyBatch %s\n", len(page), loop_time, batch_time)
+
+	// The same page without the tampered photographs.
+	var honest []camera.Photograph
+	for len(honest) < 100 {
+		honest = append(honest, photos[4:]...)
+	}
+
+	start = time.Now()
+	for _, photo := range honest {
+		user.Verify(verifier_keys, photo.Z, photo.Proof)
+	}
+	loop_time = time.Since(start)
+
+	start = time.Now()
+	for i, result := range (viewer.Viewer{Viewer: user}).VerifyBatch(verifier_keys, honest) {
+		if !result.OK {
+			fmt.Printf("[Test_Batch] FAILED honest photo %d (%s: %v)\n", i, result.Stage, result.Err)
+			passed = false
+		}
+	}
+	batch_time = time.Since(start)
+
+	fmt.Printf("[Test_Batch] %d honest photographs: Verify loop %s, VerifyBatch %s\n", len(honest), loop_time, batch_time)
+
+	if passed {
+		fmt.Println("********Test_Batch was successful!********")
+	} else {
+		fmt.Println("********Test_Batch FAILED********")
+	}
+
+	return passed
+}
@@ -0,0 +1,125 @@
+package example
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/ceremony"
+	"github.com/drakstik/Photognark_V3/src/editor"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file simulates a multi-party trusted setup of the Permissible_Transformations keys. Each participant
+is a separate process running the ceremony subcommands, which only shares the transcript files in dir with
+the others, and a separate verifier process checks every phase 2 contribution. The final keys must prove
+and verify photos like the keys of a local setup. On the real circuit this takes over an hour on one core.
+*/
+func Test_Ceremony(dir string, participants int) bool {
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Println("[Test_Ceremony] " + err.Error())
+		return false
+	}
+	run := func(args ...string) (string, error) {
+		output, err := exec.Command(executable, append([]string{"ceremony"}, args...)...).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("ceremony %s: %w: %s", strings.Join(args, " "), err, output)
+		}
+		// The last line is the result, the others are logs of the circuit compilation.
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		return lines[len(lines)-1], nil
+	}
+
+	// Phase 1: Powers of Tau
+	for i := 1; i <= participants; i++ {
+		if _, err := run("contribute-phase1", dir); err != nil {
+			fmt.Println("[Test_Ceremony] Error during phase 1 contribution: " + err.Error())
+			return false
+		}
+		fmt.Printf("[Test_Ceremony] participant %d contributed to phase 1\n", i)
+	}
+	if _, err := run("seal-phase1", dir, "phase 1 beacon"); err != nil {
+		fmt.Println("[Test_Ceremony] " + err.Error())
+		return false
+	}
+
+	// Phase 2: every participant contributes, and anyone can verify the contribution.
+	for i := 1; i <= participants; i++ {
+		output, err := run("contribute", dir)
+		if err != nil {
+			fmt.Println("[Test_Ceremony] Error during phase 2 contribution: " + err.Error())
+			return false
+		}
+		fmt.Printf("[Test_Ceremony] participant %d: %s\n", i, output)
+
+		if _, err := run("verify", dir, strconv.Itoa(i)); err != nil {
+			fmt.Println("[Test_Ceremony] " + err.Error())
+			return false
+		}
+	}
+
+	if _, err := run("finalize", dir, "phase 2 beacon"); err != nil {
+		fmt.Println("[Test_Ceremony] " + err.Error())
+		return false
+	}
+
+	// The ceremony keys must work like the keys of a local setup.
+	pk, vk, err := ceremony.Load_Keys(dir)
+	if err != nil {
+		fmt.Println("[Test_Ceremony] " + err.Error())
+		return false
+	}
+	// The circuit of the ceremony: with the policy of dir, if any, see the ceremony command.
+	var circuit photoproof.Permissible_Transformations
+	if policy, err := photoproof.LoadPolicy(filepath.Join(dir, "policy.json")); err == nil {
+		if err := policy.Apply(&circuit); err != nil {
+			fmt.Println("[Test_Ceremony] " + err.Error())
+			return false
+		}
+	}
+	cam, err := camera.NewCamera_From_Keys(&circuit, pk, vk)
+	if err != nil {
+		fmt.Println("[Test_Ceremony] " + err.Error())
+		return false
+	}
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Ceremony] Error while taking a photograph: " + err.Error())
+		return false
+	}
+	ed := editor.Editor{Editor: cam.Admin}
+	edited, err := ed.Edit(photo, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	if err != nil {
+		fmt.Println("[Test_Ceremony] Proving failed with the ceremony keys: " + err.Error())
+		return false
+	}
+	if ok, err := new_user().Verify(cam.Verifier, edited.Z, edited.Proof); !ok {
+		fmt.Printf("[Test_Ceremony] Verifying failed with the ceremony keys (err: %v)\n", err)
+		return false
+	}
+
+	// A participant who replays an earlier transcript instead of contributing must be caught.
+	replay, err := os.ReadFile(filepath.Join(dir, "phase2_0001.bin"))
+	if err != nil {
+		fmt.Println("[Test_Ceremony] " + err.Error())
+		return false
+	}
+	if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("phase2_%04d.bin", participants+1)), replay, 0o644); err != nil {
+		fmt.Println("[Test_Ceremony] " + err.Error())
+		return false
+	}
+	if _, err := run("verify", dir, strconv.Itoa(participants+1)); err == nil {
+		fmt.Println("[Test_Ceremony] A replayed contribution was accepted!")
+		return false
+	}
+
+	fmt.Println("********Test_Ceremony was successful!********")
+	return true
+}
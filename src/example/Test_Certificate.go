@@ -0,0 +1,133 @@
+package example
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks the certificate chain photo -> device key -> manufacturer root key, that rotating
+the camera key does not invalidate older photos, and that a revoked certificate invalidates its photos.
+Original photographs carry no PCD proof, so no setup is needed.
+*/
+func Test_Certificate() bool {
+	manufacturer := new_manufacturer()
+	verifier_keys := photoproof.VerifierKeys{Root_PublicKey: manufacturer.Root.PublicKey}
+	viewer := new_user()
+	passed := true
+
+	check := func(name string, photo camera.Photograph, certificate photoproof.Certificate, expect_ok bool) {
+		ok, err := viewer.Verify_Certified(verifier_keys, certificate, photo.Z, photo.Proof)
+		if (ok && err == nil) != expect_ok {
+			fmt.Printf("[Test_Certificate] FAILED %s: accepted=%t (err: %v)\n", name, ok && err == nil, err)
+			passed = false
+		} else {
+			fmt.Printf("[Test_Certificate] ok %s (err: %v)\n", name, err)
+		}
+	}
+
+	cam := camera.Camera{}
+	if err := cam.Rotate(manufacturer, "camera-1", 24*time.Hour); err != nil {
+		fmt.Println("[Test_Certificate] " + err.Error())
+		return false
+	}
+
+	old_photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Certificate] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	// Rotate, and take a photo with the new key.
+	if err := cam.Rotate(manufacturer, "camera-1", 24*time.Hour); err != nil {
+		fmt.Println("[Test_Certificate] " + err.Error())
+		return false
+	}
+
+	new_photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Certificate] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	check("photo before rotation", old_photo, *old_photo.Proof.Certificate, true)
+	check("photo after rotation", new_photo, *new_photo.Proof.Certificate, true)
+	check("old photo with new certificate", old_photo, *new_photo.Proof.Certificate, false)
+
+	// Verify() checks the certificate of the photo too.
+	if ok, err := viewer.Verify(verifier_keys, new_photo.Z, new_photo.Proof); !ok {
+		fmt.Printf("[Test_Certificate] FAILED Verify() rejected a certified photo (err: %v)\n", err)
+		passed = false
+	}
+
+	// The certificate travels with the photo in its container.
+	container, err := new_photo.Container("")
+	if err != nil {
+		fmt.Println("[Test_Certificate] " + err.Error())
+		return false
+	}
+	opened, _, err := camera.Open_Container(container)
+	if err != nil {
+		fmt.Println("[Test_Certificate] " + err.Error())
+		return false
+	}
+	if opened.Proof.Certificate == nil {
+		fmt.Println("[Test_Certificate] FAILED the container lost the certificate")
+		passed = false
+	} else {
+		check("photo from its container", opened, *opened.Proof.Certificate, true)
+	}
+
+	// A certificate from another manufacturer
+	other, err := new_manufacturer().Issue("camera-1", cam.Admin.PublicKey, 24*time.Hour)
+	if err != nil {
+		fmt.Println("[Test_Certificate] " + err.Error())
+		return false
+	}
+	check("certificate of another manufacturer", new_photo, other, false)
+
+	// An expired certificate
+	expired, err := manufacturer.Issue("camera-1", cam.Admin.PublicKey, -time.Hour)
+	if err != nil {
+		fmt.Println("[Test_Certificate] " + err.Error())
+		return false
+	}
+	check("expired certificate", new_photo, expired, false)
+	if _, err := viewer.Verify_Certified(verifier_keys, expired, new_photo.Z, new_photo.Proof); !errors.Is(err, photoproof.ErrExpiredCertificate) || !errors.Is(err, photoproof.ErrUntrustedKey) {
+		fmt.Printf("[Test_Certificate] FAILED an expired certificate is not an ErrExpiredCertificate and ErrUntrustedKey (err: %v)\n", err)
+		passed = false
+	}
+
+	// A certificate whose fields were changed after issuing
+	forged := *new_photo.Proof.Certificate
+	forged.NotAfter = forged.NotAfter.Add(365 * 24 * time.Hour)
+	check("forged certificate", new_photo, forged, false)
+
+	// Rotating away from a leaked key only helps once its certificate is revoked: photos signed by it, even
+	// backdated into its validity window, are rejected then.
+	revoking := verifier_keys
+	revoking.TrustStore = photoproof.NewTrustStore()
+	revoking.TrustStore.Revoke_Certificate(old_photo.Proof.Certificate.Serial)
+	if ok, err := viewer.Verify(revoking, old_photo.Z, old_photo.Proof); ok {
+		fmt.Println("[Test_Certificate] FAILED a photo of a revoked certificate was accepted")
+		passed = false
+	} else {
+		fmt.Printf("[Test_Certificate] ok revoked certificate (err: %v)\n", err)
+	}
+	if ok, err := viewer.Verify(revoking, new_photo.Z, new_photo.Proof); !ok {
+		fmt.Printf("[Test_Certificate] FAILED revoking the old certificate rejected the new one (err: %v)\n", err)
+		passed = false
+	}
+
+	if passed {
+		fmt.Println("********Test_Certificate was successful!********")
+	} else {
+		fmt.Println("********Test_Certificate FAILED********")
+	}
+
+	return passed
+}
@@ -0,0 +1,94 @@
+package example
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks that Prove_Context() and Verify_Context() report their phase timings,
+and stop between phases once their context is cancelled or past its deadline.
+*/
+func Test_Context() bool {
+	circuit := photoproof.Permissible_Transformations{}
+	cam, err := camera.NewCamera(&circuit)
+	if err != nil {
+		fmt.Println("[Test_Context] Error while creating the camera: " + err.Error())
+		return false
+	}
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Context] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	passed := true
+	fail := func(format string, args ...any) {
+		fmt.Printf("[Test_Context] FAILED "+format+"\n", args...)
+		passed = false
+	}
+
+	user := new_user()
+	identity, params := photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}
+
+	// A proof with time to finish reports each of its phases.
+	z_out, proof_out, timings, err := user.Prove_Context(context.Background(), cam.Prover, photo.Z, identity, params, photo.Proof)
+	if err != nil {
+		fail("proof: %v", err)
+		return false
+	}
+	fmt.Printf("[Test_Context] proof: %s (total %s)\n", timings, timings.Total().Round(time.Millisecond))
+	if len(timings) != 4 || timings[len(timings)-1].Phase != "prove" {
+		fail("proof phases: %s", timings)
+	}
+
+	ok, timings, err := user.Verify_Context(context.Background(), cam.Verifier, z_out, proof_out)
+	fmt.Printf("[Test_Context] verification: %s\n", timings)
+	if !ok || err != nil {
+		fail("verification: %v", err)
+	}
+	if len(timings) != 3 {
+		fail("verification phases: %s", timings)
+	}
+
+	// A cancelled context does not start any phase.
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, timings, err = user.Prove_Context(cancelled, cam.Prover, photo.Z, identity, params, photo.Proof)
+	if !errors.Is(err, context.Canceled) || len(timings) != 0 {
+		fail("cancelled proof: %v after %s", err, timings)
+	}
+	ok, timings, err = user.Verify_Context(cancelled, cam.Verifier, z_out, proof_out)
+	if ok || !errors.Is(err, context.Canceled) || len(timings) != 0 {
+		fail("cancelled verification: %v after %s", err, timings)
+	}
+
+	// A deadline in the middle of a proof stops it before the prove phase.
+	deadline, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, timings, err = user.Prove_Context(deadline, cam.Prover, photo.Z, identity, params, photo.Proof)
+	fmt.Printf("[Test_Context] proof past its deadline: %v after %s (%s)\n", err, time.Since(start).Round(time.Millisecond), timings)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		fail("proof past its deadline: %v", err)
+	}
+	for _, timing := range timings {
+		if timing.Phase == "prove" {
+			fail("proof past its deadline was proven")
+		}
+	}
+
+	if passed {
+		fmt.Println("********Test_Context was successful!********")
+	} else {
+		fmt.Println("********Test_Context FAILED********")
+	}
+
+	return passed
+}
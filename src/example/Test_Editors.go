@@ -0,0 +1,151 @@
+package example
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/editor"
+	"github.com/drakstik/Photognark_V3/src/image"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks a chain of edits by two editors with their own keys, a desk editor and a photo editor,
+against the set of editors authorised by the admin, and that an edit cannot start from an image that
+was not signed by the camera or by an authorised editor.
+*/
+func Test_Editors() bool {
+	circuit := photoproof.Permissible_Transformations{}
+	cam, err := camera.NewCamera(&circuit)
+	if err != nil {
+		fmt.Println("[Test_Editors] Error while creating the camera: " + err.Error())
+		return false
+	}
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Editors] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	desk := editor.Editor{Editor: new_user()}
+	photo_editor := editor.Editor{Editor: new_user()}
+	outsider := editor.Editor{Editor: new_user()}
+
+	// The admin authorises both editors. The photos of the camera share its Editors.
+	cam.Verifier.Editors.Add(photoproof.CameraKey{DeviceID: "desk", PublicKey: desk.Editor.PublicKey})
+	cam.Verifier.Editors.Add(photoproof.CameraKey{DeviceID: "photo", PublicKey: photo_editor.Editor.PublicKey})
+
+	// Anyone can convert the camera signature to a PCD proof, it is not an edit.
+	converted, err := outsider.Edit(photo, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	if err != nil {
+		fmt.Println("[Test_Editors] Error while proving an original photo: " + err.Error())
+		return false
+	}
+	brightened, err := desk.Edit(converted, photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: 10})
+	if err != nil {
+		fmt.Println("[Test_Editors] Error while proving the desk edit: " + err.Error())
+		return false
+	}
+	edited, err := photo_editor.Edit(brightened, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	if err != nil {
+		fmt.Println("[Test_Editors] Error while proving the photo edit: " + err.Error())
+		return false
+	}
+
+	passed := true
+	check := func(name string, err error, expect_ok bool) {
+		if (err == nil) != expect_ok {
+			fmt.Printf("[Test_Editors] FAILED %s: accepted=%t (err: %v)\n", name, err == nil, err)
+			passed = false
+		} else {
+			fmt.Printf("[Test_Editors] ok %s (err: %v)\n", name, err)
+		}
+	}
+	verify := func(photo camera.Photograph) error {
+		ok, err := new_user().Verify(photo.VerifierKeys, photo.Z, photo.Proof)
+		if err == nil && !ok {
+			err = fmt.Errorf("rejected")
+		}
+		return err
+	}
+
+	// The proof records both editors, in order.
+	editors, _ := photoproof.Next_Editors(nil, desk.Editor.PublicKey)
+	editors, _ = photoproof.Next_Editors(editors, photo_editor.Editor.PublicKey)
+	if !bytes.Equal(edited.Z.Editors, editors) {
+		fmt.Println("[Test_Editors] FAILED the proof does not record both editors")
+		passed = false
+	}
+
+	check("authorised editors", verify(edited), true)
+	check("authorised editors of every step", camera.Verify_History(new_user(), edited, true), true)
+
+	// Without a set of editors, no edit is authorised.
+	unconfigured := edited
+	unconfigured.VerifierKeys.Editors = nil
+	check("no authorised editors", verify(unconfigured), false)
+
+	only_desk := edited
+	only_desk.VerifierKeys.Editors = photoproof.NewTrustStore()
+	only_desk.VerifierKeys.Editors.Add(photoproof.CameraKey{DeviceID: "desk", PublicKey: desk.Editor.PublicKey})
+	check("unauthorised photo editor", verify(only_desk), false)
+
+	// An outsider can prove an edit, but it does not verify, and authorised editors do not build on it.
+	outsider_edit, err := outsider.Edit(edited, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	if err != nil {
+		fmt.Println("[Test_Editors] Error while proving the outsider edit: " + err.Error())
+		return false
+	}
+	check("outsider edit", verify(outsider_edit), false)
+	_, err = desk.Edit(outsider_edit, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	check("edit of an outsider edit", err, false)
+
+	// An image that nobody signed cannot be proven as the input of an edit, whatever the proof of the input.
+	forged := edited
+	if forged.Z.Img, err = image.NewImage("random"); err != nil {
+		fmt.Println("[Test_Editors] Error while creating an image: " + err.Error())
+		return false
+	}
+	forged.Z.Img.Hash_Function = cam.Prover.Hash_Function
+	forged.Proof.PCD_Proof = groth16.NewProof(ecc.BN254)
+	_, _, err = desk.Editor.Prove(cam.Prover, forged.Z, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, forged.Proof)
+	check("edit of an unsigned image", err, false)
+
+	forged_original := converted
+	forged_original.Z.Img = forged.Z.Img
+	_, _, err = desk.Editor.Prove(cam.Prover, forged_original.Z, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, forged.Proof)
+	check("edit of an image the camera did not sign", err, false)
+
+	// An edit signed by one editor cannot be claimed by the other.
+	claimed := edited
+	claimed.Z.Editor = desk.Editor.PublicKey
+	check("claim another editor", verify(claimed), false)
+
+	// The desk editor expires after its edit. The time of its history entry is in its window, but nobody signed it,
+	// so the history is rejected.
+	cam.Verifier.Editors.Add(photoproof.CameraKey{DeviceID: "desk", PublicKey: desk.Editor.PublicKey, NotAfter: time.Now()})
+	check("last editor of an expired chain", verify(edited), true)
+	check("expired desk editor in the history", camera.Verify_History(new_user(), edited, false), false)
+	cam.Verifier.Editors.Add(photoproof.CameraKey{DeviceID: "desk", PublicKey: desk.Editor.PublicKey})
+
+	// Revoking the desk editor rejects the chain at the desk edit, even if the last editor is authorised.
+	if err := cam.Verifier.Editors.Revoke("desk"); err != nil {
+		fmt.Println("[Test_Editors] Error while revoking an editor: " + err.Error())
+		return false
+	}
+	check("last editor of a revoked chain", verify(edited), true)
+	check("revoked desk editor in the history", camera.Verify_History(new_user(), edited, true), false)
+
+	if passed {
+		fmt.Println("********Test_Editors was successful!********")
+	} else {
+		fmt.Println("********Test_Editors FAILED********")
+	}
+
+	return passed
+}
@@ -0,0 +1,92 @@
+package example
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/editor"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks that failures can be told apart with errors.Is() and the sentinel errors of photoproof,
+and that they reach a logger set with photoproof.SetLogger().
+*/
+func Test_Errors() bool {
+	var logs bytes.Buffer
+	photoproof.SetLogger(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer photoproof.SetLogger(nil)
+
+	circuit := photoproof.Permissible_Transformations{}
+	cam, err := camera.NewCamera(&circuit)
+	if err != nil {
+		fmt.Println("[Test_Errors] Error while creating the camera: " + err.Error())
+		return false
+	}
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Errors] Error while taking a photograph: " + err.Error())
+		return false
+	}
+	ed := editor.Editor{Editor: cam.Admin}
+	converted, err := ed.Edit(photo, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	if err != nil {
+		fmt.Println("[Test_Errors] Error while proving an original photo: " + err.Error())
+		return false
+	}
+
+	passed := true
+	check := func(name string, err error, target error) {
+		if !errors.Is(err, target) {
+			fmt.Printf("[Test_Errors] FAILED %s: %v is not %v\n", name, err, target)
+			passed = false
+		} else {
+			fmt.Printf("[Test_Errors] ok %s: %v\n", name, err)
+		}
+	}
+	verify := func(photo camera.Photograph) error {
+		_, err := new_user().Verify(cam.Verifier, photo.Z, photo.Proof)
+		return err
+	}
+
+	bad_signature := photo
+	bad_signature.Z.Img.Pxls[0].RGB[0] ^= 1
+	check("original with another image", verify(bad_signature), photoproof.ErrBadSignature)
+
+	untrusted := photo
+	untrusted.Z.PublicKey = new_user().PublicKey
+	check("untrusted camera", verify(untrusted), photoproof.ErrUntrustedKey)
+
+	malformed := photo
+	malformed.Z.Provenance.Edits = 1
+	check("original with edits", verify(malformed), photoproof.ErrMalformed)
+
+	bad_proof := converted
+	bad_proof.Z.Img.Pxls[0].RGB[0] ^= 1
+	check("proof of another image", verify(bad_proof), photoproof.ErrProofInvalid)
+
+	_, err = ed.Edit(converted, photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: 300})
+	check("brightness out of range", err, photoproof.ErrNotPermissible)
+
+	_, err = photoproof.User{Signer: photoproof.PresignedSigner{PublicKey: new_user().PublicKey, Signature: photo.Proof.Signature}}.Sign(photo.Z.Img)
+	check("signature of another key", err, photoproof.ErrSign)
+
+	// The rejected proof was logged at the debug level.
+	if !strings.Contains(logs.String(), `"msg":"PCD proof rejected"`) {
+		fmt.Println("[Test_Errors] FAILED the rejected proof was not logged")
+		passed = false
+	}
+
+	if passed {
+		fmt.Println("********Test_Errors was successful!********")
+	} else {
+		fmt.Println("********Test_Errors FAILED********")
+	}
+
+	return passed
+}
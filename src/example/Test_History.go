@@ -0,0 +1,105 @@
+package example
+
+import (
+	"fmt"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/editor"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks the history of a Photograph: the hash chain of its steps from the capture, that each
+step transforms the image of the previous one, and the re-verification of every intermediate proof.
+*/
+func Test_History() bool {
+	circuit := photoproof.Permissible_Transformations{}
+	cam, err := camera.NewCamera(&circuit)
+	if err != nil {
+		fmt.Println("[Test_History] Error while creating the camera: " + err.Error())
+		return false
+	}
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_History] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	ed := editor.Editor{Editor: cam.Admin}
+	converted, err := ed.Edit(photo, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	if err != nil {
+		fmt.Println("[Test_History] Error while proving an original photo: " + err.Error())
+		return false
+	}
+	edited, err := ed.Edit(converted, photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: 20})
+	if err != nil {
+		fmt.Println("[Test_History] Error while proving an edit: " + err.Error())
+		return false
+	}
+
+	passed := true
+	check := func(name string, err error, expect_ok bool) {
+		if (err == nil) != expect_ok {
+			fmt.Printf("[Test_History] FAILED %s: accepted=%t (err: %v)\n", name, err == nil, err)
+			passed = false
+		} else {
+			fmt.Printf("[Test_History] ok %s (err: %v)\n", name, err)
+		}
+	}
+
+	for i, name := range []string{"capture", "identity", "brightness"} {
+		if len(edited.History) != 3 || edited.History[i].Transformation != name {
+			fmt.Printf("[Test_History] FAILED step %d is not %s\n", i, name)
+			passed = false
+		}
+	}
+
+	viewer := new_user()
+	check("hash chain", camera.Verify_History(viewer, edited, false), true)
+	check("re-verify every proof", camera.Verify_History(viewer, edited, true), true)
+
+	// Editing a photo does not change the history of its input.
+	check("history of the input", camera.Verify_History(viewer, converted, true), true)
+
+	tampered := edited
+	tampered.History = append([]camera.History_Entry{}, edited.History...)
+	tampered.History[2].Parameters = photoproof.Brightness_Tr_Params{Delta: 5}
+	check("rewrite a parameter", camera.Verify_History(viewer, tampered, false), false)
+
+	tampered.History = append([]camera.History_Entry{}, edited.History[0], edited.History[2])
+	check("drop a step", camera.Verify_History(viewer, tampered, false), false)
+
+	tampered.History = converted.History
+	check("history of another photo", camera.Verify_History(viewer, tampered, false), false)
+
+	// A history whose hashes are recomputed around an edit that did not produce its image.
+	dimmed, err := ed.Edit(converted, photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: -10})
+	if err != nil {
+		fmt.Println("[Test_History] Error while proving an edit: " + err.Error())
+		return false
+	}
+	tampered = dimmed
+	tampered.History = converted.History
+	if err := tampered.Record(photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: 20}, cam.Admin.PublicKey); err != nil {
+		fmt.Println("[Test_History] Error while recording an edit: " + err.Error())
+		return false
+	}
+	check("record another edit", camera.Verify_History(viewer, tampered, true), false)
+
+	other, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_History] Error while taking a photograph: " + err.Error())
+		return false
+	}
+	tampered.History = append(other.History[:1:1], edited.History[1:]...)
+	check("swap the capture", camera.Verify_History(viewer, tampered, false), false)
+
+	if passed {
+		fmt.Println("********Test_History was successful!********")
+	} else {
+		fmt.Println("********Test_History FAILED********")
+	}
+
+	return passed
+}
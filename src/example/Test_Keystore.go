@@ -0,0 +1,110 @@
+package example
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/drakstik/Photognark_V3/src/image"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks that a User survives a round trip through an encrypted keystore file,
+and that a wrong passphrase or a modified file is rejected.
+*/
+func Test_Keystore() bool {
+	dir, err := os.MkdirTemp("", "photognark_keystore")
+	if err != nil {
+		fmt.Println("[Test_Keystore] " + err.Error())
+		return false
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "editor.json")
+	passphrase := []byte("correct horse battery staple")
+
+	user := new_user()
+	if err := user.Save(path, passphrase); err != nil {
+		fmt.Println("[Test_Keystore] Error while saving the user: " + err.Error())
+		return false
+	}
+
+	// The raw secret key must not be on disk.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("[Test_Keystore] " + err.Error())
+		return false
+	}
+	if bytes.Contains(data, []byte(fmt.Sprintf("%x", user.SecretKey.Bytes()))) {
+		fmt.Println("[Test_Keystore] FAILED the secret key is stored in the clear")
+		return false
+	}
+
+	passed := true
+
+	// The loaded user must sign like the original one.
+	loaded, err := photoproof.LoadUser(path, passphrase)
+	if err != nil {
+		fmt.Println("[Test_Keystore] FAILED Error while loading the user: " + err.Error())
+		return false
+	}
+	img, err := image.NewImage("random")
+	if err != nil {
+		fmt.Println("[Test_Keystore] " + err.Error())
+		return false
+	}
+	signature, err := loaded.Sign(img)
+	if err != nil {
+		fmt.Println("[Test_Keystore] " + err.Error())
+		return false
+	}
+	if ok, err := user.PublicKey.Verify(signature, img.Hash(), hash.MIMC_BN254.New()); !ok || err != nil {
+		fmt.Println("[Test_Keystore] FAILED the loaded user does not sign for the original public key")
+		passed = false
+	}
+
+	if _, err := photoproof.LoadUser(path, []byte("wrong passphrase")); err == nil {
+		fmt.Println("[Test_Keystore] FAILED a wrong passphrase was accepted")
+		passed = false
+	}
+
+	// scrypt parameters that would allocate gigabytes or never finish are refused before deriving the key.
+	for _, params := range []string{`"n": 1099511627776`, `"n": 100000`, `"n": 0`, `"r": 1024`, `"p": 1000000`, `"p": 0`} {
+		field := params[:strings.Index(params, ":")]
+		crafted := regexp.MustCompile(field+`: \d+`).ReplaceAll(data, []byte(params))
+		crafted_path := filepath.Join(dir, "crafted.json")
+		if err := os.WriteFile(crafted_path, crafted, 0o600); err != nil {
+			fmt.Println("[Test_Keystore] " + err.Error())
+			return false
+		}
+		if _, err := photoproof.LoadUser(crafted_path, passphrase); err == nil || !strings.Contains(err.Error(), "malformed keystore: scrypt") {
+			fmt.Printf("[Test_Keystore] FAILED a keystore with %s was not refused (err: %v)\n", params, err)
+			passed = false
+		}
+	}
+
+	// Swap in another public key, which is authenticated by the AEAD.
+	other := fmt.Sprintf("%x", new_user().PublicKey.Bytes())
+	modified := bytes.Replace(data, []byte(fmt.Sprintf("%x", user.PublicKey.Bytes())), []byte(other), 1)
+	if err := os.WriteFile(path, modified, 0o600); err != nil {
+		fmt.Println("[Test_Keystore] " + err.Error())
+		return false
+	}
+	if _, err := photoproof.LoadUser(path, passphrase); err == nil {
+		fmt.Println("[Test_Keystore] FAILED a modified keystore was accepted")
+		passed = false
+	}
+
+	if passed {
+		fmt.Println("********Test_Keystore was successful!********")
+	} else {
+		fmt.Println("********Test_Keystore FAILED********")
+	}
+
+	return passed
+}
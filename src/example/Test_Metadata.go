@@ -0,0 +1,107 @@
+package example
+
+import (
+	"fmt"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/image"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks the signed capture metadata of original photographs: a viewer checks
+the disclosed fields against the signed commitment, and learns nothing of the hidden ones.
+Changing the commitment itself is covered by Test_Tampering.
+*/
+func Test_Metadata() bool {
+	cam := camera.Camera{
+		Admin: new_user(),
+		Metadata: image.Metadata{
+			Latitude:  40.712776,
+			Longitude: -74.005974,
+			DeviceID:  "camera-0001",
+			Lens:      "35mm f/1.8",
+		},
+	}
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Metadata] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	// The photo with its metadata commitment must verify.
+	viewer := new_user()
+	verifier_keys := photoproof.VerifierKeys{Original_PublicKey: cam.Admin.PublicKey}
+	ok, err := viewer.Verify(verifier_keys, photo.Z, photo.Proof)
+	if !ok || err != nil {
+		fmt.Printf("[Test_Metadata] FAILED photo with metadata was rejected (err: %v)\n", err)
+		return false
+	}
+
+	// Disclose the lens and device, but keep the location hidden.
+	published, err := photo.Disclose("device_id", "lens")
+	if err != nil {
+		fmt.Println("[Test_Metadata] Error while disclosing metadata: " + err.Error())
+		return false
+	}
+	if published.Metadata != nil {
+		fmt.Println("[Test_Metadata] FAILED the published photo still holds the metadata opening")
+		return false
+	}
+
+	passed := true
+	check := func(name string, disclosure image.Disclosure, expect_ok bool) {
+		err := disclosure.Verify(published.Z.MetadataCommitment)
+		if (err == nil) != expect_ok {
+			fmt.Printf("[Test_Metadata] FAILED %s: accepted=%t (err: %v)\n", name, err == nil, err)
+			passed = false
+		} else {
+			fmt.Printf("[Test_Metadata] ok %s (err: %v)\n", name, err)
+		}
+	}
+
+	disclosure := *published.Disclosure
+	check("honest disclosure", disclosure, true)
+
+	values := disclosure.Values()
+	if values["lens"] != "35mm f/1.8" || values["device_id"] != "camera-0001" {
+		fmt.Printf("[Test_Metadata] FAILED wrong disclosed values: %v\n", values)
+		passed = false
+	}
+	if _, found := values["latitude"]; found {
+		fmt.Println("[Test_Metadata] FAILED a hidden field was disclosed")
+		passed = false
+	}
+
+	forged := disclosure
+	forged.Fields[3].Value = "50mm f/1.4"
+	check("change a disclosed value", forged, false)
+
+	forged = disclosure
+	forged.Fields[0].Disclosed = true
+	forged.Fields[0].Value = "48.856613"
+	forged.Fields[0].Salt = disclosure.Fields[2].Salt
+	check("disclose a hidden field without its salt", forged, false)
+
+	// A disclosure of another photo must not match.
+	other, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Metadata] Error while taking a photograph: " + err.Error())
+		return false
+	}
+	other_disclosure, err := other.Metadata.Disclose("device_id", "lens")
+	if err != nil {
+		fmt.Println("[Test_Metadata] Error while disclosing metadata: " + err.Error())
+		return false
+	}
+	check("disclosure of another photo", other_disclosure, false)
+
+	if passed {
+		fmt.Println("********Test_Metadata was successful!********")
+	} else {
+		fmt.Println("********Test_Metadata FAILED********")
+	}
+
+	return passed
+}
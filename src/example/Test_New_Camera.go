@@ -5,9 +5,7 @@ import (
 	"github.com/drakstik/Photognark_V3/src/photoproof"
 )
 
-func Test_New_Camera() camera.Camera {
+func Test_New_Camera() (camera.Camera, error) {
 	circuit := photoproof.Permissible_Transformations{}
-	cam := camera.NewCamera(&circuit)
-
-	return cam
+	return camera.NewCamera(&circuit)
 }
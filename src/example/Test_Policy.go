@@ -0,0 +1,131 @@
+package example
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/editor"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+const test_policy = `{
+  "name": "brightness only",
+  "transformations": {
+    "brightness": {"max_delta": 40}
+  }
+}`
+
+/*
+This file checks a Policy file compiled into the keys: only brightness edits of at most 40 levels
+are permissible, and proofs only verify against the hash of that policy.
+*/
+func Test_Policy() bool {
+	dir, err := os.MkdirTemp("", "photognark-policy")
+	if err != nil {
+		fmt.Println("[Test_Policy] Error while creating a directory: " + err.Error())
+		return false
+	}
+	defer os.RemoveAll(dir)
+
+	// Policies naming transformations that the circuit does not know are refused.
+	path := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(path, []byte(`{"transformations": {"crop": {}}}`), 0o644); err != nil {
+		fmt.Println("[Test_Policy] Error while writing a policy: " + err.Error())
+		return false
+	}
+	if _, err := photoproof.LoadPolicy(path); err == nil {
+		fmt.Println("[Test_Policy] FAILED an unknown transformation was accepted")
+		return false
+	}
+
+	if err := os.WriteFile(path, []byte(test_policy), 0o644); err != nil {
+		fmt.Println("[Test_Policy] Error while writing a policy: " + err.Error())
+		return false
+	}
+	policy, err := photoproof.LoadPolicy(path)
+	if err != nil {
+		fmt.Println("[Test_Policy] Error while loading a policy: " + err.Error())
+		return false
+	}
+
+	circuit := photoproof.Permissible_Transformations{}
+	if err := policy.Apply(&circuit); err != nil {
+		fmt.Println("[Test_Policy] Error while applying a policy: " + err.Error())
+		return false
+	}
+	cam, err := camera.NewCamera(&circuit)
+	if err != nil {
+		fmt.Println("[Test_Policy] Error while creating the camera: " + err.Error())
+		return false
+	}
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Policy] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	// Converting the signature to a PCD proof is not an edit, so it is always permissible.
+	ed := editor.Editor{Editor: cam.Admin}
+	converted, err := ed.Edit(photo, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	if err != nil {
+		fmt.Println("[Test_Policy] Error while proving an original photo: " + err.Error())
+		return false
+	}
+
+	passed := true
+	check := func(name string, ok bool, expect_ok bool) {
+		if ok != expect_ok {
+			fmt.Printf("[Test_Policy] FAILED %s: accepted=%t\n", name, ok)
+			passed = false
+		} else {
+			fmt.Printf("[Test_Policy] ok %s\n", name)
+		}
+	}
+
+	brightened, err := ed.Edit(converted, photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: -30})
+	check("a brightness shift of 30", err == nil, true)
+	if err != nil {
+		return false
+	}
+
+	// A viewer checks that the published key enforces the published policy.
+	policy_id, err := policy.Hash()
+	if err != nil {
+		fmt.Println("[Test_Policy] Error while hashing a policy: " + err.Error())
+		return false
+	}
+	viewer_keys := brightened.VerifierKeys
+	viewer_keys.Policy_ID = policy_id
+
+	viewer := new_user()
+	ok, err := viewer.Verify(viewer_keys, brightened.Z, brightened.Proof)
+	check("verify against the policy", ok && err == nil, true)
+
+	other := policy
+	other.Transformations = map[string]photoproof.Transformation_Policy{"identity": {}, "brightness": {}}
+	viewer_keys.Policy_ID, err = other.Hash()
+	if err != nil {
+		fmt.Println("[Test_Policy] Error while hashing a policy: " + err.Error())
+		return false
+	}
+	ok, err = viewer.Verify(viewer_keys, brightened.Z, brightened.Proof)
+	check("verify against another policy", ok && err == nil, false)
+
+	// The circuit refuses edits outside the policy, so the prover cannot produce them.
+	_, err = ed.Edit(converted, photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: 45})
+	check("a brightness shift of 45", err == nil, false)
+
+	_, err = ed.Edit(converted, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	check("a disabled identity edit", err == nil, false)
+
+	if passed {
+		fmt.Println("********Test_Policy was successful!********")
+	} else {
+		fmt.Println("********Test_Policy FAILED********")
+	}
+
+	return passed
+}
@@ -0,0 +1,92 @@
+package example
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/editor"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file proves a small shoot with an editor.Pool, checks every edited photo, and cancels a second run
+after its first job.
+*/
+func Test_Pool() bool {
+	circuit := photoproof.Permissible_Transformations{}
+	cam, err := camera.NewCamera(&circuit)
+	if err != nil {
+		fmt.Println("[Test_Pool] Error while creating the camera: " + err.Error())
+		return false
+	}
+	ed := editor.Editor{Editor: cam.Admin}
+
+	var jobs []editor.Job
+	for i := 0; i < 4; i++ {
+		photo, err := cam.TakePhotograph("random")
+		if err != nil {
+			fmt.Println("[Test_Pool] Error while taking a photograph: " + err.Error())
+			return false
+		}
+		jobs = append(jobs, editor.Job{Photo: photo, Transformation: photoproof.Identity_Transformation{}, Parameters: photoproof.Identity_Tr_Params{}})
+	}
+
+	pool, err := editor.NewPool(ed, cam.Prover, 2)
+	if err != nil {
+		fmt.Println("[Test_Pool] Error while creating a pool: " + err.Error())
+		return false
+	}
+	pool.Memory_Limit = pool.Job_Memory // One proof at a time
+	pool.Progress = func(progress editor.Progress) {
+		fmt.Printf("[Test_Pool] %d/%d done (%d failed) after %s\n", progress.Done, progress.Total, progress.Failed, progress.Elapsed.Round(time.Millisecond))
+	}
+
+	passed := true
+	viewer := new_user()
+	for i, result := range pool.Run(context.Background(), jobs) {
+		if result.Err != nil {
+			fmt.Printf("[Test_Pool] FAILED job %d: %v\n", i, result.Err)
+			passed = false
+			continue
+		}
+		ok, err := viewer.Verify(result.Photo.VerifierKeys, result.Photo.Z, result.Photo.Proof)
+		if !ok || err != nil {
+			fmt.Printf("[Test_Pool] FAILED photo %d does not verify: %v\n", i, err)
+			passed = false
+		}
+		if err := camera.Verify_History(viewer, result.Photo, false); err != nil {
+			fmt.Printf("[Test_Pool] FAILED photo %d history: %v\n", i, err)
+			passed = false
+		}
+		fmt.Printf("[Test_Pool] photo %d proven in %s\n", i, result.Duration.Round(time.Millisecond))
+	}
+
+	// Cancel the run once the first job is done: the remaining jobs do not start.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool.Workers = 1
+	pool.Progress = func(editor.Progress) { cancel() }
+
+	results := pool.Run(ctx, jobs[:3])
+	if results[0].Err != nil {
+		fmt.Println("[Test_Pool] FAILED first job of the cancelled run: " + results[0].Err.Error())
+		passed = false
+	}
+	for i, result := range results[1:] {
+		if !errors.Is(result.Err, context.Canceled) {
+			fmt.Printf("[Test_Pool] FAILED job %d ran after cancellation: %v\n", i+1, result.Err)
+			passed = false
+		}
+	}
+
+	if passed {
+		fmt.Println("********Test_Pool was successful!********")
+	} else {
+		fmt.Println("********Test_Pool FAILED********")
+	}
+
+	return passed
+}
@@ -0,0 +1,162 @@
+package example
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/editor"
+	"github.com/drakstik/Photognark_V3/src/image"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks the Poseidon2 hash option: a camera whose policy selects Poseidon2 signs and proves photos
+that verify, the hash is kept by the policy, the verifier keys file and the photo container, and photos
+hashed with MiMC are rejected by its keys. It also prints the constraints of the circuit with each hash.
+*/
+func Test_Poseidon2() bool {
+	passed := true
+	fail := func(format string, args ...any) {
+		fmt.Printf("[Test_Poseidon2] FAILED "+format+"\n", args...)
+		passed = false
+	}
+
+	// The hash of a policy file is part of its Hash(), unless it is MiMC, so older policy IDs are unchanged.
+	var policy photoproof.Policy
+	if err := json.Unmarshal([]byte(`{"name": "poseidon2", "transformations": {"identity": {}, "brightness": {}}, "hash": "poseidon2"}`), &policy); err != nil {
+		fmt.Println("[Test_Poseidon2] Error while reading the policy: " + err.Error())
+		return false
+	}
+	mimc_policy := policy
+	mimc_policy.Hash_Function = image.MiMC
+	policy_id, _ := policy.Hash()
+	mimc_policy_id, _ := mimc_policy.Hash()
+	if policy.Hash_Function != image.Poseidon2 || bytes.Equal(policy_id, mimc_policy_id) {
+		fail("the policy does not record the hash")
+	}
+	if data, _ := json.Marshal(mimc_policy); bytes.Contains(data, []byte(`"hash"`)) {
+		fail("a MiMC policy writes its hash: %s", data)
+	}
+
+	circuit := photoproof.Permissible_Transformations{}
+	if err := policy.Apply(&circuit); err != nil {
+		fmt.Println("[Test_Poseidon2] Error while applying the policy: " + err.Error())
+		return false
+	}
+
+	cam, err := camera.NewCamera(&circuit)
+	if err != nil {
+		fmt.Println("[Test_Poseidon2] Error while creating the camera: " + err.Error())
+		return false
+	}
+	if cam.Prover.Hash_Function != image.Poseidon2 || cam.Verifier.Hash_Function != image.Poseidon2 {
+		fail("the keys do not record the hash: %s, %s", cam.Prover.Hash_Function, cam.Verifier.Hash_Function)
+	}
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Poseidon2] Error while taking a photograph: " + err.Error())
+		return false
+	}
+	if photo.Z.Img.Hash_Function != image.Poseidon2 {
+		fail("the photo is hashed with %s", photo.Z.Img.Hash_Function)
+	}
+
+	// Out of the circuit, the hashes differ.
+	mimc_img := photo.Z.Img
+	mimc_img.Hash_Function = image.MiMC
+	if bytes.Equal(photo.Z.Img.Hash(), mimc_img.Hash()) {
+		fail("Poseidon2 and MiMC give the same digest")
+	}
+
+	verify := func(verifier photoproof.VerifierKeys, photo camera.Photograph) error {
+		_, err := new_user().Verify(verifier, photo.Z, photo.Proof)
+		return err
+	}
+
+	// The signature of the camera, the conversion and an edit: each proof verifies the Poseidon2 signature
+	// made out of the circuit, so both hashes agree.
+	if err := verify(cam.Verifier, photo); err != nil {
+		fail("original photo: %v", err)
+	}
+	ed := editor.Editor{Editor: cam.Admin}
+	converted, err := ed.Edit(photo, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	if err != nil {
+		fmt.Println("[Test_Poseidon2] Error while proving the original photo: " + err.Error())
+		return false
+	}
+	if err := verify(cam.Verifier, converted); err != nil {
+		fail("converted photo: %v", err)
+	}
+	brighter, err := ed.Edit(converted, photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: 20})
+	if err != nil {
+		fmt.Println("[Test_Poseidon2] Error while proving the brightness edit: " + err.Error())
+		return false
+	}
+	if err := verify(cam.Verifier, brighter); err != nil {
+		fail("brightness edit: %v", err)
+	}
+
+	// The container and the verifier keys file keep the hash.
+	container, err := brighter.Container("poseidon2")
+	if err != nil {
+		fmt.Println("[Test_Poseidon2] Error while writing the container: " + err.Error())
+		return false
+	}
+	opened, _, err := camera.Open_Container(container)
+	if err != nil {
+		fmt.Println("[Test_Poseidon2] Error while opening the container: " + err.Error())
+		return false
+	}
+	dir, err := os.MkdirTemp("", "Test_Poseidon2")
+	if err != nil {
+		fmt.Println("[Test_Poseidon2] Error while creating a directory: " + err.Error())
+		return false
+	}
+	defer os.RemoveAll(dir)
+	if err := cam.Verifier.Save(dir); err != nil {
+		fmt.Println("[Test_Poseidon2] Error while saving the verifier keys: " + err.Error())
+		return false
+	}
+	loaded, err := photoproof.LoadVerifierKeys(dir)
+	if err != nil {
+		fmt.Println("[Test_Poseidon2] Error while loading the verifier keys: " + err.Error())
+		return false
+	}
+	if err := verify(loaded, opened); err != nil {
+		fail("opened container with loaded keys: %v", err)
+	}
+
+	// A photo hashed with MiMC is rejected by the Poseidon2 keys, before any signature or proof is checked.
+	mimc_photo := brighter
+	mimc_photo.Z.Img.Hash_Function = image.MiMC
+	if err := verify(cam.Verifier, mimc_photo); !errors.Is(err, photoproof.ErrMalformed) {
+		fail("a MiMC photo: %v is not %v", err, photoproof.ErrMalformed)
+	}
+	if _, err := ed.Edit(mimc_photo, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}); !errors.Is(err, photoproof.ErrMalformed) {
+		fail("proving a MiMC photo: %v is not %v", err, photoproof.ErrMalformed)
+	}
+
+	// The constraints of the circuit with each hash, for this image size.
+	for _, hash_function := range []image.Hash_Function{image.MiMC, image.Poseidon2} {
+		prover := cam.Prover
+		prover.Hash_Function = hash_function
+		if err := prover.Compile(); err != nil {
+			fmt.Println("[Test_Poseidon2] Error while compiling the circuit: " + err.Error())
+			return false
+		}
+		fmt.Printf("[Test_Poseidon2] %dx%d image hashed with %s: %d constraints\n", image.N, image.N, hash_function, prover.Compiled.GetNbConstraints())
+	}
+
+	if passed {
+		fmt.Println("********Test_Poseidon2 was successful!********")
+	} else {
+		fmt.Println("********Test_Poseidon2 FAILED********")
+	}
+
+	return passed
+}
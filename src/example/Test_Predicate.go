@@ -0,0 +1,89 @@
+package example
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/editor"
+	"github.com/drakstik/Photognark_V3/src/image"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks predicates over the hidden capture location: a photo taken in New York is proven
+to be taken inside the United States, without revealing its location. Its capture time is public,
+so a time predicate is refused (see Test_Private for one over the hidden time).
+The circuit's rejection of false claims is tested in package photoproof.
+*/
+
+var united_states = photoproof.BoundingBox{MinLatitude: 24.396308, MaxLatitude: 49.384358, MinLongitude: -125.0, MaxLongitude: -66.93457}
+var france = photoproof.BoundingBox{MinLatitude: 41.303921, MaxLatitude: 51.124199, MinLongitude: -5.142222, MaxLongitude: 9.561556}
+
+func Test_Predicate() bool {
+	circuit := photoproof.Permissible_Transformations{}
+	cam, err := camera.NewCamera(&circuit)
+	if err != nil {
+		fmt.Println("[Test_Predicate] Error while creating the camera: " + err.Error())
+		return false
+	}
+	cam.Metadata = image.Metadata{Latitude: 40.712776, Longitude: -74.005974, DeviceID: "camera-0001", Lens: "35mm f/1.8"}
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Predicate] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	passed := true
+	in_us := photoproof.Predicate{Box: &united_states}
+
+	// Prove the claim, and publish the photo without its metadata.
+	ed := editor.Editor{Editor: cam.Admin}
+	claimed, err := ed.Prove_Predicate(photo, in_us)
+	if err != nil {
+		fmt.Println("[Test_Predicate] Error while proving a predicate: " + err.Error())
+		return false
+	}
+	claimed.Metadata = nil
+
+	viewer := new_user()
+	ok, err := viewer.Verify_Predicate(claimed.VerifierKeys, claimed.Z, claimed.Proof, in_us)
+	if !ok || err != nil {
+		fmt.Printf("[Test_Predicate] FAILED honest claim was rejected (err: %v)\n", err)
+		passed = false
+	}
+
+	// The proof does not hold for another claim.
+	if ok, _ := viewer.Verify_Predicate(claimed.VerifierKeys, claimed.Z, claimed.Proof, photoproof.Predicate{Box: &france}); ok {
+		fmt.Println("[Test_Predicate] FAILED proof was accepted for another claim")
+		passed = false
+	}
+
+	// The capture time is public in the main circuit: a time bound would reveal it, so it is refused.
+	day := time.Unix(int64(photo.Z.Timestamp), 0).Add(-24 * time.Hour)
+	after_day := photoproof.Predicate{Box: &united_states, After: day}
+	if _, err := ed.Prove_Predicate(photo, after_day); !errors.Is(err, photoproof.ErrPublicTime) {
+		fmt.Printf("[Test_Predicate] FAILED prover accepted a time predicate (err: %v)\n", err)
+		passed = false
+	}
+	if _, err := viewer.Verify_Predicate(claimed.VerifierKeys, claimed.Z, claimed.Proof, after_day); !errors.Is(err, photoproof.ErrPublicTime) {
+		fmt.Printf("[Test_Predicate] FAILED verifier accepted a time predicate (err: %v)\n", err)
+		passed = false
+	}
+
+	// The prover refuses a false claim.
+	if _, err := ed.Prove_Predicate(photo, photoproof.Predicate{Box: &france}); err == nil {
+		fmt.Println("[Test_Predicate] FAILED prover accepted a false claim")
+		passed = false
+	}
+
+	if passed {
+		fmt.Println("********Test_Predicate was successful!********")
+	} else {
+		fmt.Println("********Test_Predicate FAILED********")
+	}
+
+	return passed
+}
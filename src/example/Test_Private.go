@@ -0,0 +1,106 @@
+package example
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/image"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks the private circuit: an edited photo verifies against the trusted camera key,
+while its original hash, original signature and capture fields stay hidden.
+*/
+func Test_Private() bool {
+	circuit := photoproof.Private_Permissible_Transformations{}
+	prover, verifier, admin, err := photoproof.Generator_Private(&circuit)
+	if err != nil {
+		fmt.Println("[Test_Private] Error while generating the keys: " + err.Error())
+		return false
+	}
+
+	cam := camera.Camera{Admin: admin, Prover: prover, Verifier: verifier}
+	cam.Metadata = image.Metadata{Latitude: 40.712776, Longitude: -74.005974, DeviceID: "camera-0001", Lens: "35mm f/1.8"}
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Private] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	// Convert the signature to a private proof, then edit the photo.
+	z_out, proof_out, err := cam.Admin.Prove_Private(prover, photo.Z, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, photo.Proof)
+	if err != nil {
+		fmt.Println("[Test_Private] Error while proving an original photo: " + err.Error())
+		return false
+	}
+	z_out, proof_out, err = cam.Admin.Prove_Private(prover, z_out, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, proof_out)
+	if err != nil {
+		fmt.Println("[Test_Private] Error while proving an edit: " + err.Error())
+		return false
+	}
+
+	// Only the image, the camera key and the PCD proof are published.
+	published_z := z_out.Private()
+	published_proof := photoproof.Proof{PCD_Proof: proof_out.PCD_Proof}
+	if published_z.OriginalHash != nil || published_z.OriginalSignature != nil || published_z.Timestamp != 0 || published_z.MetadataCommitment != nil {
+		fmt.Println("[Test_Private] FAILED the published photo links back to its original")
+		return false
+	}
+
+	viewer := new_user()
+	passed := true
+	check := func(name string, ok bool, err error, expect_ok bool) {
+		if (ok && err == nil) != expect_ok {
+			fmt.Printf("[Test_Private] FAILED %s: accepted=%t (err: %v)\n", name, ok && err == nil, err)
+			passed = false
+		} else {
+			fmt.Printf("[Test_Private] ok %s\n", name)
+		}
+	}
+
+	ok, err := viewer.Verify_Private(verifier, published_z, published_proof)
+	check("honest edit", ok, err, true)
+
+	tampered_z := published_z
+	tampered_z.Img.Pxls[0].RGB[0] ^= 1
+	ok, err = viewer.Verify_Private(verifier, tampered_z, published_proof)
+	check("flip a pixel", ok, err, false)
+
+	// Another trusted key must not match the proof either.
+	other := new_user()
+	tampered_z = published_z
+	tampered_z.PublicKey = other.PublicKey
+	other_verifier := verifier
+	other_verifier.Original_PublicKey = other.PublicKey
+	ok, err = viewer.Verify_Private(other_verifier, tampered_z, published_proof)
+	check("swap camera key", ok, err, false)
+
+	// The verifier keys of the main circuit do not verify a private proof.
+	ok, err = viewer.Verify(verifier, z_out, proof_out)
+	check("verify as a public photo", ok, err, false)
+
+	// A claim about the hidden capture time and location, which stay hidden.
+	in_us := photoproof.Predicate{Box: &united_states, After: time.Now().Add(-24 * time.Hour)}
+	_, claim_proof, err := cam.Admin.Prove_Private_Predicate(prover, z_out, *photo.Metadata, in_us, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, proof_out)
+	if err != nil {
+		fmt.Println("[Test_Private] Error while proving a predicate: " + err.Error())
+		return false
+	}
+	claim_proof = photoproof.Proof{PCD_Proof: claim_proof.PCD_Proof}
+
+	ok, err = viewer.Verify_Private_Predicate(verifier, published_z, claim_proof, in_us)
+	check("taken in the US after a day", ok, err, true)
+
+	ok, err = viewer.Verify_Private_Predicate(verifier, published_z, claim_proof, photoproof.Predicate{Box: &france})
+	check("taken in France", ok, err, false)
+
+	if passed {
+		fmt.Println("********Test_Private was successful!********")
+	} else {
+		fmt.Println("********Test_Private FAILED********")
+	}
+
+	return passed
+}
@@ -0,0 +1,191 @@
+package example
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+	"github.com/drakstik/Photognark_V3/src/server"
+)
+
+/*
+This file runs the proving service with httptest: an editor converts and brightens a photo through it,
+signing locally, a forged editor signature and an unauthorised editor are refused, and a job submitted before
+a restart is proven after it, while the queue and the finished jobs are capped.
+*/
+func Test_Prove_Server() bool {
+	circuit := photoproof.Permissible_Transformations{}
+	cam, err := camera.NewCamera(&circuit)
+	if err != nil {
+		fmt.Println("[Test_Prove_Server] Error while creating the camera: " + err.Error())
+		return false
+	}
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Prove_Server] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	dir, err := os.MkdirTemp("", "photognark-jobs")
+	if err != nil {
+		fmt.Println("[Test_Prove_Server] Error while creating the jobs directory: " + err.Error())
+		return false
+	}
+	defer os.RemoveAll(dir)
+
+	prover, err := server.NewProving_Server("newsroom", cam.Prover, dir)
+	if err != nil {
+		fmt.Println("[Test_Prove_Server] Error while creating the proving service: " + err.Error())
+		return false
+	}
+	prover.Editors = cam.Verifier.Editors // Shared, so that the client's editor authorised below is proven
+	ctx, stop := context.WithCancel(context.Background())
+	prover.Start(ctx)
+	service := httptest.NewServer(prover.Handler())
+
+	passed := true
+	fail := func(format string, args ...any) {
+		fmt.Printf("[Test_Prove_Server] FAILED "+format+"\n", args...)
+		passed = false
+	}
+	verify := func(name string, photo camera.Photograph) {
+		ok, err := new_user().Verify(cam.Verifier, photo.Z, photo.Proof)
+		if !ok || err != nil {
+			fail("%s does not verify: %v", name, err)
+		} else {
+			fmt.Printf("[Test_Prove_Server] ok %s verifies\n", name)
+		}
+	}
+
+	client := server.Client{URL: service.URL, Editor: new_user()}
+	cam.Verifier.Editors.Add(photoproof.CameraKey{DeviceID: "remote", PublicKey: client.Editor.PublicKey})
+	edit := func(name string, photo camera.Photograph, tr photoproof.Transformation, params photoproof.Transformation_Parameters) camera.Photograph {
+		job, err := client.Submit(photo, tr, params)
+		if err != nil {
+			fail("%s: %v", name, err)
+			return camera.Photograph{}
+		}
+		wait, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		edited, err := client.Wait(wait, job.ID, 200*time.Millisecond)
+		if err != nil {
+			fail("%s: %v", name, err)
+			return camera.Photograph{}
+		}
+		job, _ = client.Job(job.ID)
+		fmt.Printf("[Test_Prove_Server] %s: job %s %s (%s)\n", name, job.ID, job.Status, job.Timings)
+		return edited
+	}
+
+	converted := edit("conversion", photo, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	verify("converted photo", converted)
+
+	brightened := edit("brightness edit", converted, photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: 20})
+	verify("brightened photo", brightened)
+	if brightened.Z.Editor == nil || !bytes.Equal(brightened.Z.Editor.Bytes(), client.Editor.PublicKey.Bytes()) {
+		fail("brightened photo is not signed by the client's editor")
+	}
+
+	// The service only proves edits signed by the editor of the request.
+	container, _ := converted.Container("")
+	signature, _ := new_user().Sign(converted.Z.Img)
+	forged, _ := json.Marshal(server.Edit_Request{
+		Photo:          container,
+		Transformation: "identity",
+		Editor:         hex.EncodeToString(client.Editor.PublicKey.Bytes()),
+		Signature:      hex.EncodeToString(signature),
+	})
+	response, err := http.Post(service.URL+"/edit", "application/json", bytes.NewReader(forged))
+	if err != nil || response.StatusCode != http.StatusBadRequest {
+		fail("forged editor signature was not refused: %v", err)
+	} else {
+		response.Body.Close()
+		fmt.Println("[Test_Prove_Server] ok forged editor signature refused")
+	}
+
+	// The service only proves edits of authorised editors.
+	outsider := new_user()
+	z_out, _ := photoproof.Next_Z(converted.Z, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, outsider.PublicKey)
+	signature, _ = outsider.Sign_Edit(z_out)
+	unauthorised, _ := json.Marshal(server.Edit_Request{
+		Photo:          container,
+		Transformation: "identity",
+		Editor:         hex.EncodeToString(outsider.PublicKey.Bytes()),
+		Signature:      hex.EncodeToString(signature),
+	})
+	response, err = http.Post(service.URL+"/edit", "application/json", bytes.NewReader(unauthorised))
+	if err != nil || response.StatusCode != http.StatusForbidden {
+		fail("unauthorised editor was not refused: %v", err)
+	} else {
+		response.Body.Close()
+		fmt.Println("[Test_Prove_Server] ok unauthorised editor refused")
+	}
+
+	if _, err := client.Result("unknown"); err == nil {
+		fail("result of an unknown job")
+	}
+
+	// Stop the service with a queued job, and prove it after a restart.
+	service.Close()
+	stop()
+	job, err := prover.Submit(server.Edit_Request{Photo: container, Transformation: "identity"})
+	if err == nil {
+		fail("an edited photo was submitted without an editor signature")
+	}
+	original, _ := photo.Container("")
+	if job, err = prover.Submit(server.Edit_Request{Photo: original, Transformation: "identity"}); err != nil {
+		fail("submit before restart: %v", err)
+	}
+	prover.Max_Queued = 1
+	if _, err := prover.Submit(server.Edit_Request{Photo: original, Transformation: "identity"}); !errors.Is(err, server.ErrQueueFull) {
+		fail("a job beyond the queue was not refused: %v", err)
+	}
+
+	restarted, err := server.NewProving_Server("newsroom", cam.Prover, dir)
+	if err != nil {
+		fail("restart: %v", err)
+		return false
+	}
+	if saved, ok := restarted.Job(job.ID); !ok || saved.Status != server.Job_Queued {
+		fail("job was not queued again after the restart: %+v", saved)
+	}
+	restarted.Editors = cam.Verifier.Editors
+	restarted.Max_Finished = 1 // Only the job below is kept once it is done
+	ctx, stop = context.WithCancel(context.Background())
+	defer stop()
+	restarted.Start(ctx)
+	service = httptest.NewServer(restarted.Handler())
+	defer service.Close()
+	client.URL = service.URL
+
+	wait, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	resumed, err := client.Wait(wait, job.ID, 200*time.Millisecond)
+	if err != nil {
+		fail("job after the restart: %v", err)
+	} else {
+		verify("photo proven after the restart", resumed)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 1 {
+		fail("%d jobs are kept, not 1", len(files))
+	}
+
+	if passed {
+		fmt.Println("********Test_Prove_Server was successful!********")
+	} else {
+		fmt.Println("********Test_Prove_Server FAILED********")
+	}
+
+	return passed
+}
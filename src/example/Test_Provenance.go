@@ -0,0 +1,92 @@
+package example
+
+import (
+	"fmt"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/editor"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks the ProvenanceBounds compiled into the circuit by the admin:
+at most one brightness edit, with a total brightness shift under 20%.
+*/
+func Test_Provenance() bool {
+	circuit := photoproof.Permissible_Transformations{}
+	circuit.Bounds.Max_Uses[photoproof.Transformation_Index("brightness")] = 1
+	circuit.Bounds.Max_Brightness = 51 // 20% of 255
+	cam, err := camera.NewCamera(&circuit)
+	if err != nil {
+		fmt.Println("[Test_Provenance] Error while creating the camera: " + err.Error())
+		return false
+	}
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Provenance] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	// Convert the signature to a PCD proof, which is not an edit, then brighten the photo once.
+	ed := editor.Editor{Editor: cam.Admin}
+	converted, err := ed.Edit(photo, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	if err != nil {
+		fmt.Println("[Test_Provenance] Error while proving an original photo: " + err.Error())
+		return false
+	}
+	brightened, err := ed.Edit(converted, photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: 30})
+	if err != nil {
+		fmt.Println("[Test_Provenance] Error while proving a brightness edit: " + err.Error())
+		return false
+	}
+
+	passed := true
+	check := func(name string, ok bool, expect_ok bool) {
+		if ok != expect_ok {
+			fmt.Printf("[Test_Provenance] FAILED %s: accepted=%t\n", name, ok)
+			passed = false
+		} else {
+			fmt.Printf("[Test_Provenance] ok %s\n", name)
+		}
+	}
+
+	if brightened.Z.Provenance.Edits != 1 || brightened.Z.Provenance.Brightness != 30 {
+		fmt.Printf("[Test_Provenance] FAILED unexpected provenance %+v\n", brightened.Z.Provenance)
+		passed = false
+	}
+
+	viewer := new_user()
+	ok, err := viewer.Verify(brightened.VerifierKeys, brightened.Z, brightened.Proof)
+	check("one brightness edit of 30", ok && err == nil, true)
+
+	// The published provenance is bound to the proof.
+	tampered := brightened.Z
+	tampered.Provenance.Edits = 0
+	ok, err = viewer.Verify(brightened.VerifierKeys, tampered, brightened.Proof)
+	check("hide an edit", ok && err == nil, false)
+
+	tampered = brightened.Z
+	tampered.Provenance.Brightness = 0
+	ok, err = viewer.Verify(brightened.VerifierKeys, tampered, brightened.Proof)
+	check("hide the brightness shift", ok && err == nil, false)
+
+	// The circuit refuses edits beyond the bounds, so the prover cannot produce them.
+	_, err = ed.Edit(brightened, photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: -10})
+	check("a second brightness edit", err == nil, false)
+
+	_, err = ed.Edit(converted, photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: -60})
+	check("a brightness shift of 60", err == nil, false)
+
+	// Identity edits are not bounded.
+	_, err = ed.Edit(brightened, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	check("an identity edit after brightness", err == nil, true)
+
+	if passed {
+		fmt.Println("********Test_Provenance was successful!********")
+	} else {
+		fmt.Println("********Test_Provenance FAILED********")
+	}
+
+	return passed
+}
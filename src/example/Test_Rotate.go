@@ -0,0 +1,132 @@
+package example
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks the rotate command, run as a separate process like an operator would: the new key and
+its certificate are written to the camera directory, photos taken before a rotation still verify with
+the certificate kept from their key, and the manufacturer never issues the same serial twice.
+Original photographs carry no PCD proof, so no setup is needed.
+*/
+func Test_Rotate() bool {
+	dir, err := os.MkdirTemp("", "photognark_rotate")
+	if err != nil {
+		fmt.Println("[Test_Rotate] " + err.Error())
+		return false
+	}
+	defer os.RemoveAll(dir)
+
+	camera_dir, manufacturer_dir := filepath.Join(dir, "camera"), filepath.Join(dir, "manufacturer")
+	passphrase, manufacturer_passphrase := "camera passphrase", "manufacturer passphrase"
+
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Println("[Test_Rotate] " + err.Error())
+		return false
+	}
+	rotate := func(env_passphrase string, args ...string) error {
+		cmd := exec.Command(executable, append([]string{"rotate"}, args...)...)
+		cmd.Env = append(os.Environ(), "PHOTOGNARK_PASSPHRASE="+env_passphrase, "PHOTOGNARK_MANUFACTURER_PASSPHRASE="+manufacturer_passphrase)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("rotate %v: %w: %s", args, err, output)
+		}
+		return nil
+	}
+
+	passed := true
+	fail := func(format string, args ...any) {
+		fmt.Printf("[Test_Rotate] FAILED "+format+"\n", args...)
+		passed = false
+	}
+
+	// The first rotation of a camera without a key gives it one.
+	if err := rotate(passphrase, "new-manufacturer", manufacturer_dir); err != nil {
+		fmt.Println("[Test_Rotate] " + err.Error())
+		return false
+	}
+	if err := rotate(passphrase, camera_dir, manufacturer_dir, "camera-1", "24h"); err != nil {
+		fmt.Println("[Test_Rotate] " + err.Error())
+		return false
+	}
+
+	cam := camera.Camera{}
+	if err := cam.Load_Identity(camera_dir, []byte(passphrase)); err != nil {
+		fmt.Println("[Test_Rotate] " + err.Error())
+		return false
+	}
+	old_photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Rotate] Error while taking a photograph: " + err.Error())
+		return false
+	}
+	old_key := cam.Admin.PublicKey.Bytes()
+
+	// A wrong passphrase must not replace the key.
+	if err := rotate("wrong passphrase", camera_dir, manufacturer_dir, "camera-1", "24h"); err == nil {
+		fail("rotated with a wrong passphrase")
+	}
+
+	if err := rotate(passphrase, camera_dir, manufacturer_dir, "camera-1", "24h"); err != nil {
+		fmt.Println("[Test_Rotate] " + err.Error())
+		return false
+	}
+	if err := cam.Load_Identity(camera_dir, []byte(passphrase)); err != nil {
+		fmt.Println("[Test_Rotate] " + err.Error())
+		return false
+	}
+	if bytes.Equal(cam.Admin.PublicKey.Bytes(), old_key) {
+		fail("the key was not rotated")
+	}
+	new_photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Rotate] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	// The failed rotation issued nothing: the certificates are 1 and 2.
+	old_certificate, err := photoproof.LoadCertificate(filepath.Join(camera_dir, "certificate-1.json"))
+	if err != nil {
+		fmt.Println("[Test_Rotate] The certificate of the old key was not kept: " + err.Error())
+		return false
+	}
+	if cam.Certificate.Serial != 2 {
+		fail("the new certificate has serial %d, expected 2", cam.Certificate.Serial)
+	}
+
+	manufacturer, err := photoproof.LoadManufacturer(manufacturer_dir, []byte(manufacturer_passphrase))
+	if err != nil {
+		fmt.Println("[Test_Rotate] " + err.Error())
+		return false
+	}
+	verifier_keys := photoproof.VerifierKeys{Root_PublicKey: manufacturer.Root.PublicKey}
+	viewer := new_user()
+	check := func(name string, photo camera.Photograph, certificate photoproof.Certificate, expect_ok bool) {
+		ok, err := viewer.Verify_Certified(verifier_keys, certificate, photo.Z, photo.Proof)
+		if (ok && err == nil) != expect_ok {
+			fail("%s: accepted=%t (err: %v)", name, ok && err == nil, err)
+		} else {
+			fmt.Printf("[Test_Rotate] ok %s (err: %v)\n", name, err)
+		}
+	}
+	check("photo before rotation, with the kept certificate", old_photo, old_certificate, true)
+	check("photo after rotation", new_photo, *cam.Certificate, true)
+	check("photo before rotation, with the new certificate", old_photo, *cam.Certificate, false)
+
+	if passed {
+		fmt.Println("********Test_Rotate was successful!********")
+	} else {
+		fmt.Println("********Test_Rotate FAILED********")
+	}
+
+	return passed
+}
@@ -0,0 +1,111 @@
+//go:build seeded_setup
+
+package example
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/editor"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks the options of the Generator: the same seed gives the same keys and admin,
+an existing camera identity is kept, and failures are returned instead of empty keys.
+Like With_Seed(), it is only built with -tags seeded_setup: go run -tags seeded_setup ./src seed
+*/
+func Test_Seed() bool {
+	seed := []byte("Test_Seed")
+	circuit := photoproof.Permissible_Transformations{}
+
+	passed := true
+	fail := func(format string, args ...any) {
+		fmt.Printf("[Test_Seed] FAILED "+format+"\n", args...)
+		passed = false
+	}
+
+	// An admin without a key is an error, before any setup.
+	_, _, _, err := photoproof.Generator(&circuit, photoproof.With_Admin(photoproof.User{}))
+	if !errors.Is(err, photoproof.ErrSign) {
+		fail("an admin without a key: %v", err)
+	}
+
+	cam, err := camera.NewCamera(&circuit, photoproof.With_Seed(seed))
+	if err != nil {
+		fmt.Println("[Test_Seed] Error while creating the camera: " + err.Error())
+		return false
+	}
+
+	// The same seed with an existing identity: the same PCD keys, and the identity as admin.
+	existing := new_user()
+	prover, verifier, admin, err := photoproof.Generator(&circuit, photoproof.With_Seed(seed), photoproof.With_Admin(existing))
+	if err != nil {
+		fmt.Println("[Test_Seed] Error while generating the keys: " + err.Error())
+		return false
+	}
+	if !bytes.Equal(admin.PublicKey.Bytes(), existing.PublicKey.Bytes()) || !bytes.Equal(verifier.Original_PublicKey.Bytes(), existing.PublicKey.Bytes()) {
+		fail("the existing identity is not the admin")
+	}
+	if vk := key_bytes(verifier.VerifyingKey); vk == nil || !bytes.Equal(vk, key_bytes(cam.Verifier.VerifyingKey)) {
+		fail("the same seed gave another verifying key")
+	}
+	if pk := key_bytes(prover.ProvingKey); pk == nil || !bytes.Equal(pk, key_bytes(cam.Prover.ProvingKey)) {
+		fail("the same seed gave another proving key")
+	}
+
+	// The seeded admin does not depend on the setup.
+	_, _, seeded_admin, err := photoproof.Generator_From_Keys(&circuit, cam.Prover.ProvingKey, cam.Verifier.VerifyingKey, photoproof.With_Seed(seed))
+	if err != nil {
+		fail("seeded admin: %v", err)
+	} else if !bytes.Equal(seeded_admin.PublicKey.Bytes(), cam.Admin.PublicKey.Bytes()) {
+		fail("the same seed gave another admin")
+	}
+	_, _, other_admin, err := photoproof.Generator_From_Keys(&circuit, cam.Prover.ProvingKey, cam.Verifier.VerifyingKey, photoproof.With_Seed([]byte("another seed")))
+	if err != nil {
+		fail("seeded admin: %v", err)
+	} else if bytes.Equal(other_admin.PublicKey.Bytes(), cam.Admin.PublicKey.Bytes()) {
+		fail("another seed gave the same admin")
+	}
+
+	// The seeded keys prove and verify like any others.
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Seed] Error while taking a photograph: " + err.Error())
+		return false
+	}
+	ed := editor.Editor{Editor: cam.Admin}
+	edited, err := ed.Edit(photo, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	if err != nil {
+		fail("proof with the seeded keys: %v", err)
+	} else {
+		// Trust the camera, with the verifying key of the second setup.
+		keys := cam.Verifier
+		keys.VerifyingKey = verifier.VerifyingKey
+		if ok, err := new_user().Verify(keys, edited.Z, edited.Proof); !ok {
+			fail("verification with the keys of the same seed: %v", err)
+		}
+	}
+
+	if passed {
+		fmt.Println("********Test_Seed was successful!********")
+	} else {
+		fmt.Println("********Test_Seed FAILED********")
+	}
+
+	return passed
+}
+
+// The serialization of a PCD key, nil if it fails.
+func key_bytes(key interface {
+	WriteRawTo(w io.Writer) (int64, error)
+}) []byte {
+	var buf bytes.Buffer
+	if _, err := key.WriteRawTo(&buf); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
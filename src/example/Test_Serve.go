@@ -0,0 +1,154 @@
+package example
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/editor"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+	"github.com/drakstik/Photognark_V3/src/server"
+)
+
+/*
+This file runs the verification service on keys saved to disk, with httptest, and posts honest and tampered
+photo containers and PNGs to POST /verify.
+*/
+func Test_Serve() bool {
+	circuit := photoproof.Permissible_Transformations{}
+	cam, err := camera.NewCamera(&circuit)
+	if err != nil {
+		fmt.Println("[Test_Serve] Error while creating the camera: " + err.Error())
+		return false
+	}
+	cam.Metadata.DeviceID = "newsroom-cam-7"
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Serve] Error while taking a photograph: " + err.Error())
+		return false
+	}
+	if photo, err = photo.Disclose("device_id"); err != nil {
+		fmt.Println("[Test_Serve] Error while disclosing metadata: " + err.Error())
+		return false
+	}
+
+	ed := editor.Editor{Editor: cam.Admin}
+	converted, err := ed.Edit(photo, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	if err != nil {
+		fmt.Println("[Test_Serve] Error while proving an original photo: " + err.Error())
+		return false
+	}
+	edited, err := ed.Edit(converted, photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: 12})
+	if err != nil {
+		fmt.Println("[Test_Serve] Error while proving an edit: " + err.Error())
+		return false
+	}
+
+	// Two key IDs: the camera's keys, and keys that trust no camera.
+	dir, err := os.MkdirTemp("", "photognark-keys")
+	if err != nil {
+		fmt.Println("[Test_Serve] Error while creating the keys directory: " + err.Error())
+		return false
+	}
+	defer os.RemoveAll(dir)
+
+	untrusting := cam.Verifier
+	untrusting.TrustStore = photoproof.NewTrustStore()
+	if err := cam.Verifier.Save(filepath.Join(dir, "newsroom")); err != nil {
+		fmt.Println("[Test_Serve] Error while saving keys: " + err.Error())
+		return false
+	}
+	if err := untrusting.Save(filepath.Join(dir, "untrusting")); err != nil {
+		fmt.Println("[Test_Serve] Error while saving keys: " + err.Error())
+		return false
+	}
+
+	s, err := server.Load(dir)
+	if err != nil {
+		fmt.Println("[Test_Serve] Error while loading the server: " + err.Error())
+		return false
+	}
+	service := httptest.NewServer(s.Handler())
+	defer service.Close()
+
+	passed := true
+	post := func(name string, path string, content_type string, body []byte, expect_status int, expect_ok bool) server.Result {
+		var result server.Result
+		response, err := http.Post(service.URL+path, content_type, bytes.NewReader(body))
+		if err != nil {
+			fmt.Printf("[Test_Serve] FAILED %s: %v\n", name, err)
+			passed = false
+			return result
+		}
+		defer response.Body.Close()
+		json.NewDecoder(response.Body).Decode(&result)
+
+		if response.StatusCode != expect_status || result.OK != expect_ok {
+			fmt.Printf("[Test_Serve] FAILED %s: status %d, ok=%t (%s: %s)\n", name, response.StatusCode, result.OK, result.Stage, result.Error)
+			passed = false
+		} else {
+			fmt.Printf("[Test_Serve] ok %s: status %d, ok=%t %s\n", name, response.StatusCode, result.OK, result.Error)
+		}
+		return result
+	}
+	container := func(photo camera.Photograph, key_id string) []byte {
+		data, err := photo.Container(key_id)
+		if err != nil {
+			fmt.Println("[Test_Serve] FAILED container: " + err.Error())
+			passed = false
+		}
+		return data
+	}
+	embed := func(photo camera.Photograph, key_id string) []byte {
+		data, err := photo.Embed_PNG(key_id)
+		if err != nil {
+			fmt.Println("[Test_Serve] FAILED PNG: " + err.Error())
+			passed = false
+		}
+		return data
+	}
+
+	result := post("original container", "/verify", "application/json", container(photo, "newsroom"), http.StatusOK, true)
+	if !result.Original || result.Disclosed["device_id"] != cam.Metadata.DeviceID {
+		fmt.Printf("[Test_Serve] FAILED original result: %+v\n", result)
+		passed = false
+	}
+
+	result = post("edited PNG", "/verify", "image/png", embed(edited, "newsroom"), http.StatusOK, true)
+	if result.Original || result.Provenance.Edits != 1 || result.Provenance.Brightness != 12 || result.Editor == "" {
+		fmt.Printf("[Test_Serve] FAILED edited result: %+v\n", result)
+		passed = false
+	}
+
+	tampered := edited
+	tampered.Z.Img.Pxls[3].RGB[1] ^= 1
+	post("tampered PNG", "/verify", "image/png", embed(tampered, "newsroom"), http.StatusOK, false)
+
+	post("untrusting keys", "/verify?key=untrusting", "image/png", embed(edited, "newsroom"), http.StatusOK, false)
+	post("unknown keys", "/verify", "application/json", container(photo, "elsewhere"), http.StatusNotFound, false)
+	post("malformed container", "/verify", "application/json", []byte(`{"pixels": "00"}`), http.StatusBadRequest, false)
+	post("unsupported content", "/verify", "text/plain", []byte("hello"), http.StatusUnsupportedMediaType, false)
+
+	response, err := http.Get(service.URL + "/verify")
+	if err != nil || response.StatusCode != http.StatusMethodNotAllowed {
+		fmt.Printf("[Test_Serve] FAILED GET /verify: %v\n", err)
+		passed = false
+	}
+	if err == nil {
+		response.Body.Close()
+	}
+
+	if passed {
+		fmt.Println("********Test_Serve was successful!********")
+	} else {
+		fmt.Println("********Test_Serve FAILED********")
+	}
+
+	return passed
+}
@@ -0,0 +1,68 @@
+package example
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks that a camera can sign through a signing daemon over a Unix socket,
+so that its secret key never enters the camera process.
+The daemon runs in a goroutine, as a stand-in for a separate process or a secure element.
+*/
+func Test_Signer() bool {
+	dir, err := os.MkdirTemp("", "photognark_signer")
+	if err != nil {
+		fmt.Println("[Test_Signer] " + err.Error())
+		return false
+	}
+	defer os.RemoveAll(dir)
+
+	// The daemon holds the only copy of the secret key.
+	path := filepath.Join(dir, "signer.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		fmt.Println("[Test_Signer] " + err.Error())
+		return false
+	}
+	defer listener.Close()
+
+	daemon_key := new_user()
+	go photoproof.Serve_Signer(listener, photoproof.LocalSigner{SecretKey: daemon_key.SecretKey})
+
+	socket, err := photoproof.NewSocketSigner(path)
+	if err != nil {
+		fmt.Println("[Test_Signer] Error while connecting to the signing daemon: " + err.Error())
+		return false
+	}
+
+	cam := camera.Camera{Admin: photoproof.NewUser_From_Signer(socket)}
+	if cam.Admin.SecretKey != nil {
+		fmt.Println("[Test_Signer] FAILED the camera holds a secret key")
+		return false
+	}
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Signer] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	// The photo must verify under the daemon's public key.
+	viewer := new_user()
+	verifier_keys := photoproof.VerifierKeys{Original_PublicKey: daemon_key.PublicKey}
+	ok, err := viewer.Verify(verifier_keys, photo.Z, photo.Proof)
+	if !ok || err != nil {
+		fmt.Printf("[Test_Signer] FAILED photo signed by the daemon was rejected (err: %v)\n", err)
+		fmt.Println("********Test_Signer FAILED********")
+		return false
+	}
+
+	fmt.Println("********Test_Signer was successful!********")
+	return true
+}
@@ -0,0 +1,67 @@
+package example
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks the signed capture time of original photographs against a verifier's TimePolicy.
+Tampering with the capture time itself is covered by Test_Tampering.
+*/
+func Test_Timestamp() bool {
+	cam := camera.Camera{Admin: new_user()}
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Timestamp] Error while taking a photograph: " + err.Error())
+		return false
+	}
+
+	second, err := cam.TakePhotograph("random")
+	if err != nil {
+		fmt.Println("[Test_Timestamp] Error while taking a photograph: " + err.Error())
+		return false
+	}
+	if second.Z.Counter <= photo.Z.Counter {
+		fmt.Println("[Test_Timestamp] FAILED the counter is not monotonic")
+		return false
+	}
+
+	viewer := new_user()
+	now := time.Now()
+	passed := true
+
+	for _, tc := range []struct {
+		name      string
+		policy    photoproof.TimePolicy
+		expect_ok bool
+	}{
+		{"no policy", photoproof.TimePolicy{}, true},
+		{"taken within the window", photoproof.TimePolicy{NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)}, true},
+		{"taken before the window", photoproof.TimePolicy{NotBefore: now.Add(time.Hour)}, false},
+		{"taken after the window", photoproof.TimePolicy{NotAfter: now.Add(-time.Hour)}, false},
+		{"recent enough", photoproof.TimePolicy{MaxAge: time.Hour}, true},
+	} {
+		policy := tc.policy
+		verifier_keys := photoproof.VerifierKeys{Original_PublicKey: cam.Admin.PublicKey, TimePolicy: &policy}
+
+		ok, err := viewer.Verify(verifier_keys, photo.Z, photo.Proof)
+		if (ok && err == nil) != tc.expect_ok {
+			fmt.Printf("[Test_Timestamp] FAILED %s: accepted=%t (err: %v)\n", tc.name, ok && err == nil, err)
+			passed = false
+		} else {
+			fmt.Printf("[Test_Timestamp] ok %s (err: %v)\n", tc.name, err)
+		}
+	}
+
+	if passed {
+		fmt.Println("********Test_Timestamp was successful!********")
+	} else {
+		fmt.Println("********Test_Timestamp FAILED********")
+	}
+
+	return passed
+}
@@ -0,0 +1,105 @@
+package example
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+This file checks that a verifier with a TrustStore accepts original photographs
+from every valid camera of the fleet, and rejects revoked, expired and unknown cameras.
+Original photographs carry no PCD proof, so no setup is needed.
+*/
+func Test_Trust_Store() bool {
+	store := photoproof.NewTrustStore()
+	now := time.Now()
+
+	// A fleet of cameras, each with its own admin key.
+	cameras := map[string]*camera.Camera{}
+	for _, device_id := range []string{"valid", "revoked", "expired", "not-yet-valid", "unknown"} {
+		cameras[device_id] = &camera.Camera{Admin: new_user()}
+
+		key := photoproof.CameraKey{
+			DeviceID:  device_id,
+			PublicKey: cameras[device_id].Admin.PublicKey,
+			NotBefore: now.Add(-time.Hour),
+			NotAfter:  now.Add(time.Hour),
+		}
+
+		switch device_id {
+		case "expired":
+			key.NotAfter = now.Add(-time.Minute)
+		case "not-yet-valid":
+			key.NotBefore = now.Add(time.Minute)
+		case "unknown":
+			continue
+		}
+
+		store.Add(key)
+	}
+
+	if err := store.Revoke("revoked"); err != nil {
+		fmt.Println("[Test_Trust_Store] " + err.Error())
+		return false
+	}
+
+	verifier_keys := photoproof.VerifierKeys{TrustStore: store}
+	viewer := new_user()
+	passed := true
+
+	for device_id, cam := range cameras {
+		photo, err := cam.TakePhotograph("random")
+		if err != nil {
+			fmt.Println("[Test_Trust_Store] Error while taking a photograph: " + err.Error())
+			return false
+		}
+
+		ok, err := viewer.Verify(verifier_keys, photo.Z, photo.Proof)
+		accepted := ok && err == nil
+		if accepted != (device_id == "valid") {
+			fmt.Printf("[Test_Trust_Store] FAILED %s: accepted=%t (err: %v)\n", device_id, accepted, err)
+			passed = false
+		} else {
+			fmt.Printf("[Test_Trust_Store] ok %s (err: %v)\n", device_id, err)
+		}
+	}
+
+	// The store must survive a round trip through its file, with its revoked certificates.
+	store.Revoke_Certificate(7)
+	path := filepath.Join(os.TempDir(), "photognark_trust_store.json")
+	defer os.Remove(path)
+	if err := store.Save(path); err != nil {
+		fmt.Println("[Test_Trust_Store] " + err.Error())
+		return false
+	}
+	loaded, err := photoproof.LoadTrustStore(path)
+	if err != nil {
+		fmt.Println("[Test_Trust_Store] " + err.Error())
+		return false
+	}
+	if !loaded.Revoked_Certificates[7] {
+		fmt.Println("[Test_Trust_Store] FAILED the revoked certificate was lost by the store file")
+		passed = false
+	}
+	for device_id, cam := range cameras {
+		_, err := loaded.Lookup(cam.Admin.PublicKey, now)
+		_, expected := store.Lookup(cam.Admin.PublicKey, now)
+		if (err == nil) != (expected == nil) {
+			fmt.Printf("[Test_Trust_Store] FAILED %s after loading the store: %v, expected %v\n", device_id, err, expected)
+			passed = false
+		}
+	}
+
+	if passed {
+		fmt.Println("********Test_Trust_Store was successful!********")
+	} else {
+		fmt.Println("********Test_Trust_Store FAILED********")
+	}
+
+	return passed
+}
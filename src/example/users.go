@@ -0,0 +1,21 @@
+package example
+
+import "github.com/drakstik/Photognark_V3/src/photoproof"
+
+// A new User for the examples, which have nothing to do but stop if no key can be generated.
+func new_user() photoproof.User {
+	user, err := photoproof.NewUser()
+	if err != nil {
+		panic(err)
+	}
+	return user
+}
+
+// A new Manufacturer for the examples, see new_user().
+func new_manufacturer() *photoproof.Manufacturer {
+	manufacturer, err := photoproof.NewManufacturer()
+	if err != nil {
+		panic(err)
+	}
+	return manufacturer
+}
@@ -2,6 +2,7 @@ package image
 
 import (
 	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash"
 	"github.com/consensys/gnark/std/hash/mimc"
 	"github.com/consensys/gnark/std/signature/eddsa"
 )
@@ -26,12 +27,14 @@ type Fr_Pixel struct {
 
 // An Fr_Image is an image that is gnark-friendly.
 type Fr_Image struct {
-	Pxls [N2]Fr_Pixel `gnark:",inherit"`
+	Pxls          [N2]Fr_Pixel  `gnark:",inherit"`
+	Hash_Function Hash_Function `gnark:"-"` // Compiled into the circuit, see Permissible_Transformations
 }
 
 // Hash function for an Fr_Image.
 // This function must have mirror output to the Image.Hash() function.
-func (img Fr_Image) Hash(api frontend.API) (frontend.Variable, mimc.MiMC) {
+// The hasher is returned for the signature of the image, see photoproof.Verify_Signature().
+func (img Fr_Image) Hash(api frontend.API) (frontend.Variable, hash.FieldHasher) {
 	data := make([]frontend.Variable, 0, N2*5) // New frontend.Variable slice; each pixel: 3 RGB + row + col
 	for i := 0; i < int(N2); i++ {
 		px := img.Pxls[i]
@@ -41,9 +44,9 @@ func (img Fr_Image) Hash(api frontend.API) (frontend.Variable, mimc.MiMC) {
 		)
 	}
 
-	// Hash the serialized z.Img (Use MiMC).
-	h, _ := mimc.NewMiMC(api)
-	h.Write(data)
+	// Hash the serialized z.Img with img.Hash_Function
+	h, _ := img.Hash_Function.Hasher(api)
+	h.Write(data...)
 	digest := h.Sum()
 	return digest, h
 }
@@ -55,6 +58,41 @@ type Fr_Z struct {
 	// Original signature and hash
 	OriginalSignature eddsa.Signature
 	OriginalHash      frontend.Variable
+	// Capture time and counter, signed with the original hash
+	Timestamp frontend.Variable
+	Counter   frontend.Variable
+	// Commitment to the capture metadata, signed with the original hash
+	MetadataCommitment frontend.Variable
+	// Edits since the original
+	Provenance Fr_Provenance
+	// Key of the editor who signed the last edit, and the MiMC chain of every editor's key
+	Editor  eddsa.PublicKey
+	Editors frontend.Variable
+}
+
+// Gnark-friendly Provenance.
+type Fr_Provenance struct {
+	Edits      frontend.Variable
+	Uses       [Provenance_Transformations]frontend.Variable
+	Brightness frontend.Variable
+}
+
+// The digest signed by the camera: MiMC(OriginalHash, Timestamp, Counter, MetadataCommitment).
+// This function must have mirror output to the Z.Original_Digest() function.
+func (z Fr_Z) Original_Digest(api frontend.API) frontend.Variable {
+	h, _ := mimc.NewMiMC(api)
+	h.Write(z.OriginalHash, z.Timestamp, z.Counter, z.MetadataCommitment)
+	return h.Sum()
+}
+
+// The digest signed by the editor of an edit, with digest the hash of z.Img: MiMC(digest, Original_Digest, Provenance, Editors).
+// This function must have mirror output to the Z.Edit_Digest() function.
+func (z Fr_Z) Edit_Digest(api frontend.API, digest frontend.Variable) frontend.Variable {
+	h, _ := mimc.NewMiMC(api)
+	h.Write(digest, z.Original_Digest(api), z.Provenance.Edits)
+	h.Write(z.Provenance.Uses[:]...)
+	h.Write(z.Provenance.Brightness, z.Editors)
+	return h.Sum()
 }
 
 /*------------------------------------------ Gnark-Friendly Area --------------------------------------*/
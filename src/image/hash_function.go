@@ -0,0 +1,99 @@
+package image
+
+import (
+	"fmt"
+	"hash"
+
+	gchash "github.com/consensys/gnark-crypto/hash"
+	"github.com/consensys/gnark/frontend"
+	stdhash "github.com/consensys/gnark/std/hash"
+	"github.com/consensys/gnark/std/hash/mimc"
+	"github.com/consensys/gnark/std/permutation/poseidon2"
+
+	_ "github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	_ "github.com/consensys/gnark-crypto/ecc/bn254/fr/poseidon2"
+)
+
+/*
+The hash of image digests, and of the EdDSA signatures of images (H(R, A, M)), chosen when the keys are
+generated and recorded in the keys and in every Image. The zero value is MiMC, the hash of earlier keys
+and photos. The commitments to the capture metadata, the original digest, the editors and the key set
+are always MiMC.
+*/
+type Hash_Function uint8
+
+const (
+	MiMC      Hash_Function = iota // MiMC BN254
+	Poseidon2                      // Poseidon2 BN254, Merkle-Damgard with the default parameters of gnark-crypto
+)
+
+var hash_function_names = [...]string{MiMC: "mimc", Poseidon2: "poseidon2"}
+
+// The Poseidon2 parameters of gnark-crypto's bn254 poseidon2.GetDefaultParameters(), which gnark's
+// NewPoseidon2() does not have for BN254.
+const (
+	poseidon2_width          = 2
+	poseidon2_full_rounds    = 6
+	poseidon2_partial_rounds = 50
+)
+
+func (h Hash_Function) String() string {
+	if int(h) < len(hash_function_names) {
+		return hash_function_names[h]
+	}
+	return fmt.Sprintf("Hash_Function(%d)", uint8(h))
+}
+
+// The hash function of a name, i.e. the output of String(). An empty name is MiMC.
+func Parse_Hash_Function(name string) (Hash_Function, error) {
+	if name == "" {
+		return MiMC, nil
+	}
+	for h, h_name := range hash_function_names {
+		if name == h_name {
+			return Hash_Function(h), nil
+		}
+	}
+	return MiMC, fmt.Errorf("unknown hash function %q", name)
+}
+
+func (h Hash_Function) MarshalText() ([]byte, error) {
+	if int(h) >= len(hash_function_names) {
+		return nil, fmt.Errorf("unknown hash function %d", uint8(h))
+	}
+	return []byte(h.String()), nil
+}
+
+func (h *Hash_Function) UnmarshalText(text []byte) error {
+	parsed, err := Parse_Hash_Function(string(text))
+	if err != nil {
+		return err
+	}
+	*h = parsed
+	return nil
+}
+
+// A new out-of-circuit hasher, e.g. for signing with a signature.Signer.
+func (h Hash_Function) New() hash.Hash {
+	if h == Poseidon2 {
+		return gchash.POSEIDON2_BN254.New()
+	}
+	return gchash.MIMC_BN254.New()
+}
+
+// A new in-circuit hasher, which must have mirror output to New().
+func (h Hash_Function) Hasher(api frontend.API) (stdhash.FieldHasher, error) {
+	if h == Poseidon2 {
+		permutation, err := poseidon2.NewPoseidon2FromParameters(api, poseidon2_width, poseidon2_full_rounds, poseidon2_partial_rounds)
+		if err != nil {
+			return nil, err
+		}
+		return stdhash.NewMerkleDamgardHasher(api, permutation, 0), nil
+	}
+
+	h_mimc, err := mimc.NewMiMC(api)
+	if err != nil {
+		return nil, err
+	}
+	return &h_mimc, nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
 	"github.com/consensys/gnark-crypto/signature"
 	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
 	"github.com/consensys/gnark/std/signature/eddsa"
 )
 
@@ -45,15 +46,16 @@ func (pxls Pixel) ToFr() Fr_Pixel {
 /*-------------------------------------------- Image Construction -------------------------------------------*/
 // An image
 type Image struct {
-	Pxls [N2]Pixel // Array
+	Pxls          [N2]Pixel     // Array
+	Hash_Function Hash_Function // Of Hash() and of the signatures of the image, the one of the keys that prove it
 }
 
-// Write Image's values (RGB & Location) into a hash.StateStorer and return its hash.
+// Write Image's values (RGB & Location) into a hash.StateStorer and return its hash, with img.Hash_Function.
 // Each value is set as the z-value of a field element, and appended to the hash.StateStorer as a
 // big-endian slice representation of the z-value.
 func (img Image) Hash() []byte {
 
-	msg := mimc.NewMiMC()
+	msg := img.Hash_Function.New()
 
 	var fr fr.Element // New field element
 	absorb := func(u uint64) {
@@ -78,7 +80,8 @@ func (img Image) Hash() []byte {
 func (img Image) ToFr() Fr_Image {
 	// Create new Fr_Image
 	fr_image := Fr_Image{
-		Pxls: [N2]Fr_Pixel{},
+		Pxls:          [N2]Fr_Pixel{},
+		Hash_Function: img.Hash_Function,
 	}
 
 	// For each index i, set fr_image[i] to a Fr version of the pixel in img[i]
@@ -107,14 +110,88 @@ func (img Image) PrintImage() {
 	}
 }
 
+/*-------------------------------------------- Provenance Construction -------------------------------------------*/
+
+// Number of transformations counted in Provenance.Uses, indexed as in photoproof.Transformation_Index().
+const Provenance_Transformations = 2
+
+// The edits that led to an image, incremented by each proof and bounded by the admin, see photoproof.ProvenanceBounds.
+type Provenance struct {
+	Edits      uint64                             // Number of transformations applied since the original
+	Uses       [Provenance_Transformations]uint64 // Number of times each transformation was applied
+	Brightness uint64                             // Cumulative absolute brightness shift, in intensity levels (0-255 per edit)
+}
+
+func (provenance Provenance) ToFr() Fr_Provenance {
+	fr_provenance := Fr_Provenance{
+		Edits:      frontend.Variable(provenance.Edits),
+		Brightness: frontend.Variable(provenance.Brightness),
+	}
+	for i, uses := range provenance.Uses {
+		fr_provenance.Uses[i] = frontend.Variable(uses)
+	}
+	return fr_provenance
+}
+
 /*-------------------------------------------- Z Construction -------------------------------------------*/
 // Z = (Image, Public Key)
 type Z struct {
 	Img       Image
 	PublicKey signature.PublicKey
 	// Original signature and hash
-	OriginalSignature []byte
+	OriginalSignature []byte // Signature of the camera over Original_Digest()
 	OriginalHash      []byte
+	// Capture time (unix seconds) and the camera's monotonic photo counter, signed with the original hash
+	Timestamp uint64
+	Counter   uint64
+	// Commitment to the capture metadata, signed with the original hash, see Metadata_Opening
+	MetadataCommitment []byte
+	// Edits since the original, zero for an original image
+	Provenance Provenance
+	// Key of the editor who signed the last edit, and the chain of every editor's key, see photoproof.Next_Editors().
+	// Both are nil for an original image.
+	Editor  signature.PublicKey
+	Editors []byte
+}
+
+// The digest signed by the camera: MiMC(OriginalHash, Timestamp, Counter, MetadataCommitment).
+// This function must have mirror output to the Fr_Z.Original_Digest() function.
+func (z Z) Original_Digest() []byte {
+	msg := mimc.NewMiMC()
+
+	var fr fr.Element
+	fr.SetBytes(z.OriginalHash)
+	msg.Write(fr.Marshal())
+	fr.SetUint64(z.Timestamp)
+	msg.Write(fr.Marshal())
+	fr.SetUint64(z.Counter)
+	msg.Write(fr.Marshal())
+	fr.SetBytes(z.MetadataCommitment)
+	msg.Write(fr.Marshal())
+
+	return msg.Sum(nil)
+}
+
+// The digest signed by the editor of an edit: MiMC(image digest, Original_Digest, Provenance, Editors), so that
+// the next edit can check the provenance and editors of its input against the signature of its editor.
+// This function must have mirror output to the Fr_Z.Edit_Digest() function.
+func (z Z) Edit_Digest() []byte {
+	msg := mimc.NewMiMC()
+
+	var fr fr.Element
+	fr.SetBytes(z.Img.Hash())
+	msg.Write(fr.Marshal())
+	fr.SetBytes(z.Original_Digest())
+	msg.Write(fr.Marshal())
+	counts := append([]uint64{z.Provenance.Edits}, z.Provenance.Uses[:]...)
+	for _, count := range append(counts, z.Provenance.Brightness) {
+		fr.SetUint64(count)
+		msg.Write(fr.Marshal())
+	}
+	fr.SetBytes(z.Editors)
+	msg.Write(fr.Marshal())
+
+	return msg.Sum(nil)
 }
 
 func (z Z) ToFr() Fr_Z {
@@ -125,14 +202,41 @@ func (z Z) ToFr() Fr_Z {
 	eddsa_digSig.Assign(1, z.OriginalSignature)
 	eddsa_PK.Assign(1, z.PublicKey.Bytes())
 
+	// An original image has no editor
+	eddsa_editor := eddsa.PublicKey{A: twistededwards.Point{X: 0, Y: 0}}
+	if z.Editor != nil {
+		eddsa_editor.Assign(1, z.Editor.Bytes())
+	}
+
 	return Fr_Z{
 		Img:               z.Img.ToFr(),
 		PublicKey:         eddsa_PK,
 		OriginalSignature: eddsa_digSig,
 		OriginalHash:      frontend.Variable(z.OriginalHash),
+		Timestamp:         frontend.Variable(z.Timestamp),
+		Counter:           frontend.Variable(z.Counter),
+
+		MetadataCommitment: frontend.Variable(z.MetadataCommitment),
+		Provenance:         z.Provenance.ToFr(),
+
+		Editor:  eddsa_editor,
+		Editors: frontend.Variable(z.Editors),
 	}
 }
 
+// A copy of z without the values that identify the camera, for publishing an anonymous photo.
+func (z Z) Anonymous() Z {
+	z.PublicKey = nil
+	z.OriginalSignature = nil
+	z.Counter = 0
+	return z
+}
+
+// A copy of z with only the image and public key, for publishing a private photo that does not link back to its original.
+func (z Z) Private() Z {
+	return Z{Img: z.Img, PublicKey: z.PublicKey}
+}
+
 /*----------------------------------------------- Area Construction -------------------------------------*/
 // Represents an area inside an image.
 type Area struct {
@@ -0,0 +1,261 @@
+package image
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+)
+
+/*
+Capture metadata is committed with the original hash and signed by the camera, see Z.Original_Digest().
+Each field is hidden behind its own salted leaf, MiMC(Salt, Value), and the commitment is the MiMC of all
+leaves in Metadata_Fields order. A Disclosure opens some fields and only gives the leaf of the others,
+so that a viewer can check the disclosed fields without learning the hidden ones.
+*/
+
+// Names of the metadata fields, in commitment order.
+var Metadata_Fields = [...]string{"latitude", "longitude", "device_id", "lens"}
+
+const Metadata_Size = len(Metadata_Fields)
+
+// Capture metadata of a photograph.
+type Metadata struct {
+	Latitude  float64 // Degrees, kept to 6 decimals
+	Longitude float64 // Degrees, kept to 6 decimals
+	DeviceID  string
+	Lens      string
+}
+
+// The canonical string of each field, in Metadata_Fields order.
+func (metadata Metadata) Values() [Metadata_Size]string {
+	return [Metadata_Size]string{
+		strconv.FormatFloat(metadata.Latitude, 'f', 6, 64),
+		strconv.FormatFloat(metadata.Longitude, 'f', 6, 64),
+		metadata.DeviceID,
+		metadata.Lens,
+	}
+}
+
+// Location fields are committed as numbers, so that the circuit can check range predicates over them.
+// A coordinate in degrees is committed as its microdegrees, shifted by Location_Offset to be non-negative.
+const Location_Offset = 180_000_000
+
+func Microdegrees(degrees float64) uint64 {
+	return uint64(math.Round(degrees*1e6) + Location_Offset)
+}
+
+// The field element of a field value: the microdegrees of a location, else the SHA256 of its name and value,
+// reduced into the field.
+func metadata_value(name string, value string) (fr.Element, error) {
+	var element fr.Element
+
+	switch name {
+	case "latitude", "longitude":
+		degrees, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fr.Element{}, fmt.Errorf("metadata field %q is not a number", name)
+		}
+		element.SetUint64(Microdegrees(degrees))
+	default:
+		sum := sha256.Sum256([]byte(name + "=" + value))
+		element.SetBytes(sum[:])
+	}
+
+	return element, nil
+}
+
+// The leaf of a field: MiMC(Salt, Value).
+// This function must have mirror output to the leaves computed in photoproof.Check_Predicate().
+func metadata_leaf(name string, value string, salt []byte) ([]byte, error) {
+	msg := mimc.NewMiMC()
+
+	var element fr.Element
+	element.SetBytes(salt)
+	msg.Write(element.Marshal())
+
+	element, err := metadata_value(name, value)
+	if err != nil {
+		return nil, err
+	}
+	msg.Write(element.Marshal())
+
+	return msg.Sum(nil), nil
+}
+
+// The commitment of the leaves: MiMC(leaf_0, ..., leaf_n).
+func metadata_commitment(leaves [Metadata_Size][]byte) ([]byte, error) {
+	msg := mimc.NewMiMC()
+	for i, leaf := range leaves {
+		if _, err := msg.Write(leaf); err != nil {
+			return nil, fmt.Errorf("metadata leaf %d: %w", i, err)
+		}
+	}
+	return msg.Sum(nil), nil
+}
+
+// A leaf from outside the camera must be one canonical field element: MiMC would otherwise panic
+// on a length that is not a multiple of fr.Bytes, or skip a non-canonical leaf in the commitment.
+func check_leaf(name string, leaf []byte) error {
+	if len(leaf) != fr.Bytes {
+		return fmt.Errorf("hidden metadata field %q has a leaf of %d bytes, expected %d", name, len(leaf), fr.Bytes)
+	}
+	var element fr.Element
+	if err := element.SetBytesCanonical(leaf); err != nil {
+		return fmt.Errorf("hidden metadata field %q has a non-canonical leaf: %w", name, err)
+	}
+	return nil
+}
+
+/*-------------------------------------------- Opening (kept by the camera) -------------------------------------------*/
+
+// The metadata with the salts of its leaves; whoever holds it can open any field.
+type Metadata_Opening struct {
+	Metadata Metadata
+	Salts    [Metadata_Size][]byte
+}
+
+// Salt metadata with fresh randomness.
+func NewMetadata_Opening(metadata Metadata) (Metadata_Opening, error) {
+	if math.Abs(metadata.Latitude) > 90 || math.Abs(metadata.Longitude) > 180 {
+		return Metadata_Opening{}, fmt.Errorf("location (%f, %f) is out of range", metadata.Latitude, metadata.Longitude)
+	}
+
+	opening := Metadata_Opening{Metadata: metadata}
+
+	for i := range opening.Salts {
+		var salt fr.Element
+		if _, err := salt.SetRandom(); err != nil {
+			return Metadata_Opening{}, err
+		}
+		opening.Salts[i] = salt.Marshal()
+	}
+
+	return opening, nil
+}
+
+// The leaves of the commitment, in Metadata_Fields order.
+func (opening Metadata_Opening) Leaves() ([Metadata_Size][]byte, error) {
+	var leaves [Metadata_Size][]byte
+	for i, value := range opening.Metadata.Values() {
+		leaf, err := metadata_leaf(Metadata_Fields[i], value, opening.Salts[i])
+		if err != nil {
+			return [Metadata_Size][]byte{}, err
+		}
+		leaves[i] = leaf
+	}
+	return leaves, nil
+}
+
+// The commitment that the camera signs, see Z.MetadataCommitment.
+func (opening Metadata_Opening) Commitment() ([]byte, error) {
+	leaves, err := opening.Leaves()
+	if err != nil {
+		return nil, err
+	}
+	return metadata_commitment(leaves)
+}
+
+// Disclose the named fields only; the other fields are reduced to their leaf.
+func (opening Metadata_Opening) Disclose(fields ...string) (Disclosure, error) {
+	leaves, err := opening.Leaves()
+	if err != nil {
+		return Disclosure{}, err
+	}
+	values := opening.Metadata.Values()
+
+	var disclosure Disclosure
+	for i, name := range Metadata_Fields {
+		disclosure.Fields[i] = Disclosed_Field{Name: name, Leaf: leaves[i]}
+	}
+
+	for _, name := range fields {
+		i := metadata_index(name)
+		if i < 0 {
+			return Disclosure{}, fmt.Errorf("unknown metadata field %q", name)
+		}
+		disclosure.Fields[i].Disclosed = true
+		disclosure.Fields[i].Value = values[i]
+		disclosure.Fields[i].Salt = opening.Salts[i]
+		disclosure.Fields[i].Leaf = nil
+	}
+
+	return disclosure, nil
+}
+
+func metadata_index(name string) int {
+	for i, field := range Metadata_Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+/*-------------------------------------------- Disclosure (shown to a viewer) -------------------------------------------*/
+
+// A field of a Disclosure: either its Value and Salt, or only its Leaf.
+type Disclosed_Field struct {
+	Name      string
+	Disclosed bool
+	Value     string
+	Salt      []byte
+	Leaf      []byte
+}
+
+type Disclosure struct {
+	Fields [Metadata_Size]Disclosed_Field
+}
+
+// Check the disclosure against the signed commitment of a photo.
+func (disclosure Disclosure) Verify(commitment []byte) error {
+	var leaves [Metadata_Size][]byte
+
+	for i, field := range disclosure.Fields {
+		if field.Name != Metadata_Fields[i] {
+			return fmt.Errorf("metadata field %d is %q, expected %q", i, field.Name, Metadata_Fields[i])
+		}
+
+		if field.Disclosed {
+			leaf, err := metadata_leaf(field.Name, field.Value, field.Salt)
+			if err != nil {
+				return err
+			}
+			leaves[i] = leaf
+		} else {
+			if len(field.Leaf) == 0 {
+				return fmt.Errorf("hidden metadata field %q has no leaf", field.Name)
+			}
+			if err := check_leaf(field.Name, field.Leaf); err != nil {
+				return err
+			}
+			leaves[i] = field.Leaf
+		}
+	}
+
+	computed, err := metadata_commitment(leaves)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(computed, commitment) {
+		return errors.New("metadata disclosure does not match the commitment")
+	}
+
+	return nil
+}
+
+// The disclosed fields, by name.
+func (disclosure Disclosure) Values() map[string]string {
+	values := map[string]string{}
+	for _, field := range disclosure.Fields {
+		if field.Disclosed {
+			values[field.Name] = field.Value
+		}
+	}
+	return values
+}
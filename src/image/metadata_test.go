@@ -0,0 +1,45 @@
+package image
+
+import (
+	"bytes"
+	"testing"
+)
+
+// A disclosure comes from an untrusted container: a malformed hidden leaf must be an error, not a panic
+// of MiMC, nor a leaf silently left out of the commitment.
+func TestDisclosureLeaves(t *testing.T) {
+	opening, err := NewMetadata_Opening(Metadata{Latitude: 40.712776, Longitude: -74.005974, DeviceID: "camera-0001", Lens: "35mm f/1.8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitment, err := opening.Commitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	disclosure, err := opening.Disclose("device_id", "lens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := disclosure.Verify(commitment); err != nil {
+		t.Fatalf("honest disclosure was rejected: %v", err)
+	}
+
+	leaf := disclosure.Fields[0].Leaf
+	for _, tc := range []struct {
+		name string
+		leaf []byte
+	}{
+		{"33 bytes", append(bytes.Clone(leaf), 0)},
+		{"two leaves", append(bytes.Clone(leaf), leaf...)},
+		{"31 bytes", leaf[1:]},
+		{"not canonical", bytes.Repeat([]byte{0xff}, len(leaf))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			forged := disclosure
+			forged.Fields[0].Leaf = tc.leaf
+			if err := forged.Verify(commitment); err == nil {
+				t.Error("malformed leaf was accepted")
+			}
+		})
+	}
+}
@@ -1,10 +1,547 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/consensys/gnark/logger"
+
+	"github.com/drakstik/Photognark_V3/src/bench"
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/ceremony"
 	"github.com/drakstik/Photognark_V3/src/example"
+	"github.com/drakstik/Photognark_V3/src/image"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+	"github.com/drakstik/Photognark_V3/src/server"
 )
 
 func main() {
+	if len(os.Args) < 2 {
+		example.Test_Partial_Knowledge(true, true)
+		return
+	}
+
+	switch os.Args[1] {
+	case "trust-store":
+		if !example.Test_Trust_Store() {
+			os.Exit(1)
+		}
+	case "anonymous":
+		if !example.Test_Anonymous() {
+			os.Exit(1)
+		}
+	case "certificate":
+		if !example.Test_Certificate() {
+			os.Exit(1)
+		}
+	case "keystore":
+		if !example.Test_Keystore() {
+			os.Exit(1)
+		}
+	case "signer":
+		if err := run_signer(os.Args[2:]); err != nil {
+			fmt.Println("[signer] " + err.Error())
+			os.Exit(1)
+		}
+	case "timestamp":
+		if !example.Test_Timestamp() {
+			os.Exit(1)
+		}
+	case "metadata":
+		if !example.Test_Metadata() {
+			os.Exit(1)
+		}
+	case "predicate":
+		if !example.Test_Predicate() {
+			os.Exit(1)
+		}
+	case "private":
+		if !example.Test_Private() {
+			os.Exit(1)
+		}
+	case "provenance":
+		if !example.Test_Provenance() {
+			os.Exit(1)
+		}
+	case "policy":
+		if !example.Test_Policy() {
+			os.Exit(1)
+		}
+	case "history":
+		if !example.Test_History() {
+			os.Exit(1)
+		}
+	case "editors":
+		if !example.Test_Editors() {
+			os.Exit(1)
+		}
+	case "batch":
+		if !example.Test_Batch() {
+			os.Exit(1)
+		}
+	case "pool":
+		if !example.Test_Pool() {
+			os.Exit(1)
+		}
+	case "context":
+		if !example.Test_Context() {
+			os.Exit(1)
+		}
+	case "errors":
+		if !example.Test_Errors() {
+			os.Exit(1)
+		}
+	case "poseidon2":
+		if !example.Test_Poseidon2() {
+			os.Exit(1)
+		}
+	case "serve":
+		if err := run_serve(os.Args[2:]); err != nil {
+			fmt.Println("[serve] " + err.Error())
+			os.Exit(1)
+		}
+	case "serve-prover":
+		if err := run_serve_prover(os.Args[2:]); err != nil {
+			fmt.Println("[serve-prover] " + err.Error())
+			os.Exit(1)
+		}
+	case "bench":
+		if err := run_bench(os.Args[2:]); err != nil {
+			fmt.Println("[bench] " + err.Error())
+			os.Exit(1)
+		}
+	case "profile":
+		if err := run_profile(os.Args[2:]); err != nil {
+			fmt.Println("[profile] " + err.Error())
+			os.Exit(1)
+		}
+	case "rotate":
+		if err := run_rotate(os.Args[2:]); err != nil {
+			fmt.Println("[rotate] " + err.Error())
+			os.Exit(1)
+		}
+	case "ceremony":
+		if err := run_ceremony(os.Args[2:]); err != nil {
+			fmt.Println("[ceremony] " + err.Error())
+			os.Exit(1)
+		}
+	default:
+		test, ok := tagged_examples[os.Args[1]]
+		if !ok {
+			fmt.Println("unknown command: " + os.Args[1])
+			os.Exit(2)
+		}
+		if !test() {
+			os.Exit(1)
+		}
+	}
+}
+
+// Examples that only exist in builds with a tag, added by the tagged files of package main.
+var tagged_examples = map[string]func() bool{}
+
+// Multi-party trusted setup of the Permissible_Transformations keys. Participants take turns running:
+//
+//	ceremony contribute-phase1 <dir>
+//	ceremony seal-phase1 <dir> <beacon>
+//	ceremony contribute <dir>
+//	ceremony verify <dir> <contribution>
+//	ceremony finalize <dir> <beacon>
+//	ceremony simulate <dir> <participants>
+func run_ceremony(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: ceremony <contribute-phase1|seal-phase1|contribute|verify|finalize|simulate> <dir> [arg]")
+	}
+	cmd, dir := args[0], args[1]
+
+	arg := func() (string, error) {
+		if len(args) < 3 {
+			return "", fmt.Errorf("%s needs one more argument", cmd)
+		}
+		return args[2], nil
+	}
+
+	if cmd == "simulate" {
+		s, err := arg()
+		if err != nil {
+			return err
+		}
+		participants, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		if !example.Test_Ceremony(dir, participants) {
+			return fmt.Errorf("simulation failed")
+		}
+		return nil
+	}
+
+	// The circuit of serve-prover: with the policy of dir, if any
+	circuit := photoproof.Permissible_Transformations{}
+	policy_path := filepath.Join(dir, "policy.json")
+	if _, err := os.Stat(policy_path); err == nil {
+		policy, err := photoproof.LoadPolicy(policy_path)
+		if err != nil {
+			return err
+		}
+		if err := policy.Apply(&circuit); err != nil {
+			return err
+		}
+	}
+	c, err := ceremony.New(dir, &circuit)
+	if err != nil {
+		return err
+	}
+
+	switch cmd {
+	case "contribute-phase1", "contribute":
+		contribute := c.Contribute
+		if cmd == "contribute-phase1" {
+			contribute = c.Contribute_Phase1
+		}
+
+		path, err := contribute()
+		if err != nil {
+			return err
+		}
+		hash, err := ceremony.Hash_File(path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("wrote %s, publish its hash: %x\n", path, hash)
+	case "seal-phase1":
+		beacon, err := arg()
+		if err != nil {
+			return err
+		}
+		return c.Seal_Phase1([]byte(beacon))
+	case "verify":
+		s, err := arg()
+		if err != nil {
+			return err
+		}
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		if err := c.Verify_Contribution(i); err != nil {
+			return err
+		}
+		fmt.Printf("contribution %d is valid\n", i)
+	case "finalize":
+		beacon, err := arg()
+		if err != nil {
+			return err
+		}
+		if _, _, err := c.Finalize([]byte(beacon)); err != nil {
+			return err
+		}
+		fmt.Println("wrote " + ceremony.Proving_Key_File + " and " + ceremony.Verifying_Key_File)
+	default:
+		return fmt.Errorf("unknown ceremony command: %s", cmd)
+	}
+
+	return nil
+}
+
+// Rotation of a camera key: the manufacturer certifies a new key, see camera.Camera.Rotate().
+//
+//	rotate new-manufacturer <manufacturer-dir>
+//	rotate <camera-dir> <manufacturer-dir> <device-id> [validity]    (the validity defaults to 8760h)
+//	rotate test
+//
+// The keystore passphrases are read from $PHOTOGNARK_PASSPHRASE for the camera key, and from
+// $PHOTOGNARK_MANUFACTURER_PASSPHRASE for the root key. A camera-dir without a key gets its first one.
+func run_rotate(args []string) error {
+	usage := "usage: rotate new-manufacturer <manufacturer-dir> | rotate <camera-dir> <manufacturer-dir> <device-id> [validity] | rotate test"
+	if len(args) == 1 && args[0] == "test" {
+		if !example.Test_Rotate() {
+			return fmt.Errorf("test failed")
+		}
+		return nil
+	}
+	manufacturer_passphrase := []byte(os.Getenv("PHOTOGNARK_MANUFACTURER_PASSPHRASE"))
+
+	if len(args) == 2 && args[0] == "new-manufacturer" {
+		if _, err := os.Stat(filepath.Join(args[1], photoproof.Root_Key_File)); err == nil {
+			return fmt.Errorf("%s already has a root key", args[1])
+		}
+		manufacturer, err := photoproof.NewManufacturer()
+		if err != nil {
+			return err
+		}
+		if err := manufacturer.Save(args[1], manufacturer_passphrase); err != nil {
+			return err
+		}
+		fmt.Printf("wrote the root key %x to %s\n", manufacturer.Root.PublicKey.Bytes(), args[1])
+		return nil
+	}
+
+	if len(args) < 3 || len(args) > 4 {
+		return fmt.Errorf("%s", usage)
+	}
+	camera_dir, manufacturer_dir, device_id := args[0], args[1], args[2]
+	validity := 365 * 24 * time.Hour
+	if len(args) == 4 {
+		var err error
+		if validity, err = time.ParseDuration(args[3]); err != nil {
+			return fmt.Errorf("%s: %w", usage, err)
+		}
+	}
+	passphrase := []byte(os.Getenv("PHOTOGNARK_PASSPHRASE"))
+
+	manufacturer, err := photoproof.LoadManufacturer(manufacturer_dir, manufacturer_passphrase)
+	if err != nil {
+		return err
+	}
+
+	// The current key must open with the passphrase before it is replaced.
+	cam := camera.Camera{}
+	if _, err := os.Stat(filepath.Join(camera_dir, camera.Camera_Key_File)); err == nil {
+		if err := cam.Load_Identity(camera_dir, passphrase); err != nil {
+			return err
+		}
+	}
+
+	if err := cam.Rotate(manufacturer, device_id, validity); err != nil {
+		return err
+	}
+	// Record the serial before the certificate is used, so that it is never issued twice.
+	if err := manufacturer.Save(manufacturer_dir, manufacturer_passphrase); err != nil {
+		return err
+	}
+	if err := cam.Save_Identity(camera_dir, passphrase); err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote the key %x of %s to %s, with certificate %d valid until %s\n",
+		cam.Admin.PublicKey.Bytes(), device_id, camera_dir, cam.Certificate.Serial, cam.Certificate.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+// Signing daemon, which keeps a User's secret key out of the camera or editor process:
+//
+//	signer serve <socket> <keystore>    (the keystore passphrase is read from $PHOTOGNARK_PASSPHRASE)
+//	signer test
+func run_signer(args []string) error {
+	if len(args) == 1 && args[0] == "test" {
+		if !example.Test_Signer() {
+			return fmt.Errorf("test failed")
+		}
+		return nil
+	}
+
+	if len(args) != 3 || args[0] != "serve" {
+		return fmt.Errorf("usage: signer serve <socket> <keystore> | signer test")
+	}
+
+	user, err := photoproof.LoadUser(args[2], []byte(os.Getenv("PHOTOGNARK_PASSPHRASE")))
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("unix", args[1])
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	fmt.Printf("serving the signer of %x on %s\n", user.PublicKey.Bytes(), args[1])
+	return photoproof.Serve_Signer(listener, photoproof.LocalSigner{SecretKey: user.SecretKey})
+}
+
+// Local HTTP verification service, see package server:
+//
+//	serve <keys-dir> [address]    (one subdirectory of verifier keys per key ID, the address defaults to 127.0.0.1:8080)
+//	serve test
+func run_serve(args []string) error {
+	if len(args) == 1 && args[0] == "test" {
+		if !example.Test_Serve() {
+			return fmt.Errorf("test failed")
+		}
+		return nil
+	}
+
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: serve <keys-dir> [address] | serve test")
+	}
+	address := "127.0.0.1:8080"
+	if len(args) == 2 {
+		address = args[1]
+	}
+
+	s, err := server.Load(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("serving POST /verify with keys %v on %s\n", s.Key_IDs(), address)
+	return http.ListenAndServe(address, s.Handler())
+}
+
+// Local HTTP proving service with asynchronous jobs, see server.Proving_Server:
+//
+//	serve-prover <keys-dir> <jobs-dir> [address]    (keys-dir holds the proving_key.bin of a ceremony, the policy.json
+//	                                                compiled into it if any, and the editors.json of the editors whose
+//	                                                edits are proven; the address defaults to 127.0.0.1:8081)
+//	serve-prover test
+func run_serve_prover(args []string) error {
+	if len(args) == 1 && args[0] == "test" {
+		if !example.Test_Prove_Server() {
+			return fmt.Errorf("test failed")
+		}
+		return nil
+	}
+
+	if len(args) < 2 || len(args) > 3 {
+		return fmt.Errorf("usage: serve-prover <keys-dir> <jobs-dir> [address] | serve-prover test")
+	}
+	address := "127.0.0.1:8081"
+	if len(args) == 3 {
+		address = args[2]
+	}
+
+	provingKey, _, err := ceremony.Load_Keys(args[0])
+	if err != nil {
+		return err
+	}
+	prover := photoproof.ProverKeys{ProvingKey: provingKey}
+
+	policy_path := filepath.Join(args[0], "policy.json")
+	if _, err := os.Stat(policy_path); err == nil {
+		policy, err := photoproof.LoadPolicy(policy_path)
+		if err != nil {
+			return err
+		}
+		var circuit photoproof.Permissible_Transformations
+		if err := policy.Apply(&circuit); err != nil {
+			return err
+		}
+		prover.Bounds, prover.Params, prover.Policy_ID, prover.Hash_Function = circuit.Bounds, circuit.Params, circuit.Policy_ID, circuit.Hash_Function
+	}
+
+	s, err := server.NewProving_Server(filepath.Base(args[0]), prover, args[1])
+	if err != nil {
+		return err
+	}
+
+	// Only the editors of the keys directory are proven, as in its verifier keys.
+	editors_path := filepath.Join(args[0], photoproof.Editors_File)
+	if _, err := os.Stat(editors_path); err == nil {
+		if s.Editors, err = photoproof.LoadTrustStore(editors_path); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("no %s in %s: only originals are converted\n", photoproof.Editors_File, args[0])
+	}
+	s.Start(context.Background())
+
+	fmt.Printf("serving POST /edit and GET /jobs on %s, jobs in %s\n", address, args[1])
+	return http.ListenAndServe(address, s.Handler())
+}
+
+// Cost of each transformation, see package bench:
+//
+//	bench [-json] [-hash mimc|poseidon2]... [transformation...]    (all the transformations of the circuit if none is named)
+//
+// Each -hash measures the transformations again with that hash of image digests and signatures, MiMC if none.
+// Progress goes to stderr, so that the table or JSON on stdout can be kept to track regressions.
+// The same steps are Go benchmarks too: go test -run '^$' -bench . ./src/bench
+func run_bench(args []string) error {
+	usage := "usage: bench [-json] [-hash mimc|poseidon2]... [transformation...]"
+	as_json := false
+	cases := []bench.Case{}
+	hash_functions := []image.Hash_Function{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-json" {
+			as_json = true
+			continue
+		}
+		if arg == "-hash" {
+			if i+1 == len(args) {
+				return fmt.Errorf("%s: -hash needs a hash function", usage)
+			}
+			i++
+			hash_function, err := image.Parse_Hash_Function(args[i])
+			if err != nil {
+				return fmt.Errorf("%s: %w", usage, err)
+			}
+			hash_functions = append(hash_functions, hash_function)
+			continue
+		}
+
+		found := false
+		for _, c := range bench.Cases {
+			if c.Transformation.GetName() == arg {
+				cases = append(cases, c)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: unknown transformation %q", usage, arg)
+		}
+	}
+	if len(cases) == 0 {
+		cases = bench.Cases
+	}
+	if len(hash_functions) == 0 {
+		hash_functions = []image.Hash_Function{image.MiMC}
+	}
+	cases = bench.With_Hash(cases, hash_functions...)
+
+	logger.Disable() // The logs of gnark would mix with the results
+	results, err := bench.Run(context.Background(), cases, func(result bench.Result) {
+		fmt.Fprintf(os.Stderr, "measured %s with %s: %d constraints, prove %s\n", result.Transformation, result.Hash_Function, result.Constraints, result.Prove.Round(time.Millisecond))
+	})
+	if err != nil {
+		return err
+	}
+
+	if as_json {
+		return bench.Write_JSON(os.Stdout, results)
+	}
+	return bench.Write_Table(os.Stdout, results)
+}
+
+// Constraints of each gadget of Permissible_Transformations, see bench.Profile():
+//
+//	profile [pprof-file] [policy.json]    (the pprof file defaults to constraints.pprof)
+func run_profile(args []string) error {
+	if len(args) > 2 {
+		return fmt.Errorf("usage: profile [pprof-file] [policy.json]")
+	}
+	path := "constraints.pprof"
+	if len(args) >= 1 {
+		path = args[0]
+	}
+
+	circuit := photoproof.Permissible_Transformations{}
+	if len(args) == 2 {
+		policy, err := photoproof.LoadPolicy(args[1])
+		if err != nil {
+			return err
+		}
+		if err := policy.Apply(&circuit); err != nil {
+			return err
+		}
+	}
+
+	logger.Disable()
+	report, err := bench.Profile(&circuit, path, bench.Gadgets)
+	if err != nil {
+		return err
+	}
 
-	example.Test_Partial_Knowledge(true, true)
+	if err := report.Write(os.Stdout); err != nil {
+		return err
+	}
+	fmt.Printf("\nwrote %s, see: go tool pprof -top %s\n", path, path)
+	return nil
 }
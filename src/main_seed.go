@@ -0,0 +1,10 @@
+//go:build seeded_setup
+
+package main
+
+import "github.com/drakstik/Photognark_V3/src/example"
+
+// go run -tags seeded_setup ./src seed
+func init() {
+	tagged_examples["seed"] = example.Test_Seed
+}
@@ -0,0 +1,297 @@
+package photoproof
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+	"github.com/consensys/gnark/std/signature/eddsa"
+	"github.com/drakstik/Photognark_V3/src/image"
+)
+
+/*
+Anonymous circuit: the same permissible transformations as the main circuit, but the
+camera public key and every signature stay secret. The verifier only learns the image,
+its original hash, and that the signing key is a leaf of the Merkle root of authorised
+camera keys (see KeySet), i.e. the photographer is anonymous within the fleet.
+*/
+type Anonymous_Permissible_Transformations struct {
+	Input           image.Fr_Z                   `gnark:",secret"`
+	Output          image.Fr_Z                   `gnark:",secret"`
+	Signature       eddsa.Signature              `gnark:",secret"`
+	Parameters      Fr_Transformation_Parameters `gnark:",secret"`
+	Identity        Fr_Identity_Transformation   `gnark:",secret"`
+	Brightness      Fr_Brightness_Transformation `gnark:",secret"`
+	Case_1          frontend.Variable            `gnark:",secret"`
+	Input_Signature eddsa.Signature              `gnark:",secret"` // See Permissible_Transformations
+
+	Img          image.Fr_Image    `gnark:",public"` // Output image
+	OriginalHash frontend.Variable `gnark:",public"` // Output original hash
+	Timestamp    frontend.Variable `gnark:",public"` // Output capture time. The counter stays hidden, it would link photos of a camera.
+	Metadata     frontend.Variable `gnark:",public"` // Output metadata commitment, so that viewers can check a Disclosure
+	Keys_Root    frontend.Variable `gnark:",public"` // Merkle root of the authorised camera public keys
+
+	Keys_Path  []frontend.Variable `gnark:",secret"` // Keys_Depth+1 values, allocated by Generator_Anonymous()
+	Keys_Index frontend.Variable   `gnark:",secret"`
+
+	// The depth of the key set Merkle tree, Default_Keys_Depth if 0. Like Bounds, it is compiled into the keys:
+	// the fleet can grow to 2^Keys_Depth cameras, each level costs a MiMC hash in the circuit.
+	Keys_Depth int `gnark:"-"`
+
+	// See Permissible_Transformations
+	Bounds        ProvenanceBounds    `gnark:"-"`
+	Params        ParamsBounds        `gnark:"-"`
+	Policy_ID     []byte              `gnark:"-"`
+	Hash_Function image.Hash_Function `gnark:"-"`
+	Policy_Hash   frontend.Variable   `gnark:",public"`
+}
+
+func (circuit Anonymous_Permissible_Transformations) Define(api frontend.API) error {
+	depth, err := check_keys_depth(circuit.Keys_Depth)
+	if err != nil {
+		return err
+	}
+	if len(circuit.Keys_Path) != depth+1 {
+		return fmt.Errorf("the key set path has %d values, the depth %d needs %d", len(circuit.Keys_Path), depth, depth+1)
+	}
+
+	// Run the main circuit on the hidden values
+	main := Permissible_Transformations{
+		Input:           circuit.Input,
+		Output:          circuit.Output,
+		Signature:       circuit.Signature,
+		Parameters:      circuit.Parameters,
+		Identity:        circuit.Identity,
+		Brightness:      circuit.Brightness,
+		Case_1:          circuit.Case_1,
+		Input_Signature: circuit.Input_Signature,
+		Bounds:          circuit.Bounds,
+		Params:          circuit.Params,
+		Policy_ID:       circuit.Policy_ID,
+		Policy_Hash:     circuit.Policy_Hash,
+
+		Hash_Function: circuit.Hash_Function,
+	}
+	main.Predicate, main.Location = No_Predicate() // No predicates on anonymous photos
+	if err := main.Define(api); err != nil {
+		return err
+	}
+
+	// The editors are hidden from the verifier, so the camera must have signed every edit.
+	Check_Camera_Editor(api, main)
+
+	// Bind the public image and original hash to the hidden output
+	for i := 0; i < int(image.N2); i++ {
+		for c := 0; c < 3; c++ {
+			api.AssertIsEqual(circuit.Img.Pxls[i].RGB[c], circuit.Output.Img.Pxls[i].RGB[c])
+		}
+		api.AssertIsEqual(circuit.Img.Pxls[i].Loc.X, circuit.Output.Img.Pxls[i].Loc.X)
+		api.AssertIsEqual(circuit.Img.Pxls[i].Loc.Y, circuit.Output.Img.Pxls[i].Loc.Y)
+	}
+	api.AssertIsEqual(circuit.OriginalHash, circuit.Output.OriginalHash)
+	api.AssertIsEqual(circuit.Timestamp, circuit.Output.Timestamp)
+	api.AssertIsEqual(circuit.Metadata, circuit.Output.MetadataCommitment)
+
+	// The hidden camera key, which verified the original signature, must be authorised.
+	ok := Verify_Camera_Membership(api, circuit.Output.PublicKey, circuit.Keys_Root, circuit.Keys_Path, circuit.Keys_Index)
+	api.AssertIsEqual(ok, 1)
+
+	return nil
+}
+
+// Generate the keys of the anonymous circuit for the cameras of key_set, and a new admin (unless one is given
+// With_Admin()) whose key is added to key_set if missing.
+// Cameras can be added to key_set later, up to 2^circuit.Keys_Depth, but verifiers then need the new Keys_Root.
+// key_set must have the depth of the circuit.
+func Generator_Anonymous(circuit *Anonymous_Permissible_Transformations, key_set *KeySet, options ...Generator_Option) (ProverKeys, VerifierKeys, User, error) {
+	config := generator_config(options)
+
+	depth, err := check_keys_depth(circuit.Keys_Depth)
+	if err != nil {
+		return ProverKeys{}, VerifierKeys{}, User{}, fmt.Errorf("[Generator_Anonymous()] %w", err)
+	}
+	if set_depth, err := key_set.depth(); err != nil {
+		return ProverKeys{}, VerifierKeys{}, User{}, fmt.Errorf("[Generator_Anonymous()] %w", err)
+	} else if set_depth != depth {
+		return ProverKeys{}, VerifierKeys{}, User{}, fmt.Errorf("[Generator_Anonymous()] the key set has depth %d, the circuit %d", set_depth, depth)
+	}
+	circuit.Keys_Path = make([]frontend.Variable, depth+1)
+
+	user, err := config.admin("Generator_Anonymous")
+	if err != nil {
+		return ProverKeys{}, VerifierKeys{}, User{}, err
+	}
+
+	if _, _, err := key_set.Path(user.PublicKey); err != nil {
+		if err := key_set.Add(user.PublicKey); err != nil {
+			return ProverKeys{}, VerifierKeys{}, User{}, fmt.Errorf("[Generator_Anonymous()] adding the admin to the key set: %w", err)
+		}
+	}
+
+	keys_root, err := key_set.Root()
+	if err != nil {
+		return ProverKeys{}, VerifierKeys{}, User{}, fmt.Errorf("[Generator_Anonymous()] computing the key set root: %w", err)
+	}
+
+	provingKey, verifyingKey, err := setup("Generator_Anonymous", circuit, config.seed)
+	if err != nil {
+		return ProverKeys{}, VerifierKeys{}, User{}, err
+	}
+
+	return ProverKeys{ProvingKey: provingKey, Original_PublicKey: user.PublicKey, KeySet: key_set, Bounds: circuit.Bounds, Params: circuit.Params, Policy_ID: circuit.Policy_ID, Hash_Function: circuit.Hash_Function},
+		VerifierKeys{VerifyingKey: verifyingKey, Original_PublicKey: user.PublicKey, Keys_Root: keys_root, Keys_Depth: circuit.Keys_Depth, Policy_ID: circuit.Policy_ID, Hash_Function: circuit.Hash_Function},
+		user, nil
+}
+
+// Assign the anonymous circuit, wrapping the assignment of the main circuit.
+func (user User) assign_anonymous(prover ProverKeys, z_in image.Z, tr Transformation, params Transformation_Parameters, proof_in Proof) (Anonymous_Permissible_Transformations, image.Z, []byte, error) {
+	key_set := prover.KeySet
+	if key_set == nil {
+		return Anonymous_Permissible_Transformations{}, image.Z{}, nil, fmt.Errorf("[Prove_Anonymous()] %w: prover keys have no key set", ErrUntrustedKey)
+	}
+
+	main, z_out, signature_out, err := user.assign(prover, z_in, tr, params, proof_in)
+	if err != nil {
+		return Anonymous_Permissible_Transformations{}, image.Z{}, nil, err
+	}
+
+	// The output public key is the camera's, in both cases.
+	keys_index, keys_path, err := key_set.Path(z_in.PublicKey)
+	if err != nil {
+		return Anonymous_Permissible_Transformations{}, image.Z{}, nil, fmt.Errorf("[Prove_Anonymous()] %w: %w", ErrUntrustedKey, err)
+	}
+
+	keys_root, err := key_set.Root()
+	if err != nil {
+		return Anonymous_Permissible_Transformations{}, image.Z{}, nil, fmt.Errorf("[Prove_Anonymous()] %w", err)
+	}
+
+	circuit := Anonymous_Permissible_Transformations{
+		Input:           main.Input,
+		Output:          main.Output,
+		Signature:       main.Signature,
+		Parameters:      main.Parameters,
+		Identity:        main.Identity,
+		Brightness:      main.Brightness,
+		Case_1:          main.Case_1,
+		Input_Signature: main.Input_Signature,
+		Img:             main.Output.Img,
+		OriginalHash:    main.Output.OriginalHash,
+		Timestamp:       main.Output.Timestamp,
+		Metadata:        main.Output.MetadataCommitment,
+		Keys_Root:       keys_root,
+		Keys_Path:       make([]frontend.Variable, len(keys_path)),
+		Keys_Index:      keys_index,
+		Keys_Depth:      len(keys_path) - 1,
+		Bounds:          main.Bounds,
+		Params:          main.Params,
+		Policy_ID:       main.Policy_ID,
+		Policy_Hash:     main.Policy_Hash,
+
+		Hash_Function: main.Hash_Function,
+	}
+	for i := range keys_path {
+		circuit.Keys_Path[i] = keys_path[i]
+	}
+
+	return circuit, z_out, signature_out, nil
+}
+
+// Like Prove(), but with the anonymous circuit. z_out still holds the secret camera key and signatures,
+// which the next edit needs; only publish z_out.Anonymous() and the PCD_Proof.
+func (user User) Prove_Anonymous(prover ProverKeys, z_in image.Z, tr Transformation, params Transformation_Parameters, proof_in Proof) (image.Z, Proof, error) {
+	circuit, z_out, signature_out, err := user.assign_anonymous(prover, z_in, tr, params, proof_in)
+	if err != nil {
+		return image.Z{}, Proof{}, err
+	}
+
+	pcd_proof_out, err := prove_circuit(&circuit, prover.ProvingKey, nil)
+	if err != nil {
+		return image.Z{}, Proof{}, err
+	}
+
+	return z_out, Proof{
+		PCD_Proof: pcd_proof_out,
+		Signature: signature_out, // Secret, do not publish
+	}, nil
+}
+
+// Verify an anonymous photo: only z_in.Img, z_in.OriginalHash, z_in.Timestamp, z_in.MetadataCommitment and proof_in.PCD_Proof
+// are used, against the Keys_Root of the verifier keys.
+func (user User) Verify_Anonymous(verifier_keys VerifierKeys, z_in image.Z, proof_in Proof) (bool, error) {
+	if proof_in.PCD_Proof == nil {
+		return false, fmt.Errorf("[Verify_Anonymous()] %w: an anonymous photo needs a PCD proof", ErrMalformed)
+	}
+	if verifier_keys.Keys_Root == nil {
+		return false, fmt.Errorf("[Verify_Anonymous()] %w: verifier keys have no key set root", ErrUntrustedKey)
+	}
+	if verifier_keys.Editors != nil {
+		return false, fmt.Errorf("[Verify_Anonymous()] %w: the editors of an anonymous photo are hidden", ErrUntrustedKey)
+	}
+	if z_in.Img.Hash_Function != verifier_keys.Hash_Function {
+		return false, fmt.Errorf("[Verify_Anonymous()] %w: the image is hashed with %s, the keys with %s", ErrMalformed, z_in.Img.Hash_Function, verifier_keys.Hash_Function)
+	}
+
+	depth, err := check_keys_depth(verifier_keys.Keys_Depth)
+	if err != nil {
+		return false, fmt.Errorf("[Verify_Anonymous()] %w: %w", ErrUntrustedKey, err)
+	}
+
+	// The secret values do not matter when verifying, but they cannot be nil.
+	fr_z_in := anonymous_fr_z(z_in)
+	circuit := Anonymous_Permissible_Transformations{
+		Input:           fr_z_in,
+		Output:          fr_z_in,
+		Signature:       eddsa.Signature{R: twistededwards.Point{X: 0, Y: 0}, S: 0},
+		Parameters:      Fr_Identity_Tr_Params{},
+		Identity:        Fr_Identity_Transformation{Flag: frontend.Variable(0)},
+		Brightness:      Fr_Brightness_Transformation{Flag: frontend.Variable(0), Delta: frontend.Variable(0)},
+		Case_1:          frontend.Variable(0),
+		Input_Signature: eddsa.Signature{R: twistededwards.Point{X: 0, Y: 0}, S: 0},
+		Keys_Index:      frontend.Variable(0),
+		Keys_Path:       make([]frontend.Variable, depth+1),
+		Keys_Depth:      depth,
+
+		Img:          fr_z_in.Img,                          // Public values
+		OriginalHash: fr_z_in.OriginalHash,                 // Public values
+		Timestamp:    fr_z_in.Timestamp,                    // Public values
+		Metadata:     fr_z_in.MetadataCommitment,           // Public values
+		Keys_Root:    verifier_keys.Keys_Root,              // Public values
+		Policy_Hash:  Policy_Hash(verifier_keys.Policy_ID), // Public values
+	}
+	for i := range circuit.Keys_Path {
+		circuit.Keys_Path[i] = frontend.Variable(0)
+	}
+
+	if err := verify_circuit(&circuit, proof_in.PCD_Proof, verifier_keys.VerifyingKey); err != nil {
+		return false, err
+	}
+
+	// The capture time must satisfy the verifier's policy.
+	if verifier_keys.TimePolicy != nil {
+		if err := verifier_keys.TimePolicy.Check(time.Unix(int64(z_in.Timestamp), 0), time.Now()); err != nil {
+			return false, fmt.Errorf("[Verify_Anonymous()] %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+// An Fr_Z of the public values of an anonymous photo, with zeroes for the camera key, original signature and counter.
+func anonymous_fr_z(z image.Z) image.Fr_Z {
+	return image.Fr_Z{
+		Img:               z.Img.ToFr(),
+		PublicKey:         eddsa.PublicKey{A: twistededwards.Point{X: 0, Y: 0}},
+		OriginalSignature: eddsa.Signature{R: twistededwards.Point{X: 0, Y: 0}, S: 0},
+		OriginalHash:      frontend.Variable(z.OriginalHash),
+		Timestamp:         frontend.Variable(z.Timestamp),
+		Counter:           frontend.Variable(0),
+
+		MetadataCommitment: frontend.Variable(z.MetadataCommitment),
+		Provenance:         image.Provenance{}.ToFr(), // Secret
+
+		Editor:  eddsa.PublicKey{A: twistededwards.Point{X: 0, Y: 0}}, // Secret
+		Editors: frontend.Variable(0),
+	}
+}
@@ -0,0 +1,226 @@
+package photoproof
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	eddsa_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/drakstik/Photognark_V3/src/image"
+)
+
+// An EdDSA signature checked out of the circuit by the verifier.
+type signature_check struct {
+	public_key signature.PublicKey
+	signature  []byte
+	message    []byte
+	hash       image.Hash_Function // H of H(R, A, M)
+	err        error               // Returned if the signature is not valid
+}
+
+func (check signature_check) verify() error {
+	ok, err := check.public_key.Verify(check.signature, check.message, check.hash.New())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrBadSignature, err)
+	}
+	if !ok {
+		return check.err
+	}
+	return nil
+}
+
+// Verify many signatures at once with a randomized check, for random 128-bit z_i:
+//
+//	cofactor * [sum z_i S_i] B == cofactor * (sum [z_i] R_i + sum [z_i H(R_i, A_i, M_i)] A_i)
+//
+// The terms of each distinct public key are summed before multiplying, so signatures of the same camera
+// cost a single multiplication by A. If the check fails, both halves are checked again to find the
+// invalid signatures. Returns the error of each check, nil if it is valid.
+func verify_signatures(checks []signature_check) []error {
+	errs := make([]error, len(checks))
+	if len(checks) == 0 || batch_verify_signatures(checks) {
+		return errs
+	}
+
+	if len(checks) == 1 {
+		errs[0] = checks[0].verify()
+		return errs
+	}
+
+	half := len(checks) / 2
+	copy(errs, verify_signatures(checks[:half]))
+	copy(errs[half:], verify_signatures(checks[half:]))
+	return errs
+}
+
+func batch_verify_signatures(checks []signature_check) bool {
+	curve := twistededwards.GetEdwardsCurve()
+	order := &curve.Order
+
+	var s_sum big.Int
+	var neutral twistededwards.PointAffine // (0, 1)
+	neutral.Y.SetOne()
+	var r_sum twistededwards.PointProj
+	r_sum.FromAffine(&neutral)
+
+	type key_term struct {
+		a      twistededwards.PointAffine
+		scalar big.Int
+	}
+	keys := map[string]*key_term{}
+
+	for _, check := range checks {
+		var pk eddsa_bn254.PublicKey
+		if _, err := pk.SetBytes(check.public_key.Bytes()); err != nil || !pk.A.IsOnCurve() {
+			return false
+		}
+
+		var sig eddsa_bn254.Signature
+		if _, err := sig.SetBytes(check.signature); err != nil {
+			return false
+		}
+
+		z, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+		if err != nil {
+			return false
+		}
+
+		// H(R, A, M), as in eddsa_bn254.PublicKey.Verify()
+		hFunc := check.hash.New()
+		r_x, r_y := sig.R.X.Bytes(), sig.R.Y.Bytes()
+		a_x, a_y := pk.A.X.Bytes(), pk.A.Y.Bytes()
+		for _, data := range [][]byte{r_x[:], r_y[:], a_x[:], a_y[:], check.message} {
+			hFunc.Write(data)
+		}
+		hram := new(big.Int).SetBytes(hFunc.Sum(nil))
+
+		// sum z_i S_i
+		s := new(big.Int).SetBytes(sig.S[:])
+		s_sum.Add(&s_sum, s.Mul(s, z))
+		s_sum.Mod(&s_sum, order)
+
+		// sum [z_i] R_i
+		var r twistededwards.PointProj
+		r.FromAffine(&sig.R)
+		r.ScalarMultiplication(&r, z)
+		r_sum.Add(&r_sum, &r)
+
+		// sum z_i H_i, per public key
+		index := string(check.public_key.Bytes())
+		term, ok := keys[index]
+		if !ok {
+			term = &key_term{a: pk.A}
+			keys[index] = term
+		}
+		term.scalar.Add(&term.scalar, hram.Mul(hram, z))
+		term.scalar.Mod(&term.scalar, order)
+	}
+
+	rhs := r_sum
+	for _, term := range keys {
+		var a twistededwards.PointProj
+		a.FromAffine(&term.a)
+		a.ScalarMultiplication(&a, &term.scalar)
+		rhs.Add(&rhs, &a)
+	}
+
+	var lhs twistededwards.PointProj
+	lhs.FromAffine(&curve.Base)
+	lhs.ScalarMultiplication(&lhs, &s_sum)
+
+	// Clear the small-order components
+	var cofactor big.Int
+	curve.Cofactor.BigInt(&cofactor)
+	lhs.ScalarMultiplication(&lhs, &cofactor)
+	rhs.ScalarMultiplication(&rhs, &cofactor)
+
+	return lhs.Equal(&rhs)
+}
+
+/*------------------------------------------ Batch Verification --------------------------------------*/
+
+// A photo to verify with VerifyBatch(). If Predicate is set, it is verified like Verify_Predicate().
+type Batch_Item struct {
+	Z         image.Z
+	Proof     Proof
+	Predicate *Predicate
+}
+
+// The outcome of verifying one photo of a batch.
+type Verification_Result struct {
+	OK    bool
+	Stage string // Where verification failed: "checks", "signature", "proof" (or "disclosure", see viewer). Empty if OK.
+	Err   error
+}
+
+// Verify many photos under the same verifier keys, with the same result as calling Verify() on each of them.
+// The EdDSA signatures are verified in a single batch, and the PCD proofs in parallel.
+func (user User) VerifyBatch(verifier_keys VerifierKeys, items []Batch_Item) []Verification_Result {
+	results := make([]Verification_Result, len(items))
+	circuits := make([]*Permissible_Transformations, len(items))
+
+	var checks []signature_check
+	var owners []int // The item of each check
+	for i, item := range items {
+		fr_predicate, _ := No_Predicate()
+		if item.Predicate != nil {
+			if item.Proof.PCD_Proof == nil {
+				results[i] = Verification_Result{Stage: "checks", Err: fmt.Errorf("[Verify_Predicate()] %w: a predicate needs a PCD proof", ErrMalformed)}
+				continue
+			}
+			if err := item.Predicate.location_only("Verify_Predicate"); err != nil {
+				results[i] = Verification_Result{Stage: "checks", Err: err}
+				continue
+			}
+			fr_predicate = item.Predicate.ToFr()
+		}
+
+		item_checks, circuit, err := verifier_keys.prepare(item.Z, item.Proof, fr_predicate)
+		if err != nil {
+			results[i] = Verification_Result{Stage: "checks", Err: err}
+			continue
+		}
+
+		results[i].OK = true
+		circuits[i] = circuit
+		for _, check := range item_checks {
+			checks = append(checks, check)
+			owners = append(owners, i)
+		}
+	}
+
+	for j, err := range verify_signatures(checks) {
+		i := owners[j]
+		if err != nil && results[i].OK {
+			results[i] = Verification_Result{Stage: "signature", Err: err}
+		}
+	}
+
+	// Verify the PCD proofs of the photos that passed so far, on every CPU.
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := verify_circuit(circuits[i], items[i].Proof.PCD_Proof, verifier_keys.VerifyingKey); err != nil {
+					results[i] = Verification_Result{Stage: "proof", Err: err}
+				}
+			}
+		}()
+	}
+	for i := range items {
+		if results[i].OK && circuits[i] != nil {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
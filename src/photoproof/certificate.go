@@ -0,0 +1,121 @@
+package photoproof
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/drakstik/Photognark_V3/src/image"
+)
+
+// A device key attested by a manufacturer root key.
+type Certificate struct {
+	Serial    uint64
+	DeviceID  string
+	PublicKey signature.PublicKey // The device (camera) key
+	NotBefore time.Time
+	NotAfter  time.Time
+	Issuer    signature.PublicKey // The manufacturer root key
+	Signature []byte              // Signature of the Issuer over Digest()
+}
+
+// The digest signed by the issuer: a field element of the SHA256 of all other fields.
+func (cert Certificate) Digest() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, cert.Serial)
+	binary.Write(&buf, binary.BigEndian, uint64(len(cert.DeviceID)))
+	buf.WriteString(cert.DeviceID)
+	buf.Write(cert.PublicKey.Bytes())
+	binary.Write(&buf, binary.BigEndian, cert.NotBefore.Unix())
+	binary.Write(&buf, binary.BigEndian, cert.NotAfter.Unix())
+	buf.Write(cert.Issuer.Bytes())
+
+	sum := sha256.Sum256(buf.Bytes())
+
+	var digest fr.Element
+	digest.SetBytes(sum[:]) // Reduce into the field, so that MiMC can absorb it
+	return digest.Marshal()
+}
+
+// Check that the certificate was issued by root and may be used at time t, e.g. the capture time of a photo.
+// Every failure is an ErrUntrustedKey, and ErrExpiredCertificate outside the validity window.
+// The camera signs the capture time itself, so a leaked key can backdate photos into the validity window of
+// its certificate: the window only bounds honest cameras. Revoke the certificate of a leaked key instead,
+// see TrustStore.Revoke_Certificate().
+func (cert Certificate) Verify(root signature.PublicKey, t time.Time) error {
+	if cert.PublicKey == nil || cert.Issuer == nil {
+		return fmt.Errorf("%w: certificate is incomplete", ErrUntrustedKey)
+	}
+	if root == nil || !bytes.Equal(cert.Issuer.Bytes(), root.Bytes()) {
+		return fmt.Errorf("%w: certificate %d was not issued by the root key", ErrUntrustedKey, cert.Serial)
+	}
+
+	ok, err := root.Verify(cert.Signature, cert.Digest(), hash.MIMC_BN254.New())
+	if err != nil {
+		return fmt.Errorf("%w: %w: signature of certificate %d: %w", ErrUntrustedKey, ErrBadSignature, cert.Serial, err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %w: signature of certificate %d", ErrUntrustedKey, ErrBadSignature, cert.Serial)
+	}
+
+	if t.Before(cert.NotBefore) || t.After(cert.NotAfter) {
+		return fmt.Errorf("%w: %w: certificate %d is only valid from %s to %s", ErrUntrustedKey, ErrExpiredCertificate, cert.Serial, cert.NotBefore, cert.NotAfter)
+	}
+
+	return nil
+}
+
+// A camera manufacturer, which issues certificates for device keys with its root key.
+type Manufacturer struct {
+	Root   User
+	Serial uint64 // Serial number of the last issued certificate
+}
+
+func NewManufacturer() (*Manufacturer, error) {
+	root, err := NewUser()
+	if err != nil {
+		return nil, fmt.Errorf("[NewManufacturer()] %w", err)
+	}
+	return &Manufacturer{Root: root}, nil
+}
+
+// Issue a certificate for a device key, valid from now for validity.
+func (manufacturer *Manufacturer) Issue(device_id string, public_key signature.PublicKey, validity time.Duration) (Certificate, error) {
+	now := time.Now().Truncate(time.Second) // The digest has a precision of seconds
+
+	manufacturer.Serial++
+	cert := Certificate{
+		Serial:    manufacturer.Serial,
+		DeviceID:  device_id,
+		PublicKey: public_key,
+		NotBefore: now,
+		NotAfter:  now.Add(validity),
+		Issuer:    manufacturer.Root.PublicKey,
+	}
+
+	signature, err := manufacturer.Root.Sign_Digest(cert.Digest(), image.MiMC)
+	if err != nil {
+		return Certificate{}, err
+	}
+	cert.Signature = signature
+
+	return cert, nil
+}
+
+// Verify a photo whose camera key is attested by certificate: photo -> device key -> root key.
+// The root key is the Root_PublicKey of the verifier keys. The certificate must be valid at the
+// signed capture time of the photo, so that older photos outlive their certificate, and not be revoked
+// by the TrustStore of the verifier keys. Verify() does the same with the certificate of proof_in, if any.
+func (user User) Verify_Certified(verifier_keys VerifierKeys, certificate Certificate, z_in image.Z, proof_in Proof) (bool, error) {
+	if verifier_keys.Root_PublicKey == nil {
+		return false, fmt.Errorf("[Verify_Certified()] %w: verifier keys have no root key", ErrUntrustedKey)
+	}
+
+	proof_in.Certificate = &certificate
+	return user.Verify(verifier_keys, z_in, proof_in)
+}
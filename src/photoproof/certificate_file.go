@@ -0,0 +1,175 @@
+package photoproof
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/consensys/gnark-crypto/signature"
+)
+
+/*
+A Manufacturer is saved in a directory, so that the rotate command can issue certificates across runs:
+
+	root_key.json        The root key, in an encrypted keystore, see User.Save()
+	manufacturer.json    The serial number of the last issued certificate, and the root public key
+
+A Certificate is saved on its own as JSON, with its keys hex encoded, and embedded as such in photo containers.
+*/
+
+const (
+	Root_Key_File     = "root_key.json"
+	Manufacturer_File = "manufacturer.json"
+)
+
+type manufacturer_file struct {
+	Serial         uint64 `json:"serial"`
+	Root_PublicKey string `json:"root_public_key"` // Hex encoded
+}
+
+type certificate_file struct {
+	Serial    uint64    `json:"serial"`
+	DeviceID  string    `json:"device_id"`
+	PublicKey string    `json:"public_key"` // Hex encoded, like the issuer and signature
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	Issuer    string    `json:"issuer"`
+	Signature string    `json:"signature"`
+}
+
+// Write the manufacturer to dir, see LoadManufacturer(). Save it after each Issue(), so that serials are not reused.
+func (manufacturer *Manufacturer) Save(dir string, passphrase []byte) error {
+	if manufacturer.Root.PublicKey == nil {
+		return errors.New("[Manufacturer.Save()] the manufacturer has no root key")
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	// The root key does not change, only write it once.
+	root_path := filepath.Join(dir, Root_Key_File)
+	if _, err := os.Stat(root_path); errors.Is(err, os.ErrNotExist) {
+		if err := manufacturer.Root.Save(root_path, passphrase); err != nil {
+			return fmt.Errorf("[Manufacturer.Save()] %w", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(manufacturer_file{
+		Serial:         manufacturer.Serial,
+		Root_PublicKey: hex.EncodeToString(manufacturer.Root.PublicKey.Bytes()),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return write_file_atomic(filepath.Join(dir, Manufacturer_File), data, 0o644)
+}
+
+// Read a manufacturer written by Save().
+func LoadManufacturer(dir string, passphrase []byte) (*Manufacturer, error) {
+	root, err := LoadUser(filepath.Join(dir, Root_Key_File), passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("[LoadManufacturer()] %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, Manufacturer_File))
+	if err != nil {
+		return nil, fmt.Errorf("[LoadManufacturer()] %w", err)
+	}
+	var file manufacturer_file
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("[LoadManufacturer()] %s: %w", Manufacturer_File, err)
+	}
+
+	// The serials must be those of this root key.
+	if file.Root_PublicKey != hex.EncodeToString(root.PublicKey.Bytes()) {
+		return nil, fmt.Errorf("[LoadManufacturer()] %s is not the file of %s", Manufacturer_File, Root_Key_File)
+	}
+
+	return &Manufacturer{Root: root, Serial: file.Serial}, nil
+}
+
+// Write the certificate to a JSON file.
+func (cert Certificate) Save(path string) error {
+	data, err := json.MarshalIndent(cert, "", "  ")
+	if err != nil {
+		return fmt.Errorf("[Certificate.Save()] %w", err)
+	}
+	return write_file_atomic(path, data, 0o644)
+}
+
+// Read a certificate written by Save(). It is not verified, see Certificate.Verify().
+func LoadCertificate(path string) (Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Certificate{}, err
+	}
+
+	var cert Certificate
+	if err := json.Unmarshal(data, &cert); err != nil {
+		return Certificate{}, fmt.Errorf("[LoadCertificate()] %w", err)
+	}
+	return cert, nil
+}
+
+func (cert Certificate) MarshalJSON() ([]byte, error) {
+	if cert.PublicKey == nil || cert.Issuer == nil {
+		return nil, errors.New("certificate is incomplete")
+	}
+
+	return json.Marshal(certificate_file{
+		Serial:    cert.Serial,
+		DeviceID:  cert.DeviceID,
+		PublicKey: hex.EncodeToString(cert.PublicKey.Bytes()),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		Issuer:    hex.EncodeToString(cert.Issuer.Bytes()),
+		Signature: hex.EncodeToString(cert.Signature),
+	})
+}
+
+func (cert *Certificate) UnmarshalJSON(data []byte) error {
+	var file certificate_file
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+
+	public_key := func(name string, src string) (signature.PublicKey, error) {
+		key_bytes, err := hex.DecodeString(src)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		key, err := PublicKey_From_Bytes(key_bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return key, nil
+	}
+
+	decoded := Certificate{Serial: file.Serial, DeviceID: file.DeviceID, NotBefore: file.NotBefore, NotAfter: file.NotAfter}
+	var err error
+	if decoded.PublicKey, err = public_key("public_key", file.PublicKey); err != nil {
+		return err
+	}
+	if decoded.Issuer, err = public_key("issuer", file.Issuer); err != nil {
+		return err
+	}
+	if decoded.Signature, err = hex.DecodeString(file.Signature); err != nil {
+		return fmt.Errorf("signature: %w", err)
+	}
+
+	*cert = decoded
+	return nil
+}
+
+// Write data next to path, then rename it, so that path is never left half written.
+func write_file_atomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.WriteFile(path+".tmp", data, perm); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -1,9 +1,12 @@
 package photoproof
 
-import "github.com/consensys/gnark/frontend"
+import (
+	"github.com/consensys/gnark/frontend"
+	"github.com/drakstik/Photognark_V3/src/image"
+)
 
 func Check_Transformation(api frontend.API, permissible Permissible_Transformations) frontend.Variable {
-	/* Ensure that input public key and output public key are the same. */
+	/* Ensure that input public key and output public key are the same: the camera key is passed through every edit. */
 	api.AssertIsEqual(permissible.Input.PublicKey.A.X, permissible.Output.PublicKey.A.X)
 	api.AssertIsEqual(permissible.Input.PublicKey.A.Y, permissible.Output.PublicKey.A.Y)
 
@@ -12,17 +15,51 @@ func Check_Transformation(api frontend.API, permissible Permissible_Transformati
 	// 		- th original hash is passed from input to output without modification
 	api.AssertIsEqual(permissible.Input.OriginalHash, permissible.Output.OriginalHash)
 
-	// Verify the output signature is valid.
-	digest, mimc := permissible.Output.Img.Hash(api)
-	Verify_Signature(api, digest, permissible.Signature, permissible.Output.PublicKey, mimc)
+	// The capture time and counter are passed from input to output without modification
+	api.AssertIsEqual(permissible.Input.Timestamp, permissible.Output.Timestamp)
+	api.AssertIsEqual(permissible.Input.Counter, permissible.Output.Counter)
 
-	// TODO: ensure that all permissible transformations are applied
-	/* Run Identity Transformation check if flag is on. */
-	api.Select(
-		permissible.Identity.Flag,
-		permissible.Identity.Apply(api, permissible.Input, permissible.Output, permissible.Parameters, permissible.Signature),
-		frontend.Variable(0),
+	// The metadata commitment is passed from input to output without modification
+	api.AssertIsEqual(permissible.Input.MetadataCommitment, permissible.Output.MetadataCommitment)
+
+	// Verify the input signature: the camera signed the image of an original input, and the editor of the last
+	// edit signed the image, provenance and editors of an edited input, so that the Input is the output of a signed edit.
+	digest_in, h := permissible.Input.Img.Hash(api)
+	input_original := Check_Original_Input(api, permissible.Input)
+	// The camera signed the image of an original input, and its original signature (checked on the Output, which
+	// copies its fields) the original hash: they must match, or the capture time, counter and metadata of another
+	// photo could be attached to the image.
+	api.AssertIsEqual(api.Mul(input_original, api.Sub(permissible.Input.OriginalHash, digest_in)), 0)
+	message_in := api.Select(input_original, digest_in, permissible.Input.Edit_Digest(api, digest_in))
+	Verify_Signature(api, message_in, permissible.Input_Signature, Input_Signing_Key(api, permissible, input_original), h)
+
+	// Verify the output signature is valid, under the key of the editor (or the camera, in case 1).
+	digest_out, _ := permissible.Output.Img.Hash(api)
+	message_out := api.Select(permissible.Case_1, digest_out, permissible.Output.Edit_Digest(api, digest_out))
+	Verify_Signature(api, message_out, permissible.Signature, Signing_Key(api, permissible), h)
+
+	// Exactly one transformation flag is on in case 2, none in case 1.
+	api.AssertIsBoolean(permissible.Identity.Flag)
+	api.AssertIsBoolean(permissible.Brightness.Flag)
+	api.AssertIsEqual(api.Add(permissible.Identity.Flag, permissible.Brightness.Flag), api.Sub(1, permissible.Case_1))
+
+	/* Run the check of the transformation whose flag is on. */
+	identity_ok := permissible.Identity.Apply(api, permissible.Input, permissible.Output, permissible.Parameters, permissible.Signature)
+	brightness_ok := permissible.Brightness.Apply(api, permissible.Input, permissible.Output, permissible.Parameters, permissible.Signature)
+
+	return api.Add(
+		api.Mul(permissible.Identity.Flag, identity_ok),
+		api.Mul(permissible.Brightness.Flag, brightness_ok),
 	)
+}
+
+// Return 1 if the Input is an original, which has no edits, and 0 otherwise. An original has no provenance,
+// editor or editors, so that its camera signature cannot stand in for the signature of an edit.
+func Check_Original_Input(api frontend.API, in image.Fr_Z) frontend.Variable {
+	input_original := api.IsZero(in.Provenance.Edits)
 
-	return 1
+	for _, v := range append([]frontend.Variable{in.Provenance.Brightness, in.Editors, in.Editor.A.X, in.Editor.A.Y}, in.Provenance.Uses[:]...) {
+		api.AssertIsEqual(api.Mul(input_original, v), 0)
+	}
+	return input_original
 }
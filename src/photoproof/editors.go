@@ -0,0 +1,114 @@
+package photoproof
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	eddsa_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+	"github.com/consensys/gnark/std/signature/eddsa"
+	"github.com/drakstik/Photognark_V3/src/image"
+)
+
+/*
+Edits are signed by the editor's own key, which can differ at each step of a chain, while the camera key
+is passed through unchanged. The proof records the editor of the last edit and a chain of every editor's
+key, Editors = MiMC(previous Editors, A.X, A.Y), so that the editors of each step are bound to the proof.
+
+An editor signs the Edit_Digest() of its output, which covers its provenance and editors, and each proof
+checks the signature of its input: by the camera for an original, else by the editor of the last edit.
+The circuit does not verify the proof of its input, so a chain is only as trusted as its editors: verifiers
+only accept the editors of VerifierKeys.Editors, and authorised editors only edit photos whose last editor
+is authorised too, see Check_Editor().
+*/
+
+// The editor chain of z_in after an edit signed by editor.
+// This function must have mirror output to Check_Editors().
+func Next_Editors(editors []byte, editor signature.PublicKey) ([]byte, error) {
+	var pk eddsa_bn254.PublicKey
+	if _, err := pk.SetBytes(editor.Bytes()); err != nil {
+		return nil, err
+	}
+
+	var previous fr.Element
+	previous.SetBytes(editors)
+	digest := mimc_elements(previous, pk.A.X, pk.A.Y)
+
+	digest_bytes := digest.Bytes()
+	return digest_bytes[:], nil
+}
+
+// The key that signed the input: the camera's if it is an original, the editor's of its last edit otherwise.
+func Input_Signing_Key(api frontend.API, circuit Permissible_Transformations, input_original frontend.Variable) eddsa.PublicKey {
+	signing_key := circuit.Input.PublicKey
+	signing_key.A.X = api.Select(input_original, circuit.Input.PublicKey.A.X, circuit.Input.Editor.A.X)
+	signing_key.A.Y = api.Select(input_original, circuit.Input.PublicKey.A.Y, circuit.Input.Editor.A.Y)
+	return signing_key
+}
+
+// The key that signed the output image: the camera's in case 1, the editor's otherwise.
+func Signing_Key(api frontend.API, circuit Permissible_Transformations) eddsa.PublicKey {
+	signing_key := circuit.Output.PublicKey
+	signing_key.A.X = api.Select(circuit.Case_1, circuit.Output.PublicKey.A.X, circuit.Output.Editor.A.X)
+	signing_key.A.Y = api.Select(circuit.Case_1, circuit.Output.PublicKey.A.Y, circuit.Output.Editor.A.Y)
+	return signing_key
+}
+
+// Check that the output editor chain is the input chain extended with the output editor, in case 2.
+// An original image has no editors.
+func Check_Editors(api frontend.API, circuit Permissible_Transformations) {
+	in, out := circuit.Input, circuit.Output
+
+	api.AssertIsEqual(api.Mul(circuit.Case_1, out.Editors), 0)
+	api.AssertIsEqual(api.Mul(circuit.Case_1, out.Editor.A.X), 0)
+	api.AssertIsEqual(api.Mul(circuit.Case_1, out.Editor.A.Y), 0)
+
+	h, _ := mimc.NewMiMC(api)
+	h.Write(in.Editors, out.Editor.A.X, out.Editor.A.Y)
+	api.AssertIsEqual(out.Editors, api.Select(circuit.Case_1, in.Editors, h.Sum()))
+}
+
+// The editors of new verifier keys: only the admin, see Generator().
+func admin_editors(admin User) *TrustStore {
+	editors := NewTrustStore()
+	editors.Add(CameraKey{DeviceID: "admin", PublicKey: admin.PublicKey})
+	return editors
+}
+
+// In the circuits that hide the editor (private and anonymous), the verifier cannot check it, so every edit
+// must be signed by the camera itself: the output editor, and the editor of an edited input, is the camera key.
+func Check_Camera_Editor(api frontend.API, circuit Permissible_Transformations) {
+	in, out := circuit.Input, circuit.Output
+
+	edit := api.Sub(1, circuit.Case_1)
+	api.AssertIsEqual(api.Mul(edit, api.Sub(out.Editor.A.X, out.PublicKey.A.X)), 0)
+	api.AssertIsEqual(api.Mul(edit, api.Sub(out.Editor.A.Y, out.PublicKey.A.Y)), 0)
+
+	input_edited := api.Sub(1, api.IsZero(in.Provenance.Edits))
+	api.AssertIsEqual(api.Mul(input_edited, api.Sub(in.Editor.A.X, in.PublicKey.A.X)), 0)
+	api.AssertIsEqual(api.Mul(input_edited, api.Sub(in.Editor.A.Y, in.PublicKey.A.Y)), 0)
+}
+
+// Check that the editor of the last edit of z is authorised at time t. Without Editors, no editor is.
+// A photo without editor must be a converted original. The editors of earlier edits are checked by
+// camera.Verify_History(), at the time of verification: the time of an edit is not signed by its editor.
+func (verifier_keys VerifierKeys) Check_Editor(z image.Z, t time.Time) error {
+	if z.Editor == nil {
+		if z.Provenance != (image.Provenance{}) || z.Editors != nil {
+			return errors.New("edited photo has no editor")
+		}
+		return nil
+	}
+
+	if verifier_keys.Editors == nil {
+		return errors.New("no editor is authorised by the verifier keys")
+	}
+	if _, err := verifier_keys.Editors.Lookup(z.Editor, t); err != nil {
+		return fmt.Errorf("editor: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package photoproof
+
+import (
+	"errors"
+	"log/slog"
+	"sync/atomic"
+)
+
+// Kinds of failure, wrapped with their context in the errors of photoproof and the packages built on it.
+// Check them with errors.Is(), e.g. errors.Is(err, ErrBadSignature).
+var (
+	ErrCompile        = errors.New("compiling the circuit failed")
+	ErrSetup          = errors.New("generating the PCD keys failed")
+	ErrWitness        = errors.New("assigning the witness failed")
+	ErrProve          = errors.New("proving failed")
+	ErrSign           = errors.New("signing failed")
+	ErrBadSignature   = errors.New("signature is not valid")
+	ErrProofInvalid   = errors.New("PCD proof is not valid")
+	ErrUntrustedKey   = errors.New("key is not trusted")
+	ErrNotPermissible = errors.New("transformation is not permissible")
+	ErrMalformed      = errors.New("photo is malformed")
+	ErrPublicTime     = errors.New("the capture time is public in the main circuit")
+	ErrKeySetFull     = errors.New("the key set is full")
+
+	ErrExpiredCertificate = errors.New("certificate is not valid at this time") // Also an ErrUntrustedKey
+)
+
+var logger atomic.Pointer[slog.Logger]
+
+// Set the structured logger of photoproof and the packages built on it, e.g. with a level or a JSON handler.
+// Failures are returned, not logged: the caller decides how to report them. Rejected photos and proofs are
+// logged at the debug level, and failures with no caller to return them to (e.g. of a proving job) at the error level.
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+}
+
+// The logger set by SetLogger(), slog.Default() if none.
+func Logger() *slog.Logger {
+	if l := logger.Load(); l != nil {
+		return l
+	}
+	return slog.Default()
+}
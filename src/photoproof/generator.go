@@ -6,8 +6,10 @@ import (
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark-crypto/signature"
 	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/drakstik/Photognark_V3/src/image"
 
 	_ "github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
 )
@@ -15,41 +17,82 @@ import (
 type ProverKeys struct {
 	ProvingKey         groth16.ProvingKey
 	Original_PublicKey signature.PublicKey
+	KeySet             *KeySet                     // Authorised camera keys, only for the anonymous circuit
+	Bounds             ProvenanceBounds            // The bounds compiled into ProvingKey, assigned to every proven circuit
+	Params             ParamsBounds                // Likewise
+	Policy_ID          []byte                      // Hash of the Policy compiled into ProvingKey, nil if none
+	Hash_Function      image.Hash_Function         // The hash of images compiled into ProvingKey
+	Compiled           constraint.ConstraintSystem // The compiled main circuit, see Compile(). If nil, each proof compiles it
 }
 
 type VerifierKeys struct {
 	VerifyingKey       groth16.VerifyingKey
 	Original_PublicKey signature.PublicKey
+	TrustStore         *TrustStore         // If set, trust any valid camera key of the store instead of Original_PublicKey
+	Keys_Root          []byte              // Merkle root of the authorised camera keys, only for the anonymous circuit
+	Keys_Depth         int                 // Depth of the key set compiled into VerifyingKey, Default_Keys_Depth if 0
+	Root_PublicKey     signature.PublicKey // Manufacturer root key, for photos with a Certificate
+	TimePolicy         *TimePolicy         // If set, the capture time of photos must satisfy it
+	Policy_ID          []byte              // Hash of the Policy enforced by VerifyingKey, nil if none
+	Editors            *TrustStore         // Edits must be signed by a key of the store, e.g. the admin's editors. If nil, no edit verifies
+	Hash_Function      image.Hash_Function // The hash of images compiled into VerifyingKey, photos must use it too
 }
 
-func Generator(circuit *Permissible_Transformations) (ProverKeys, VerifierKeys, User) {
-	// 1. Generate a secret & public key using ceddsa.
-	// secret_key, err := ceddsa.New(1, rand.Reader) // Generate a secret key for signing
-	// if err != nil {
-	// 	fmt.Println("func NewSecretKey(): Error while generating secret key using ceddsa...")
-	// 	fmt.Print(err.Error())
-	// 	return Prover{}, Verifier{}, nil
-	// }
+// Generate the keys of the main circuit, and a new admin unless one is given With_Admin().
+// The admin is the only authorised editor of the verifier keys, add others to VerifierKeys.Editors.
+func Generator(circuit *Permissible_Transformations, options ...Generator_Option) (ProverKeys, VerifierKeys, User, error) {
+	config := generator_config(options)
 
-	// public_key := secret_key.Public()
+	user, err := config.admin("Generator")
+	if err != nil {
+		return ProverKeys{}, VerifierKeys{}, User{}, err
+	}
+
+	provingKey, verifyingKey, err := setup("Generator", circuit, config.seed)
+	if err != nil {
+		return ProverKeys{}, VerifierKeys{}, User{}, err
+	}
 
-	user := NewUser()
+	return ProverKeys{ProvingKey: provingKey, Original_PublicKey: user.PublicKey, Bounds: circuit.Bounds, Params: circuit.Params, Policy_ID: circuit.Policy_ID, Hash_Function: circuit.Hash_Function},
+		VerifierKeys{VerifyingKey: verifyingKey, Original_PublicKey: user.PublicKey, Policy_ID: circuit.Policy_ID, Editors: admin_editors(user), Hash_Function: circuit.Hash_Function},
+		user, nil
+}
 
+// Compile the circuit and generate its PCD keys with a local groth16.Setup, seeded if seed is not nil
+// (see With_Seed(), in builds tagged seeded_setup).
+func setup(caller string, circuit frontend.Circuit, seed []byte) (groth16.ProvingKey, groth16.VerifyingKey, error) {
 	// Set the security parameter (BN254) and compile a constraint system (aka compliance_predicate)
 	compliance_predicate_id, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
 	if err != nil {
-		fmt.Println("[Generator]: ERROR while compiling constraint system")
-		return ProverKeys{}, VerifierKeys{}, User{}
+		return nil, nil, fmt.Errorf("[%s()] %w: %w", caller, ErrCompile, err)
 	}
 
 	// Generate PCD Keys from the compliance_predicate
-	provingKey, verifyingKey, err := groth16.Setup(compliance_predicate_id)
+	var provingKey groth16.ProvingKey
+	var verifyingKey groth16.VerifyingKey
+	if seed == nil {
+		provingKey, verifyingKey, err = groth16.Setup(compliance_predicate_id)
+	} else {
+		provingKey, verifyingKey, err = seeded_setup(compliance_predicate_id, seed)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("[%s()] %w: %w", caller, ErrSetup, err)
+	}
+
+	return provingKey, verifyingKey, nil
+}
+
+// Like Generator(), but with Groth16 keys produced by a multi-party ceremony (see package ceremony)
+// instead of a local groth16.Setup, so that whoever runs the Generator cannot forge proofs.
+// With_Seed() only seeds the admin, the ceremony chose the keys. circuit must be the circuit of the ceremony,
+// e.g. with its Policy applied: its constants are compiled into the keys, and copied to them like in Generator().
+func Generator_From_Keys(circuit *Permissible_Transformations, provingKey groth16.ProvingKey, verifyingKey groth16.VerifyingKey, options ...Generator_Option) (ProverKeys, VerifierKeys, User, error) {
+	user, err := generator_config(options).admin("Generator_From_Keys")
 	if err != nil {
-		fmt.Println("[Generator]: ERROR while generating PCD Keys from the constraint system")
-		return ProverKeys{}, VerifierKeys{}, User{}
+		return ProverKeys{}, VerifierKeys{}, User{}, err
 	}
 
-	return ProverKeys{ProvingKey: provingKey, Original_PublicKey: user.PublicKey},
-		VerifierKeys{VerifyingKey: verifyingKey, Original_PublicKey: user.PublicKey},
-		user
+	return ProverKeys{ProvingKey: provingKey, Original_PublicKey: user.PublicKey, Bounds: circuit.Bounds, Params: circuit.Params, Policy_ID: circuit.Policy_ID, Hash_Function: circuit.Hash_Function},
+		VerifierKeys{VerifyingKey: verifyingKey, Original_PublicKey: user.PublicKey, Policy_ID: circuit.Policy_ID, Editors: admin_editors(user), Hash_Function: circuit.Hash_Function},
+		user, nil
 }
@@ -0,0 +1,49 @@
+package photoproof
+
+import (
+	"fmt"
+)
+
+// An option of Generator(), Generator_Private(), Generator_Anonymous() and Generator_From_Keys().
+type Generator_Option func(*generator_options)
+
+type generator_options struct {
+	admin_user *User
+	seed       []byte // Only set by With_Seed(), which only exists in builds tagged seeded_setup
+}
+
+// Use an existing camera identity as the admin instead of a new one, e.g. to keep a camera's key
+// when its circuit changes. Its public key becomes the Original_PublicKey of the keys.
+func With_Admin(admin User) Generator_Option {
+	return func(options *generator_options) {
+		options.admin_user = &admin
+	}
+}
+
+func generator_config(options []Generator_Option) generator_options {
+	var config generator_options
+	for _, option := range options {
+		option(&config)
+	}
+	return config
+}
+
+// The admin of the keys: the one given With_Admin(), else a new one, derived from the seed if any.
+func (config generator_options) admin(caller string) (User, error) {
+	if config.admin_user != nil {
+		if config.admin_user.PublicKey == nil {
+			return User{}, fmt.Errorf("[%s()] %w: the admin has no public key", caller, ErrSign)
+		}
+		return *config.admin_user, nil
+	}
+
+	if config.seed == nil {
+		user, err := NewUser()
+		if err != nil {
+			return User{}, fmt.Errorf("[%s()] generating the admin key: %w", caller, err)
+		}
+		return user, nil
+	}
+
+	return seeded_admin(caller, config.seed)
+}
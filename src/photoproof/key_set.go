@@ -0,0 +1,177 @@
+package photoproof
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	eddsa_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+	"github.com/consensys/gnark-crypto/signature"
+)
+
+// The Merkle tree of authorised camera keys holds up to 2^depth keys. The depth is compiled into the
+// anonymous circuit, see Anonymous_Permissible_Transformations.Keys_Depth.
+const (
+	Default_Keys_Depth = 4
+	Max_Keys_Depth     = 32
+)
+
+// The set of authorised camera public keys, committed to by the root of a MiMC Merkle tree.
+type KeySet struct {
+	PublicKeys []signature.PublicKey
+	Depth      int // Depth of the Merkle tree, Default_Keys_Depth if 0. It must be the Keys_Depth of the circuit
+}
+
+func NewKeySet(public_keys ...signature.PublicKey) *KeySet {
+	return &KeySet{PublicKeys: public_keys}
+}
+
+// The depth of the Merkle tree, checked against Max_Keys_Depth.
+func (set *KeySet) depth() (int, error) {
+	return check_keys_depth(set.Depth)
+}
+
+// The depth of a key set or circuit, Default_Keys_Depth if 0.
+func check_keys_depth(depth int) (int, error) {
+	if depth == 0 {
+		return Default_Keys_Depth, nil
+	}
+	if depth < 0 || depth > Max_Keys_Depth {
+		return 0, fmt.Errorf("the key set depth is %d, it must be in [1, %d]", depth, Max_Keys_Depth)
+	}
+	return depth, nil
+}
+
+// Authorise a camera public key, or fail with ErrKeySetFull.
+func (set *KeySet) Add(public_key signature.PublicKey) error {
+	depth, err := set.depth()
+	if err != nil {
+		return err
+	}
+	if len(set.PublicKeys) >= 1<<depth {
+		return fmt.Errorf("%w: it holds at most %d keys", ErrKeySetFull, 1<<depth)
+	}
+	set.PublicKeys = append(set.PublicKeys, public_key)
+	return nil
+}
+
+func mimc_elements(elements ...fr.Element) fr.Element {
+	h := mimc.NewMiMC()
+	for _, e := range elements {
+		b := e.Marshal()
+		h.Write(b)
+	}
+
+	var digest fr.Element
+	digest.SetBytes(h.Sum(nil))
+	return digest
+}
+
+// The leaf value of a public key: MiMC(A.X, A.Y)
+func key_leaf(public_key signature.PublicKey) (fr.Element, error) {
+	var pk eddsa_bn254.PublicKey
+	if _, err := pk.SetBytes(public_key.Bytes()); err != nil {
+		return fr.Element{}, err
+	}
+	return mimc_elements(pk.A.X, pk.A.Y), nil
+}
+
+// The Merkle tree of the key set, from the hashed leaves (level 0) to the root (level depth).
+// Empty leaves have the value 0. Only the nodes above keys are stored: the others are the empty
+// node of their level, so that deep trees of few keys stay cheap.
+// This tree must have mirror output to the Verify_Camera_Membership() function.
+type key_tree struct {
+	levels [][]fr.Element
+	empty  []fr.Element // The node of each level above empty leaves only
+}
+
+func (set *KeySet) tree() (key_tree, error) {
+	depth, err := set.depth()
+	if err != nil {
+		return key_tree{}, err
+	}
+	if len(set.PublicKeys) > 1<<depth {
+		return key_tree{}, fmt.Errorf("%w: it holds more than %d keys", ErrKeySetFull, 1<<depth)
+	}
+
+	tree := key_tree{levels: make([][]fr.Element, depth+1), empty: make([]fr.Element, depth+1)}
+	tree.empty[0] = mimc_elements(fr.Element{})
+	tree.levels[0] = make([]fr.Element, len(set.PublicKeys))
+	for i, public_key := range set.PublicKeys {
+		leaf, err := key_leaf(public_key)
+		if err != nil {
+			return key_tree{}, err
+		}
+		tree.levels[0][i] = mimc_elements(leaf)
+	}
+
+	for level := 1; level <= depth; level++ {
+		tree.empty[level] = mimc_elements(tree.empty[level-1], tree.empty[level-1])
+		tree.levels[level] = make([]fr.Element, (len(tree.levels[level-1])+1)/2)
+		for i := range tree.levels[level] {
+			tree.levels[level][i] = mimc_elements(tree.node(level-1, 2*i), tree.node(level-1, 2*i+1))
+		}
+	}
+
+	return tree, nil
+}
+
+// The node at index i of a level.
+func (tree key_tree) node(level int, i int) fr.Element {
+	if i < len(tree.levels[level]) {
+		return tree.levels[level][i]
+	}
+	return tree.empty[level]
+}
+
+// The Merkle root of the key set.
+func (set *KeySet) Root() ([]byte, error) {
+	tree, err := set.tree()
+	if err != nil {
+		return nil, err
+	}
+	root := tree.node(len(tree.levels)-1, 0)
+	return root.Marshal(), nil
+}
+
+// The Merkle path of public_key: the leaf index, and the leaf value followed by the sibling of each level,
+// i.e. depth+1 values.
+func (set *KeySet) Path(public_key signature.PublicKey) (uint64, [][]byte, error) {
+	if public_key == nil {
+		return 0, nil, errors.New("no public key")
+	}
+
+	index := -1
+	for i, pk := range set.PublicKeys {
+		if bytes.Equal(pk.Bytes(), public_key.Bytes()) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return 0, nil, errors.New("public key is not in the key set")
+	}
+
+	tree, err := set.tree()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	leaf, err := key_leaf(public_key)
+	if err != nil {
+		return 0, nil, err
+	}
+	path := make([][]byte, len(tree.levels))
+	path[0] = leaf.Marshal()
+
+	node := index
+	for level := 0; level < len(tree.levels)-1; level++ {
+		sibling := tree.node(level, node^1)
+		path[level+1] = sibling.Marshal()
+		node /= 2
+	}
+
+	return uint64(index), path, nil
+}
@@ -0,0 +1,83 @@
+package photoproof_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/signature/eddsa"
+	"github.com/consensys/gnark/test"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+type membership_circuit struct {
+	PublicKey eddsa.PublicKey
+	Root      frontend.Variable `gnark:",public"`
+	Path      []frontend.Variable
+	Index     frontend.Variable
+}
+
+func (circuit membership_circuit) Define(api frontend.API) error {
+	photoproof.Verify_Camera_Membership(api, circuit.PublicKey, circuit.Root, circuit.Path, circuit.Index)
+	return nil
+}
+
+// The Merkle path of a key set must verify in the circuit at any depth, and a full key set must refuse keys.
+func TestKeySetDepth(t *testing.T) {
+	for _, depth := range []int{1, photoproof.Default_Keys_Depth, 20} {
+		key_set := &photoproof.KeySet{Depth: depth}
+		if depth == photoproof.Default_Keys_Depth {
+			key_set.Depth = 0
+		}
+		member := must_user()
+		for _, user := range []photoproof.User{must_user(), member} {
+			if err := key_set.Add(user.PublicKey); err != nil {
+				t.Fatalf("depth %d: %v", depth, err)
+			}
+		}
+
+		root, err := key_set.Root()
+		if err != nil {
+			t.Fatalf("depth %d: %v", depth, err)
+		}
+		index, path, err := key_set.Path(member.PublicKey)
+		if err != nil {
+			t.Fatalf("depth %d: %v", depth, err)
+		}
+		if len(path) != depth+1 {
+			t.Fatalf("depth %d: the path has %d values", depth, len(path))
+		}
+
+		assignment := membership_circuit{Root: root, Path: make([]frontend.Variable, len(path)), Index: index}
+		assignment.PublicKey.Assign(tedwards.BN254, member.PublicKey.Bytes())
+		for i := range path {
+			assignment.Path[i] = path[i]
+		}
+		circuit := membership_circuit{Path: make([]frontend.Variable, depth+1)}
+		if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Errorf("depth %d: member was rejected: %v", depth, err)
+		}
+
+		outsider := must_user()
+		assignment.PublicKey.Assign(tedwards.BN254, outsider.PublicKey.Bytes())
+		if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+			t.Errorf("depth %d: outsider was accepted", depth)
+		}
+	}
+
+	full := &photoproof.KeySet{Depth: 1}
+	for i := 0; i < 2; i++ {
+		if err := full.Add(must_user().PublicKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := full.Add(must_user().PublicKey); !errors.Is(err, photoproof.ErrKeySetFull) {
+		t.Errorf("a third key was added to a key set of depth 1 (err: %v)", err)
+	}
+
+	if _, err := (&photoproof.KeySet{Depth: photoproof.Max_Keys_Depth + 1}).Root(); err == nil {
+		t.Errorf("a key set deeper than Max_Keys_Depth has a root")
+	}
+}
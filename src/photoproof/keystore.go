@@ -0,0 +1,173 @@
+package photoproof
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	eddsa_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+	"golang.org/x/crypto/scrypt"
+)
+
+/*
+Encrypted keystore file of a User, so that a camera or editor keeps a stable identity
+across sessions without leaving the raw EdDSA secret key on disk.
+The secret key is encrypted with AES-256-GCM, under a key derived from a passphrase with scrypt.
+The public key is stored in the clear, and authenticated as additional data.
+*/
+
+// scrypt cost parameters, see https://pkg.go.dev/golang.org/x/crypto/scrypt
+const (
+	keystore_scrypt_N = 1 << 17
+	keystore_scrypt_r = 8
+	keystore_scrypt_p = 1
+)
+
+// Limits on the scrypt parameters read from a keystore, so that a crafted file cannot make LoadUser()
+// allocate more than 1 GiB (128*N*r bytes) or work more than 16 times as long as Save() (N*r*p).
+const (
+	keystore_max_scrypt_N  = 1 << 20
+	keystore_max_scrypt_r  = 8
+	keystore_max_scrypt_rp = 16
+)
+
+func check_scrypt_params(n, r, p int) error {
+	if n <= 1 || n&(n-1) != 0 || n > keystore_max_scrypt_N {
+		return fmt.Errorf("scrypt N=%d is not a power of two in [2, %d]", n, keystore_max_scrypt_N)
+	}
+	if r <= 0 || r > keystore_max_scrypt_r || p <= 0 || p > keystore_max_scrypt_rp/r {
+		return fmt.Errorf("scrypt r=%d and p=%d are out of range, r is at most %d and r*p at most %d", r, p, keystore_max_scrypt_r, keystore_max_scrypt_rp)
+	}
+	return nil
+}
+
+type keystore_file struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+	PublicKey  string `json:"public_key"`
+}
+
+func keystore_aead(passphrase []byte, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, n, r, p, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Write the user's keys to an encrypted keystore file.
+func (user User) Save(path string, passphrase []byte) error {
+	if user.SecretKey == nil {
+		return errors.New("[Save()] user has no secret key")
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	aead, err := keystore_aead(passphrase, salt, keystore_scrypt_N, keystore_scrypt_r, keystore_scrypt_p)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	public_key := user.PublicKey.Bytes()
+	ciphertext := aead.Seal(nil, nonce, user.SecretKey.Bytes(), public_key)
+
+	data, err := json.MarshalIndent(keystore_file{
+		Version:    1,
+		KDF:        "scrypt",
+		N:          keystore_scrypt_N,
+		R:          keystore_scrypt_r,
+		P:          keystore_scrypt_p,
+		Salt:       hex.EncodeToString(salt),
+		Nonce:      hex.EncodeToString(nonce),
+		Ciphertext: hex.EncodeToString(ciphertext),
+		PublicKey:  hex.EncodeToString(public_key),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// The secret key is encrypted, but the file is still only for its owner.
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Read a User from a keystore file written by Save().
+func LoadUser(path string, passphrase []byte) (User, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return User{}, err
+	}
+
+	var file keystore_file
+	if err := json.Unmarshal(data, &file); err != nil {
+		return User{}, err
+	}
+	if file.Version != 1 || file.KDF != "scrypt" {
+		return User{}, fmt.Errorf("[LoadUser()] unsupported keystore version %d with kdf %q", file.Version, file.KDF)
+	}
+
+	var salt, nonce, ciphertext, public_key []byte
+	for _, field := range []struct {
+		dst *[]byte
+		src string
+	}{{&salt, file.Salt}, {&nonce, file.Nonce}, {&ciphertext, file.Ciphertext}, {&public_key, file.PublicKey}} {
+		if *field.dst, err = hex.DecodeString(field.src); err != nil {
+			return User{}, fmt.Errorf("[LoadUser()] malformed keystore: %w", err)
+		}
+	}
+
+	if err := check_scrypt_params(file.N, file.R, file.P); err != nil {
+		return User{}, fmt.Errorf("[LoadUser()] malformed keystore: %w", err)
+	}
+	aead, err := keystore_aead(passphrase, salt, file.N, file.R, file.P)
+	if err != nil {
+		return User{}, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return User{}, errors.New("[LoadUser()] malformed keystore: bad nonce size")
+	}
+
+	secret_key_bytes, err := aead.Open(nil, nonce, ciphertext, public_key)
+	if err != nil {
+		return User{}, errors.New("[LoadUser()] wrong passphrase or corrupted keystore")
+	}
+
+	secret_key := new(eddsa_bn254.PrivateKey)
+	if _, err := secret_key.SetBytes(secret_key_bytes); err != nil {
+		return User{}, fmt.Errorf("[LoadUser()] malformed secret key: %w", err)
+	}
+
+	// The secret key must belong to the stored public key.
+	if !bytes.Equal(secret_key.Public().Bytes(), public_key) {
+		return User{}, errors.New("[LoadUser()] secret key does not match the public key")
+	}
+
+	return User{
+		SecretKey: secret_key,
+		PublicKey: secret_key.Public(),
+	}, nil
+}
@@ -1,7 +1,10 @@
 package photoproof
 
 import (
+	"math/big"
+
 	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/cmp"
 	"github.com/consensys/gnark/std/signature/eddsa"
 	"github.com/drakstik/Photognark_V3/src/image"
 )
@@ -17,7 +20,7 @@ type Fr_Transformation interface {
 	GetName() frontend.Variable
 	GetFlag() frontend.Variable // Either 0 or 1
 	// TODO: When implementing Apply, Check relations between img_in and img_out, relevant for params, and assert they adhere
-	// to ParamsBounds and ProvenanceBounds originally set by the admin.
+	// to ParamsBounds originally set by the admin. ProvenanceBounds are checked by Check_Provenance().
 	Apply(api frontend.API, img_in image.Fr_Z, img_out image.Fr_Z, params Fr_Transformation_Parameters, dig_sig eddsa.Signature) frontend.Variable
 }
 
@@ -47,16 +50,77 @@ func (tr Fr_Identity_Transformation) GetFlag() frontend.Variable {
 // Check that img_in & img_out are equivelant.
 // return 0 if unsuccessful, 1 if successful
 func (id_tr Fr_Identity_Transformation) Apply(api frontend.API, img_in image.Fr_Z, img_out image.Fr_Z, params Fr_Transformation_Parameters, dig_sig eddsa.Signature) frontend.Variable {
-	digest_in, mimc := img_in.Img.Hash(api)   // The hash of the input image
-	digest_out, mimc := img_out.Img.Hash(api) // The hash of the output image
+	// Both images are signed, see Check_Transformation(), so comparing their pixels is enough.
+	// Do not assert here: the result only counts if the flag is on.
+	ok := frontend.Variable(1)
+	for i := 0; i < int(image.N2); i++ {
+		px_in, px_out := img_in.Img.Pxls[i], img_out.Img.Pxls[i]
+
+		for c := 0; c < 3; c++ {
+			ok = api.And(ok, api.IsZero(api.Sub(px_in.RGB[c], px_out.RGB[c])))
+		}
+		ok = api.And(ok, api.IsZero(api.Sub(px_in.Loc.X, px_out.Loc.X)))
+		ok = api.And(ok, api.IsZero(api.Sub(px_in.Loc.Y, px_out.Loc.Y)))
+	}
+
+	return ok
+}
+
+/*------------------------------------------ Brightness --------------------------------------*/
+
+// The delta lives in the transformation rather than in Fr_Brightness_Tr_Params, because the circuit
+// has a single Parameters field and its shape must not depend on the chosen transformation.
+type Fr_Brightness_Tr_Params struct{}
+
+func (params Fr_Brightness_Tr_Params) GetName() frontend.Variable {
+	return frontend.Variable([]byte("brightness"))
+}
+
+type Fr_Brightness_Transformation struct {
+	Flag  frontend.Variable
+	Delta frontend.Variable // Delta + 255, so that it is in [0, 510]
+}
+
+func (tr Fr_Brightness_Transformation) GetName() frontend.Variable {
+	return frontend.Variable([]byte("brightness"))
+}
+
+// Either 0 or 1
+func (tr Fr_Brightness_Transformation) GetFlag() frontend.Variable {
+	return tr.Flag
+}
+
+// The absolute brightness shift, in intensity levels.
+func (br_tr Fr_Brightness_Transformation) Shift(api frontend.API) frontend.Variable {
+	comparator := cmp.NewBoundedComparator(api, big.NewInt(1<<10), false)
+	darker := comparator.IsLess(br_tr.Delta, 255)
+	return api.Select(darker, api.Sub(255, br_tr.Delta), api.Sub(br_tr.Delta, 255))
+}
+
+// Check that every channel of img_out is the channel of img_in shifted by Delta, clamped to [0, 255].
+// return 0 if unsuccessful, 1 if successful
+func (br_tr Fr_Brightness_Transformation) Apply(api frontend.API, img_in image.Fr_Z, img_out image.Fr_Z, params Fr_Transformation_Parameters, dig_sig eddsa.Signature) frontend.Variable {
+	// Values stay below 2^10: channels are at most 255, the shifted delta at most 510.
+	api.ToBinary(br_tr.Delta, 10)
+	comparator := cmp.NewBoundedComparator(api, big.NewInt(1<<10), false)
+	comparator.AssertIsLessEq(br_tr.Delta, 510)
+
+	ok := frontend.Variable(1)
+	for i := 0; i < int(image.N2); i++ {
+		px_in, px_out := img_in.Img.Pxls[i], img_out.Img.Pxls[i]
 
-	// Check if both hashes match against the same signature
-	ok1 := Verify_Signature(api, digest_in, dig_sig, img_in.PublicKey, mimc)
-	ok2 := Verify_Signature(api, digest_out, dig_sig, img_out.PublicKey, mimc)
+		for c := 0; c < 3; c++ {
+			shifted := api.Add(px_in.RGB[c], br_tr.Delta) // Channel + delta + 255
+			expected := api.Select(
+				comparator.IsLessEq(shifted, 255), 0,
+				api.Select(comparator.IsLessEq(510, shifted), 255, api.Sub(shifted, 255)),
+			)
+			ok = api.And(ok, api.IsZero(api.Sub(px_out.RGB[c], expected)))
+		}
 
-	// Assert that both input & output images successfully verified against the same signature
-	api.AssertIsEqual(1, ok1)
-	api.AssertIsEqual(1, ok2)
+		ok = api.And(ok, api.IsZero(api.Sub(px_in.Loc.X, px_out.Loc.X)))
+		ok = api.And(ok, api.IsZero(api.Sub(px_in.Loc.Y, px_out.Loc.Y)))
+	}
 
-	return 1
+	return ok
 }
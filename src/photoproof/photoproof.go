@@ -14,18 +14,41 @@ type Permissible_Transformations struct {
 	Signature  eddsa.Signature              `gnark:",public"` // Either the original signature, or the output signature after a transformation
 	Parameters Fr_Transformation_Parameters `gnark:",secret"`
 
+	// The signature of the Input: the camera's of its image if it is an original, else its editor's of its Edit_Digest()
+	Input_Signature eddsa.Signature `gnark:",secret"`
+
 	// TODO: use an array of Fr_Transformations instead of a specific transformation
-	Identity Fr_Identity_Transformation `gnark:",secret"`
+	Identity   Fr_Identity_Transformation   `gnark:",secret"`
+	Brightness Fr_Brightness_Transformation `gnark:",secret"`
 
 	Case_1 frontend.Variable `gnark:",secret"` // 1 if there is NO Input. Otherwise 0.
+
+	// A claim about the hidden capture location and time of the Output, see Predicate
+	Predicate Fr_Predicate `gnark:",public"`
+	Location  Fr_Location  `gnark:",secret"`
+
+	// Limits on the edits of a chain and on each edit, compiled into the circuit, see Policy
+	Bounds    ProvenanceBounds `gnark:"-"`
+	Params    ParamsBounds     `gnark:"-"`
+	Policy_ID []byte           `gnark:"-"` // Hash of the policy the limits were read from, nil if none
+
+	// The hash of the images and of their signatures, compiled into the circuit like the limits
+	Hash_Function image.Hash_Function `gnark:"-"`
+
+	Policy_Hash frontend.Variable `gnark:",public"` // Must be Policy_Hash(Policy_ID)
 }
 
 func (circuit Permissible_Transformations) Define(api frontend.API) error {
+	// Every image of the circuit is hashed with the hash function of the keys
+	circuit.Input.Img.Hash_Function = circuit.Hash_Function
+	circuit.Output.Img.Hash_Function = circuit.Hash_Function
+
 	/*
 		Case 1: Verify the Output.Img against the signature, using the output's public key
 		Case 2:
 				a) Check that transformation from Input to Output is permissible
 				b) Check that Input public key == Output public key
+				c) Check that the Input is signed by the camera or by the editor of its last edit
 	*/
 	ok := api.Select(
 		circuit.Case_1,
@@ -36,5 +59,17 @@ func (circuit Permissible_Transformations) Define(api frontend.API) error {
 	// Assert that VerifySignature or CheckTransformation return 1
 	api.AssertIsEqual(ok, 1)
 
+	// Record the editor of this proof in the output
+	Check_Editors(api, circuit)
+
+	// Count this proof in the provenance of the output, and check the admin's limits
+	Check_Provenance(api, circuit)
+
+	// Check that the transformation is enabled by the admin's policy, with permissible parameters
+	Check_Policy(api, circuit)
+
+	// Check the claimed predicate over the hidden capture fields of the output
+	Check_Predicate(api, circuit.Predicate, circuit.Location, circuit.Output)
+
 	return nil
 }
@@ -0,0 +1,165 @@
+package photoproof
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/cmp"
+	"github.com/drakstik/Photognark_V3/src/image"
+)
+
+/*
+A policy file declares what is permissible for a deployment: the enabled transformations, their parameter
+bounds and the provenance limits of a chain. For example, "at most one brightness edit of at most 20%":
+
+	{
+	  "name": "newsroom",
+	  "transformations": {
+	    "identity": {},
+	    "brightness": {"max_uses": 1, "max_delta": 51}
+	  },
+	  "max_brightness": 51
+	}
+
+Apply() compiles the policy into the circuit before Generator(), and binds its Hash() to a public input, so
+the admin can publish "this key enforces policy X": proofs of the key only verify with Policy_ID == X.Hash().
+*/
+type Policy struct {
+	Name            string                           `json:"name"`
+	Transformations map[string]Transformation_Policy `json:"transformations"` // Enabled transformations, by name
+	Max_Edits       uint64                           `json:"max_edits,omitempty"`
+	Max_Brightness  uint64                           `json:"max_brightness,omitempty"` // Cumulative absolute brightness shift
+	Hash_Function   image.Hash_Function              `json:"hash,omitempty"`           // Of image digests and signatures, MiMC if omitted
+}
+
+// The bounds of one enabled transformation. Zero fields are not checked.
+type Transformation_Policy struct {
+	Max_Uses  uint64 `json:"max_uses,omitempty"`
+	Max_Delta uint64 `json:"max_delta,omitempty"` // Largest brightness shift of one edit, in intensity levels
+}
+
+// Limits on the parameters of each edit, set by the admin like ProvenanceBounds. Zero fields are not checked.
+type ParamsBounds struct {
+	Disabled             [image.Provenance_Transformations]bool // Transformations that are not permissible, see Transformation_Index()
+	Max_Brightness_Delta uint64                                 // Largest brightness shift of one edit, in intensity levels
+}
+
+// Read a policy file.
+func LoadPolicy(path string) (Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Policy{}, err
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return Policy{}, fmt.Errorf("policy %s: %w", path, err)
+	}
+
+	if err := policy.Check(); err != nil {
+		return Policy{}, fmt.Errorf("policy %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// Write the policy to a JSON file.
+func (policy Policy) Save(path string) error {
+	data, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Check that the policy only names transformations and bounds that the circuit knows.
+func (policy Policy) Check() error {
+	if len(policy.Transformations) == 0 {
+		return errors.New("no transformation is enabled")
+	}
+
+	for name, tr_policy := range policy.Transformations {
+		if Transformation_Index(name) < 0 {
+			return fmt.Errorf("unknown transformation %q", name)
+		}
+		if tr_policy.Max_Delta != 0 && name != "brightness" {
+			return fmt.Errorf("transformation %q has no delta", name)
+		}
+		if tr_policy.Max_Delta > 255 {
+			return fmt.Errorf("max delta of %q is above 255", name)
+		}
+	}
+	return nil
+}
+
+// The hash of the policy, independent of the formatting of its file.
+func (policy Policy) Hash() ([]byte, error) {
+	data, err := json.Marshal(policy) // Map keys are sorted
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(data)
+	return hash[:], nil
+}
+
+// Compile the policy into the constants of circuit, see Permissible_Transformations.
+func (policy Policy) Apply(circuit *Permissible_Transformations) error {
+	if err := policy.Check(); err != nil {
+		return err
+	}
+
+	policy_id, err := policy.Hash()
+	if err != nil {
+		return err
+	}
+
+	circuit.Bounds = ProvenanceBounds{Max_Edits: policy.Max_Edits, Max_Brightness: policy.Max_Brightness}
+	circuit.Params = ParamsBounds{}
+	for i := range circuit.Params.Disabled {
+		circuit.Params.Disabled[i] = true
+	}
+
+	for name, tr_policy := range policy.Transformations {
+		i := Transformation_Index(name)
+		circuit.Params.Disabled[i] = false
+		circuit.Bounds.Max_Uses[i] = tr_policy.Max_Uses
+		if name == "brightness" {
+			circuit.Params.Max_Brightness_Delta = tr_policy.Max_Delta
+		}
+	}
+
+	circuit.Hash_Function = policy.Hash_Function
+	circuit.Policy_ID = policy_id
+	return nil
+}
+
+// The policy hash as a field element, 0 if there is no policy.
+func Policy_Hash(policy_id []byte) frontend.Variable {
+	return new(big.Int).Mod(new(big.Int).SetBytes(policy_id), ecc.BN254.ScalarField())
+}
+
+// Check that the transformation of this proof is enabled and within the parameter bounds,
+// and that the public Policy_Hash is the policy compiled into the circuit.
+func Check_Policy(api frontend.API, circuit Permissible_Transformations) {
+	api.AssertIsEqual(circuit.Policy_Hash, Policy_Hash(circuit.Policy_ID))
+
+	flags := [image.Provenance_Transformations]frontend.Variable{}
+	flags[Transformation_Index("identity")] = circuit.Identity.Flag
+	flags[Transformation_Index("brightness")] = circuit.Brightness.Flag
+	for i, disabled := range circuit.Params.Disabled {
+		if disabled {
+			api.AssertIsEqual(flags[i], 0)
+		}
+	}
+
+	if circuit.Params.Max_Brightness_Delta != 0 {
+		comparator := cmp.NewBoundedComparator(api, big.NewInt(1<<10), false)
+		comparator.AssertIsLessEq(api.Mul(circuit.Brightness.Flag, circuit.Brightness.Shift(api)), circuit.Params.Max_Brightness_Delta)
+	}
+}
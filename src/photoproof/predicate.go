@@ -0,0 +1,177 @@
+package photoproof
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+	"github.com/consensys/gnark/std/math/cmp"
+	"github.com/drakstik/Photognark_V3/src/image"
+)
+
+/*
+Predicates are claims about the hidden capture fields of the output, e.g. "this photo was taken inside
+this bounding box, after date D". The circuit opens the location leaves of the signed metadata commitment
+with secret values, and checks the ranges; only the bounds and whether the predicate holds are public.
+
+The capture time is only hidden by the private circuit: the main circuit has Output.Timestamp as a public
+input, so a time bound would prove "after date D" while revealing the exact time. Prove_Predicate() and
+Verify_Predicate() therefore refuse After and Before with ErrPublicTime, use Prove_Private_Predicate().
+*/
+
+// Every predicate value (microdegrees, unix seconds) fits in Predicate_Bits bits.
+const Predicate_Bits = 40
+
+// A bounding box, in degrees.
+type BoundingBox struct {
+	MinLatitude  float64
+	MaxLatitude  float64
+	MinLongitude float64
+	MaxLongitude float64
+}
+
+// A claim about the capture location and time of a photo. Zero fields are not checked.
+type Predicate struct {
+	Box    *BoundingBox
+	After  time.Time // Taken at or after
+	Before time.Time // Taken at or before
+}
+
+// Gnark-friendly predicate, public.
+type Fr_Predicate struct {
+	Holds        frontend.Variable // 1 if the predicate is claimed. If 0, nothing is checked.
+	MinLatitude  frontend.Variable // Microdegrees, see image.Microdegrees()
+	MaxLatitude  frontend.Variable
+	MinLongitude frontend.Variable
+	MaxLongitude frontend.Variable
+	After        frontend.Variable // Unix seconds
+	Before       frontend.Variable
+}
+
+// Opening of the location leaves of the output's metadata commitment, secret.
+type Fr_Location struct {
+	Latitude       frontend.Variable
+	Longitude      frontend.Variable
+	Latitude_Salt  frontend.Variable
+	Longitude_Salt frontend.Variable
+	Leaves         [image.Metadata_Size - 2]frontend.Variable // The leaves of the other metadata fields
+}
+
+func (predicate Predicate) ToFr() Fr_Predicate {
+	box := BoundingBox{MinLatitude: -180, MaxLatitude: 180, MinLongitude: -180, MaxLongitude: 180}
+	if predicate.Box != nil {
+		box = *predicate.Box
+	}
+
+	after := uint64(0)
+	if !predicate.After.IsZero() {
+		after = uint64(predicate.After.Unix())
+	}
+	before := uint64(1)<<Predicate_Bits - 1
+	if !predicate.Before.IsZero() {
+		before = uint64(predicate.Before.Unix())
+	}
+
+	return Fr_Predicate{
+		Holds:        frontend.Variable(1),
+		MinLatitude:  frontend.Variable(image.Microdegrees(box.MinLatitude)),
+		MaxLatitude:  frontend.Variable(image.Microdegrees(box.MaxLatitude)),
+		MinLongitude: frontend.Variable(image.Microdegrees(box.MinLongitude)),
+		MaxLongitude: frontend.Variable(image.Microdegrees(box.MaxLongitude)),
+		After:        frontend.Variable(after),
+		Before:       frontend.Variable(before),
+	}
+}
+
+// Refuse a time bound in the main circuit, where the capture time is public, see ErrPublicTime.
+func (predicate Predicate) location_only(caller string) error {
+	if !predicate.After.IsZero() || !predicate.Before.IsZero() {
+		return fmt.Errorf("[%s()] %w: prove a time predicate with Prove_Private_Predicate()", caller, ErrPublicTime)
+	}
+	return nil
+}
+
+// Check the predicate natively, so that a prover fails early instead of in the circuit.
+func (predicate Predicate) Check(metadata image.Metadata, capture_time time.Time) error {
+	if box := predicate.Box; box != nil {
+		if metadata.Latitude < box.MinLatitude || metadata.Latitude > box.MaxLatitude ||
+			metadata.Longitude < box.MinLongitude || metadata.Longitude > box.MaxLongitude {
+			return errors.New("photo was not taken inside the bounding box")
+		}
+	}
+	// The circuit compares unix seconds
+	if !predicate.After.IsZero() && capture_time.Unix() < predicate.After.Unix() {
+		return fmt.Errorf("photo was taken before %s", predicate.After)
+	}
+	if !predicate.Before.IsZero() && capture_time.Unix() > predicate.Before.Unix() {
+		return fmt.Errorf("photo was taken after %s", predicate.Before)
+	}
+	return nil
+}
+
+// No claim: every value is zero. Use it to assign a circuit without a predicate.
+func No_Predicate() (Fr_Predicate, Fr_Location) {
+	location := Fr_Location{Latitude: 0, Longitude: 0, Latitude_Salt: 0, Longitude_Salt: 0}
+	for i := range location.Leaves {
+		location.Leaves[i] = 0
+	}
+	return Fr_Predicate{Holds: 0, MinLatitude: 0, MaxLatitude: 0, MinLongitude: 0, MaxLongitude: 0, After: 0, Before: 0}, location
+}
+
+// The secret opening of the location, from the metadata opening kept by the camera.
+func Location_Opening(opening image.Metadata_Opening) (Fr_Location, error) {
+	leaves, err := opening.Leaves()
+	if err != nil {
+		return Fr_Location{}, err
+	}
+
+	location := Fr_Location{
+		Latitude:       frontend.Variable(image.Microdegrees(opening.Metadata.Latitude)),
+		Longitude:      frontend.Variable(image.Microdegrees(opening.Metadata.Longitude)),
+		Latitude_Salt:  frontend.Variable(opening.Salts[0]),
+		Longitude_Salt: frontend.Variable(opening.Salts[1]),
+	}
+	for i := range location.Leaves {
+		location.Leaves[i] = frontend.Variable(leaves[i+2])
+	}
+
+	return location, nil
+}
+
+// Check that the predicate holds for the location and capture time of z, if it is claimed.
+func Check_Predicate(api frontend.API, predicate Fr_Predicate, location Fr_Location, z image.Fr_Z) {
+	api.AssertIsBoolean(predicate.Holds)
+
+	// Open the location leaves of the metadata commitment, see image.metadata_leaf()
+	leaf := func(salt, value frontend.Variable) frontend.Variable {
+		h, _ := mimc.NewMiMC(api)
+		h.Write(salt, value)
+		return h.Sum()
+	}
+	h, _ := mimc.NewMiMC(api)
+	h.Write(leaf(location.Latitude_Salt, location.Latitude), leaf(location.Longitude_Salt, location.Longitude))
+	h.Write(location.Leaves[:]...)
+	opened := api.IsZero(api.Sub(h.Sum(), z.MetadataCommitment))
+
+	// The bounded comparator is only sound on small values, so range check all of them.
+	for _, v := range []frontend.Variable{
+		location.Latitude, location.Longitude, z.Timestamp,
+		predicate.MinLatitude, predicate.MaxLatitude, predicate.MinLongitude, predicate.MaxLongitude,
+		predicate.After, predicate.Before,
+	} {
+		api.ToBinary(v, Predicate_Bits)
+	}
+	comparator := cmp.NewBoundedComparator(api, new(big.Int).Lsh(big.NewInt(1), Predicate_Bits), false)
+
+	ok := api.And(opened, api.And(
+		api.And(comparator.IsLessEq(predicate.MinLatitude, location.Latitude), comparator.IsLessEq(location.Latitude, predicate.MaxLatitude)),
+		api.And(comparator.IsLessEq(predicate.MinLongitude, location.Longitude), comparator.IsLessEq(location.Longitude, predicate.MaxLongitude)),
+	))
+	ok = api.And(ok, api.And(comparator.IsLessEq(predicate.After, z.Timestamp), comparator.IsLessEq(z.Timestamp, predicate.Before)))
+
+	// Holds => ok
+	api.AssertIsEqual(api.Mul(predicate.Holds, api.Sub(1, ok)), 0)
+}
@@ -0,0 +1,77 @@
+package photoproof_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/image"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+var united_states = photoproof.BoundingBox{MinLatitude: 24.396308, MaxLatitude: 49.384358, MinLongitude: -125.0, MaxLongitude: -66.93457}
+var france = photoproof.BoundingBox{MinLatitude: 41.303921, MaxLatitude: 51.124199, MinLongitude: -5.142222, MaxLongitude: 9.561556}
+
+// The circuit of an original photo taken in New York must reject false claims about its hidden location and time,
+// and a forged location opening.
+func TestPredicateCircuit(t *testing.T) {
+	cam := camera.Camera{Admin: must_user()}
+	cam.Metadata = image.Metadata{Latitude: 40.712776, Longitude: -74.005974, DeviceID: "camera-0001", Lens: "35mm f/1.8"}
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Unix(int64(photo.Z.Timestamp), 0).Add(-24 * time.Hour)
+	forged := *photo.Metadata
+	forged.Metadata.Latitude, forged.Metadata.Longitude = 48.856613, 2.352222
+
+	for _, tc := range []struct {
+		name      string
+		predicate photoproof.Predicate
+		opening   image.Metadata_Opening
+		expect_ok bool
+	}{
+		{"taken in the US after a day", photoproof.Predicate{Box: &united_states, After: day}, *photo.Metadata, true},
+		{"taken in France", photoproof.Predicate{Box: &france}, *photo.Metadata, false},
+		{"taken after tomorrow", photoproof.Predicate{After: time.Now().Add(24 * time.Hour)}, *photo.Metadata, false},
+		{"taken before yesterday", photoproof.Predicate{Before: day}, *photo.Metadata, false},
+		{"forged location in France", photoproof.Predicate{Box: &france}, forged, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assignment := tamper_assignment(photo, photo, true)
+			assignment.Predicate = tc.predicate.ToFr()
+			if assignment.Location, err = photoproof.Location_Opening(tc.opening); err != nil {
+				t.Fatal(err)
+			}
+
+			err := test.IsSolved(&photoproof.Permissible_Transformations{}, &assignment, ecc.BN254.ScalarField())
+			if (err == nil) != tc.expect_ok {
+				t.Errorf("solved=%t, expected %t (err: %v)", err == nil, tc.expect_ok, err)
+			}
+		})
+	}
+}
+
+// The capture time is public in the main circuit, so Prove_Predicate() and Verify_Predicate() refuse a time bound,
+// before proving or verifying anything.
+func TestPredicatePublicTime(t *testing.T) {
+	cam := camera.Camera{Admin: must_user()}
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, predicate := range []photoproof.Predicate{{After: time.Unix(0, 0)}, {Box: &united_states, Before: time.Now()}} {
+		_, _, err := cam.Admin.Prove_Predicate(photoproof.ProverKeys{}, photo.Z, *photo.Metadata, predicate, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, photo.Proof)
+		if !errors.Is(err, photoproof.ErrPublicTime) {
+			t.Errorf("Prove_Predicate(): %v, expected ErrPublicTime", err)
+		}
+		if _, err := cam.Admin.Verify_Predicate(photoproof.VerifierKeys{}, photo.Z, photo.Proof, predicate); !errors.Is(err, photoproof.ErrPublicTime) {
+			t.Errorf("Verify_Predicate(): %v, expected ErrPublicTime", err)
+		}
+	}
+}
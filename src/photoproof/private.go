@@ -0,0 +1,262 @@
+package photoproof
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+	"github.com/consensys/gnark/std/signature/eddsa"
+	"github.com/drakstik/Photognark_V3/src/image"
+)
+
+/*
+Private circuit (privacy mode): the same permissible transformations as the main circuit, but the original
+hash, the original signature, the capture time, the counter, the metadata commitment and the provenance stay
+secret, and the original signature is only checked inside the circuit. The verifier learns the image, the trusted
+camera key and, optionally, a Predicate about the hidden capture fields; an edited image does not link
+back to its original. For a key-set root instead of a camera key, see the anonymous circuit.
+*/
+type Private_Permissible_Transformations struct {
+	Input           image.Fr_Z                   `gnark:",secret"`
+	Output          image.Fr_Z                   `gnark:",secret"`
+	Signature       eddsa.Signature              `gnark:",secret"`
+	Parameters      Fr_Transformation_Parameters `gnark:",secret"`
+	Identity        Fr_Identity_Transformation   `gnark:",secret"`
+	Brightness      Fr_Brightness_Transformation `gnark:",secret"`
+	Case_1          frontend.Variable            `gnark:",secret"`
+	Input_Signature eddsa.Signature              `gnark:",secret"` // See Permissible_Transformations
+	Location        Fr_Location                  `gnark:",secret"`
+
+	Img       image.Fr_Image  `gnark:",public"` // Output image
+	PublicKey eddsa.PublicKey `gnark:",public"` // Output (camera) public key
+	Predicate Fr_Predicate    `gnark:",public"`
+
+	// See Permissible_Transformations
+	Bounds        ProvenanceBounds    `gnark:"-"`
+	Params        ParamsBounds        `gnark:"-"`
+	Policy_ID     []byte              `gnark:"-"`
+	Hash_Function image.Hash_Function `gnark:"-"`
+	Policy_Hash   frontend.Variable   `gnark:",public"`
+}
+
+func (circuit Private_Permissible_Transformations) Define(api frontend.API) error {
+	// Run the main circuit on the hidden values
+	main := Permissible_Transformations{
+		Input:           circuit.Input,
+		Output:          circuit.Output,
+		Signature:       circuit.Signature,
+		Parameters:      circuit.Parameters,
+		Identity:        circuit.Identity,
+		Brightness:      circuit.Brightness,
+		Case_1:          circuit.Case_1,
+		Input_Signature: circuit.Input_Signature,
+		Predicate:       circuit.Predicate,
+		Location:        circuit.Location,
+		Bounds:          circuit.Bounds,
+		Params:          circuit.Params,
+		Policy_ID:       circuit.Policy_ID,
+		Policy_Hash:     circuit.Policy_Hash,
+
+		Hash_Function: circuit.Hash_Function,
+	}
+	if err := main.Define(api); err != nil {
+		return err
+	}
+
+	// The editors are hidden from the verifier, so the camera must have signed every edit.
+	Check_Camera_Editor(api, main)
+
+	// Bind the public image and public key to the hidden output
+	for i := 0; i < int(image.N2); i++ {
+		for c := 0; c < 3; c++ {
+			api.AssertIsEqual(circuit.Img.Pxls[i].RGB[c], circuit.Output.Img.Pxls[i].RGB[c])
+		}
+		api.AssertIsEqual(circuit.Img.Pxls[i].Loc.X, circuit.Output.Img.Pxls[i].Loc.X)
+		api.AssertIsEqual(circuit.Img.Pxls[i].Loc.Y, circuit.Output.Img.Pxls[i].Loc.Y)
+	}
+	api.AssertIsEqual(circuit.PublicKey.A.X, circuit.Output.PublicKey.A.X)
+	api.AssertIsEqual(circuit.PublicKey.A.Y, circuit.Output.PublicKey.A.Y)
+
+	return nil
+}
+
+// Generate the keys of the private circuit, and a new admin unless one is given With_Admin().
+func Generator_Private(circuit *Private_Permissible_Transformations, options ...Generator_Option) (ProverKeys, VerifierKeys, User, error) {
+	config := generator_config(options)
+
+	user, err := config.admin("Generator_Private")
+	if err != nil {
+		return ProverKeys{}, VerifierKeys{}, User{}, err
+	}
+
+	provingKey, verifyingKey, err := setup("Generator_Private", circuit, config.seed)
+	if err != nil {
+		return ProverKeys{}, VerifierKeys{}, User{}, err
+	}
+
+	return ProverKeys{ProvingKey: provingKey, Original_PublicKey: user.PublicKey, Bounds: circuit.Bounds, Params: circuit.Params, Policy_ID: circuit.Policy_ID, Hash_Function: circuit.Hash_Function},
+		VerifierKeys{VerifyingKey: verifyingKey, Original_PublicKey: user.PublicKey, Policy_ID: circuit.Policy_ID, Hash_Function: circuit.Hash_Function},
+		user, nil
+}
+
+// Assign the private circuit, wrapping the assignment of the main circuit.
+func (user User) assign_private(prover ProverKeys, z_in image.Z, tr Transformation, params Transformation_Parameters, proof_in Proof) (Private_Permissible_Transformations, image.Z, []byte, error) {
+	main, z_out, signature_out, err := user.assign(prover, z_in, tr, params, proof_in)
+	if err != nil {
+		return Private_Permissible_Transformations{}, image.Z{}, nil, err
+	}
+
+	circuit := Private_Permissible_Transformations{
+		Input:           main.Input,
+		Output:          main.Output,
+		Signature:       main.Signature,
+		Parameters:      main.Parameters,
+		Identity:        main.Identity,
+		Brightness:      main.Brightness,
+		Case_1:          main.Case_1,
+		Input_Signature: main.Input_Signature,
+		Location:        main.Location,
+
+		Bounds:      main.Bounds,
+		Params:      main.Params,
+		Policy_ID:   main.Policy_ID,
+		Policy_Hash: main.Policy_Hash,
+		Img:         main.Output.Img,
+		PublicKey:   main.Output.PublicKey,
+		Predicate:   main.Predicate,
+
+		Hash_Function: main.Hash_Function,
+	}
+
+	return circuit, z_out, signature_out, nil
+}
+
+// Like Prove(), but with the private circuit. z_out still holds the original hash and signature,
+// which the next edit needs; only publish z_out.Private() and the PCD_Proof.
+func (user User) Prove_Private(prover ProverKeys, z_in image.Z, tr Transformation, params Transformation_Parameters, proof_in Proof) (image.Z, Proof, error) {
+	circuit, z_out, signature_out, err := user.assign_private(prover, z_in, tr, params, proof_in)
+	if err != nil {
+		return image.Z{}, Proof{}, err
+	}
+
+	pcd_proof_out, err := prove_circuit(&circuit, prover.ProvingKey, nil)
+	if err != nil {
+		return image.Z{}, Proof{}, err
+	}
+
+	return z_out, Proof{
+		PCD_Proof: pcd_proof_out,
+		Signature: signature_out, // Secret, do not publish
+	}, nil
+}
+
+// Like Prove_Predicate(), but with the private circuit, so that the capture time stays hidden too.
+func (user User) Prove_Private_Predicate(prover ProverKeys, z_in image.Z, opening image.Metadata_Opening, predicate Predicate, tr Transformation, params Transformation_Parameters, proof_in Proof) (image.Z, Proof, error) {
+	if err := predicate.Check(opening.Metadata, time.Unix(int64(z_in.Timestamp), 0)); err != nil {
+		return image.Z{}, Proof{}, fmt.Errorf("[Prove_Private_Predicate()] %w", err)
+	}
+
+	circuit, z_out, signature_out, err := user.assign_private(prover, z_in, tr, params, proof_in)
+	if err != nil {
+		return image.Z{}, Proof{}, err
+	}
+
+	circuit.Predicate = predicate.ToFr()
+	circuit.Location, err = Location_Opening(opening)
+	if err != nil {
+		return image.Z{}, Proof{}, fmt.Errorf("[Prove_Private_Predicate()] %w", err)
+	}
+
+	pcd_proof_out, err := prove_circuit(&circuit, prover.ProvingKey, nil)
+	if err != nil {
+		return image.Z{}, Proof{}, err
+	}
+
+	return z_out, Proof{
+		PCD_Proof: pcd_proof_out,
+		Signature: signature_out, // Secret, do not publish
+	}, nil
+}
+
+// Verify a private photo: only z_in.Img, z_in.PublicKey and proof_in.PCD_Proof are used.
+// The capture time is hidden, so the camera key is checked at verification time, and a TimePolicy
+// cannot be applied; use Verify_Private_Predicate() with a time window instead.
+func (user User) Verify_Private(verifier_keys VerifierKeys, z_in image.Z, proof_in Proof) (bool, error) {
+	fr_predicate, _ := No_Predicate()
+	return user.verify_private(verifier_keys, z_in, proof_in, fr_predicate)
+}
+
+// Verify a private photo, and that predicate holds for its hidden capture location and time.
+func (user User) Verify_Private_Predicate(verifier_keys VerifierKeys, z_in image.Z, proof_in Proof, predicate Predicate) (bool, error) {
+	return user.verify_private(verifier_keys, z_in, proof_in, predicate.ToFr())
+}
+
+func (user User) verify_private(verifier_keys VerifierKeys, z_in image.Z, proof_in Proof, fr_predicate Fr_Predicate) (bool, error) {
+	if proof_in.PCD_Proof == nil {
+		return false, fmt.Errorf("[Verify_Private()] %w: a private photo needs a PCD proof", ErrMalformed)
+	}
+	if verifier_keys.TimePolicy != nil {
+		return false, errors.New("[Verify_Private()] the capture time of a private photo is hidden, use a predicate instead of a time policy")
+	}
+	if verifier_keys.Editors != nil {
+		return false, fmt.Errorf("[Verify_Private()] %w: the editors of a private photo are hidden", ErrUntrustedKey)
+	}
+	if z_in.Img.Hash_Function != verifier_keys.Hash_Function {
+		return false, fmt.Errorf("[Verify_Private()] %w: the image is hashed with %s, the keys with %s", ErrMalformed, z_in.Img.Hash_Function, verifier_keys.Hash_Function)
+	}
+
+	// The photo must claim a camera public key that the verifier trusts.
+	if _, err := verifier_keys.camera_public_key(z_in.PublicKey, nil, time.Now()); err != nil {
+		return false, fmt.Errorf("[Verify_Private()] %w", err)
+	}
+
+	// The secret values do not matter when verifying, but they cannot be nil.
+	fr_z_in := private_fr_z(z_in)
+	_, location := No_Predicate()
+	circuit := Private_Permissible_Transformations{
+		Input:           fr_z_in,
+		Output:          fr_z_in,
+		Signature:       eddsa.Signature{R: twistededwards.Point{X: 0, Y: 0}, S: 0},
+		Parameters:      Fr_Identity_Tr_Params{},
+		Identity:        Fr_Identity_Transformation{Flag: frontend.Variable(0)},
+		Brightness:      Fr_Brightness_Transformation{Flag: frontend.Variable(0), Delta: frontend.Variable(0)},
+		Case_1:          frontend.Variable(0),
+		Input_Signature: eddsa.Signature{R: twistededwards.Point{X: 0, Y: 0}, S: 0},
+		Location:        location,
+
+		Img:       fr_z_in.Img,       // Public values
+		PublicKey: fr_z_in.PublicKey, // Public values
+		Predicate: fr_predicate,      // Public values
+
+		Policy_Hash: Policy_Hash(verifier_keys.Policy_ID), // Public values
+	}
+
+	if err := verify_circuit(&circuit, proof_in.PCD_Proof, verifier_keys.VerifyingKey); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// An Fr_Z of the public values of a private photo, with zeroes for everything else.
+func private_fr_z(z image.Z) image.Fr_Z {
+	var eddsa_PK eddsa.PublicKey
+	eddsa_PK.Assign(1, z.PublicKey.Bytes())
+
+	return image.Fr_Z{
+		Img:               z.Img.ToFr(),
+		PublicKey:         eddsa_PK,
+		OriginalSignature: eddsa.Signature{R: twistededwards.Point{X: 0, Y: 0}, S: 0},
+		OriginalHash:      frontend.Variable(0),
+		Timestamp:         frontend.Variable(0),
+		Counter:           frontend.Variable(0),
+
+		MetadataCommitment: frontend.Variable(0),
+		Provenance:         image.Provenance{}.ToFr(),
+
+		Editor:  eddsa.PublicKey{A: twistededwards.Point{X: 0, Y: 0}},
+		Editors: frontend.Variable(0),
+	}
+}
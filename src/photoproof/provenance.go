@@ -0,0 +1,68 @@
+package photoproof
+
+import (
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/cmp"
+	"github.com/drakstik/Photognark_V3/src/image"
+)
+
+// Limits on the edits of a chain, set by the admin when generating the keys. They are constants of the
+// circuit, so the verifying key enforces them. Zero fields are not checked.
+type ProvenanceBounds struct {
+	Max_Edits      uint64                                   // Total number of edits
+	Max_Uses       [image.Provenance_Transformations]uint64 // Per transformation, see Transformation_Index()
+	Max_Brightness uint64                                   // Cumulative absolute brightness shift, in intensity levels (51 is 20%)
+}
+
+// The provenance of z_in after applying tr with params.
+func Next_Provenance(provenance image.Provenance, tr Transformation, params Transformation_Parameters) image.Provenance {
+	provenance.Edits++
+	if i := Transformation_Index(tr.GetName()); i >= 0 {
+		provenance.Uses[i]++
+	}
+	if br_params, ok := params.(Brightness_Tr_Params); ok && tr.GetName() == "brightness" {
+		provenance.Brightness += uint64(max(br_params.Delta, -br_params.Delta))
+	}
+	return provenance
+}
+
+// Check that the output provenance is the input provenance incremented by this proof, and that it is within bounds.
+// The input provenance is signed by the editor of the last edit, see Check_Transformation(), so it cannot be reset.
+func Check_Provenance(api frontend.API, circuit Permissible_Transformations) {
+	in, out := circuit.Input.Provenance, circuit.Output.Provenance
+
+	// An original image has no edits.
+	api.AssertIsEqual(api.Mul(circuit.Case_1, out.Edits), 0)
+	api.AssertIsEqual(api.Mul(circuit.Case_1, out.Brightness), 0)
+	for i := range out.Uses {
+		api.AssertIsEqual(api.Mul(circuit.Case_1, out.Uses[i]), 0)
+	}
+
+	// Every proof of case 2 counts as an edit, and counts the transformation whose flag is on.
+	api.AssertIsEqual(out.Edits, api.Add(in.Edits, api.Sub(1, circuit.Case_1)))
+	api.AssertIsEqual(out.Uses[Transformation_Index("identity")], api.Add(in.Uses[Transformation_Index("identity")], circuit.Identity.Flag))
+	api.AssertIsEqual(out.Uses[Transformation_Index("brightness")], api.Add(in.Uses[Transformation_Index("brightness")], circuit.Brightness.Flag))
+	api.AssertIsEqual(out.Brightness, api.Add(in.Brightness, api.Mul(circuit.Brightness.Flag, circuit.Brightness.Shift(api))))
+
+	// The bounded comparator is only sound on small values, so range check the output, and the input so that
+	// the increments above cannot wrap around the field.
+	comparator := cmp.NewBoundedComparator(api, new(big.Int).Lsh(big.NewInt(1), Predicate_Bits), false)
+	for _, v := range append([]frontend.Variable{in.Edits, in.Brightness, out.Edits, out.Brightness}, append(in.Uses[:], out.Uses[:]...)...) {
+		api.ToBinary(v, Predicate_Bits)
+	}
+
+	bounds := circuit.Bounds
+	if bounds.Max_Edits != 0 {
+		comparator.AssertIsLessEq(out.Edits, bounds.Max_Edits)
+	}
+	for i, max_uses := range bounds.Max_Uses {
+		if max_uses != 0 {
+			comparator.AssertIsLessEq(out.Uses[i], max_uses)
+		}
+	}
+	if bounds.Max_Brightness != 0 {
+		comparator.AssertIsLessEq(out.Brightness, bounds.Max_Brightness)
+	}
+}
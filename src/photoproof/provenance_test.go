@@ -0,0 +1,49 @@
+package photoproof_test
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/image"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+// A third edit is bounded by the provenance of its input, which is signed by the editor of the second edit,
+// so a prover cannot reset it to pass the bounds.
+func TestProvenanceBounds(t *testing.T) {
+	originals, _ := signed_photos(t)
+	editor := must_user()
+	first := signed_edit(t, editor, originals[0])
+	second := signed_edit(t, editor, first)
+
+	reset := second
+	reset.Z.Provenance = image.Provenance{}
+	reset_as_original := reset
+	reset_as_original.Z.Editor, reset_as_original.Z.Editors = nil, nil
+
+	for _, tc := range []struct {
+		name      string
+		max_edits uint64
+		input     camera.Photograph
+		expect_ok bool
+	}{
+		{"second edit within bounds", 2, first, true},
+		{"third edit within bounds", 3, second, true},
+		{"third edit over bounds", 2, second, false},
+		{"reset provenance", 2, reset, false},
+		{"reset provenance and editors", 2, reset_as_original, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			bounds := photoproof.ProvenanceBounds{Max_Edits: tc.max_edits}
+			assignment := tamper_assignment(signed_edit(t, editor, tc.input), tc.input, false)
+			assignment.Bounds = bounds
+
+			err := test.IsSolved(&photoproof.Permissible_Transformations{Bounds: bounds}, &assignment, ecc.BN254.ScalarField())
+			if (err == nil) != tc.expect_ok {
+				t.Errorf("solved=%t, expected %t (err: %v)", err == nil, tc.expect_ok, err)
+			}
+		})
+	}
+}
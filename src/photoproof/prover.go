@@ -1,10 +1,15 @@
 package photoproof
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/signature"
 	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/r1cs"
 	"github.com/consensys/gnark/std/signature/eddsa"
@@ -13,11 +18,87 @@ import (
 
 // Proof that is used outside the circuit
 type Proof struct {
-	PCD_Proof groth16.Proof
-	Signature []byte
+	PCD_Proof   groth16.Proof
+	Signature   []byte
+	Certificate *Certificate // Of the camera key, if any. Passed through every edit of the main circuit
 }
 
 func (user User) Prove(prover ProverKeys, z_in image.Z, tr Transformation, params Transformation_Parameters, proof_in Proof) (image.Z, Proof, error) {
+	z_out, proof_out, _, err := user.Prove_Context(context.Background(), prover, z_in, tr, params, proof_in)
+	return z_out, proof_out, err
+}
+
+// Like Prove(), but stops between the assign, witness, compile and prove phases once ctx is done,
+// and returns the time spent in each phase that ran. A phase that already started runs to its end.
+func (user User) Prove_Context(ctx context.Context, prover ProverKeys, z_in image.Z, tr Transformation, params Transformation_Parameters, proof_in Proof) (image.Z, Proof, Timings, error) {
+	var timings Timings
+
+	var circuit Permissible_Transformations
+	var z_out image.Z
+	var signature_out []byte
+	err := run_phase(ctx, &timings, "assign", func() (err error) {
+		circuit, z_out, signature_out, err = user.assign(prover, z_in, tr, params, proof_in)
+		return err
+	})
+	if err != nil {
+		return image.Z{}, Proof{}, timings, err
+	}
+
+	// Create pcd_proof_out that the secret witness adheres to the compliance predicate, using the given proving key
+	pcd_proof_out, err := prove_phases(ctx, &timings, &circuit, prover.ProvingKey, prover.Compiled)
+	if err != nil {
+		return image.Z{}, Proof{}, timings, err
+	}
+
+	return z_out, Proof{
+		PCD_Proof:   pcd_proof_out,
+		Signature:   signature_out, // The public signature of the circuit
+		Certificate: proof_in.Certificate,
+	}, timings, nil
+}
+
+// Like Prove(), but the proof also claims that predicate holds for the hidden capture location of z_out.
+// opening is the metadata opening of the camera that took the photo, see camera.Photograph.Metadata.
+// The capture time of z_out is public, so a predicate with a time bound is refused, see ErrPublicTime.
+func (user User) Prove_Predicate(prover ProverKeys, z_in image.Z, opening image.Metadata_Opening, predicate Predicate, tr Transformation, params Transformation_Parameters, proof_in Proof) (image.Z, Proof, error) {
+	if err := predicate.location_only("Prove_Predicate"); err != nil {
+		return image.Z{}, Proof{}, err
+	}
+	if err := predicate.Check(opening.Metadata, time.Unix(int64(z_in.Timestamp), 0)); err != nil {
+		return image.Z{}, Proof{}, fmt.Errorf("[Prove_Predicate()] %w", err)
+	}
+
+	circuit, z_out, signature_out, err := user.assign(prover, z_in, tr, params, proof_in)
+	if err != nil {
+		return image.Z{}, Proof{}, err
+	}
+
+	circuit.Predicate = predicate.ToFr()
+	circuit.Location, err = Location_Opening(opening)
+	if err != nil {
+		return image.Z{}, Proof{}, fmt.Errorf("[Prove_Predicate()] %w", err)
+	}
+
+	pcd_proof_out, err := prove_circuit(&circuit, prover.ProvingKey, prover.Compiled)
+	if err != nil {
+		return image.Z{}, Proof{}, err
+	}
+
+	return z_out, Proof{
+		PCD_Proof:   pcd_proof_out,
+		Signature:   signature_out,
+		Certificate: proof_in.Certificate,
+	}, nil
+}
+
+// Assign the main circuit for proving z_out, and return z_out with the signature of the circuit.
+// The constants of the circuit come from prover, see Policy.
+func (user User) assign(prover ProverKeys, z_in image.Z, tr Transformation, params Transformation_Parameters, proof_in Proof) (Permissible_Transformations, image.Z, []byte, error) {
+	// The photo must be hashed and signed like the circuit of the keys
+	if z_in.Img.Hash_Function != prover.Hash_Function {
+		return Permissible_Transformations{}, image.Z{}, nil, fmt.Errorf("[Prove()] %w: the image is hashed with %s, the keys with %s", ErrMalformed, z_in.Img.Hash_Function, prover.Hash_Function)
+	}
+
 	// Case 1: Only a signature, no PCD_Proof
 	if proof_in.PCD_Proof == nil {
 
@@ -28,102 +109,168 @@ func (user User) Prove(prover ProverKeys, z_in image.Z, tr Transformation, param
 		var eddsa_digSig eddsa.Signature
 		eddsa_digSig.Assign(1, proof_in.Signature)
 
+		// Both branches of Define() are asserted, so the Input mirrors the Output and the identity flag is off.
 		circuit := Permissible_Transformations{
-			Output:    fr_z_in,
-			Signature: eddsa_digSig,
-			Case_1:    frontend.Variable(1),
-		}
+			Input:           fr_z_in,
+			Output:          fr_z_in,
+			Signature:       eddsa_digSig,
+			Parameters:      Fr_Identity_Tr_Params{},
+			Input_Signature: eddsa_digSig, // The camera signed the image of the original
+			Identity: Fr_Identity_Transformation{
+				Flag: frontend.Variable(0),
+			},
+			Brightness: Fr_Brightness_Transformation{Flag: frontend.Variable(0), Delta: frontend.Variable(0)},
+			Case_1:     frontend.Variable(1),
 
-		// Create the secret witness from the circuit (runs Define())
-		secret_witness_out, err := frontend.NewWitness(circuit, ecc.BN254.ScalarField())
-		if err != nil {
-			return image.Z{}, Proof{}, err
-		}
+			Bounds:      prover.Bounds,
+			Params:      prover.Params,
+			Policy_ID:   prover.Policy_ID,
+			Policy_Hash: Policy_Hash(prover.Policy_ID),
 
-		// Set the security parameter and compile a constraint system (aka compliance_predicate) (runs Define())
-		compliance_predicate, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
-		if err != nil {
-			return image.Z{}, Proof{}, err
+			Hash_Function: prover.Hash_Function,
 		}
+		circuit.Predicate, circuit.Location = No_Predicate()
 
-		// Create pcd_proof_out that the secret witness adheres to the compliance predicate, using the given proving key (runs Define())
-		pcd_proof_out, err := groth16.Prove(compliance_predicate, prover.ProvingKey, secret_witness_out)
-		if err != nil {
-			return image.Z{}, Proof{}, err
-		}
+		return circuit, z_in, proof_in.Signature, nil
+	}
 
-		return z_in, Proof{
-			PCD_Proof: pcd_proof_out,
-			Signature: proof_in.Signature,
-		}, err
-	} else {
-		/* From paper: Algorithm 3, 5-9: "π'in ← πin" */
-		img_out := tr.Apply(z_in.Img, &params) // Algorithm 3, 6: "Iout ← t (Iin, γ)"
+	/* From paper: Algorithm 3, 5-9: "π'in ← πin" */
+	// Algorithm 3, 6: "Iout ← t (Iin, γ)", with the provenance and editors of the output
+	z_out, err := Next_Z(z_in, tr, params, user.PublicKey)
+	if err != nil {
+		return Permissible_Transformations{}, image.Z{}, nil, fmt.Errorf("[Prove()] %w", err)
+	}
 
-		// Sign output image
-		signature_out, err := user.Sign(img_out)
-		if err != nil {
-			fmt.Println("[Prove()] Error while Signing the output image.")
-			return image.Z{}, Proof{}, err
-		}
+	// Sign the output image, with its provenance and editors
+	signature_out, err := user.Sign_Edit(z_out)
+	if err != nil {
+		return Permissible_Transformations{}, image.Z{}, nil, fmt.Errorf("[Prove()] signing the output image: %w", err)
+	}
 
-		// Assign signature to its EdDSA equivalent.
-		var eddsa_digSig eddsa.Signature
-		eddsa_digSig.Assign(1, signature_out)
+	// Assign the signatures to their EdDSA equivalent: the input was signed by the camera or by its last editor.
+	var eddsa_digSig, eddsa_sig_in eddsa.Signature
+	eddsa_digSig.Assign(1, signature_out)
+	eddsa_sig_in.Assign(1, proof_in.Signature)
 
-		// Assign public key to its EdDSA equivalent.
-		var eddsa_PK eddsa.PublicKey
-		eddsa_PK.Assign(1, user.PublicKey.Bytes())
+	fr_z_in := z_in.ToFr()
+	fr_z_out := z_out.ToFr()
 
-		// Set Public Key of fr_z_in
-		fr_z_in := z_in.ToFr()
-		fr_z_in.PublicKey = eddsa_PK
-
-		circuit := Permissible_Transformations{} // Instantiate new circuit
-
-		// Depending on the tr.GetName(), Set the appropriate flag in the circuit's list of fr_transformations.
-		switch tr.GetName() {
-		case "Identity":
-			circuit = Permissible_Transformations{
-				Input: fr_z_in,
-				Output: image.Fr_Z{
-					Img:       img_out.ToFr(),
-					PublicKey: eddsa_PK,
-				},
-				Signature:  eddsa_digSig,
-				Parameters: params.ToFr(),
-				Identity: Fr_Identity_Transformation{
-					Flag: frontend.Variable(1),
-				},
-				Case_1: frontend.Variable(0), // Not case 1; Not original image
-			}
-		}
+	circuit := Permissible_Transformations{
+		Input:           fr_z_in,
+		Output:          fr_z_out, // The camera key, original signature, hash, timestamp, counter and metadata are passed from input to output
+		Signature:       eddsa_digSig,
+		Parameters:      params.ToFr(),
+		Input_Signature: eddsa_sig_in,
+		Identity:        Fr_Identity_Transformation{Flag: frontend.Variable(0)},
+		Brightness:      Fr_Brightness_Transformation{Flag: frontend.Variable(0), Delta: frontend.Variable(0)},
+		Case_1:          frontend.Variable(0), // Not case 1; Not original image
 
-		// Create the secret witness from the circuit
-		secret_witness_out, err := frontend.NewWitness(circuit, ecc.BN254.ScalarField())
-		if err != nil {
-			return image.Z{}, Proof{}, err
+		Bounds:      prover.Bounds,
+		Params:      prover.Params,
+		Policy_ID:   prover.Policy_ID,
+		Policy_Hash: Policy_Hash(prover.Policy_ID),
+
+		Hash_Function: prover.Hash_Function,
+	}
+
+	// Depending on the tr.GetName(), Set the appropriate flag in the circuit's list of fr_transformations.
+	switch tr.GetName() {
+	case "identity":
+		circuit.Identity.Flag = frontend.Variable(1)
+	case "brightness":
+		br_params, ok := params.(Brightness_Tr_Params)
+		if !ok || br_params.Delta < -255 || br_params.Delta > 255 {
+			return Permissible_Transformations{}, image.Z{}, nil, fmt.Errorf("[Prove()] %w: brightness needs a delta in [-255, 255]", ErrNotPermissible)
+		}
+		circuit.Brightness = Fr_Brightness_Transformation{
+			Flag:  frontend.Variable(1),
+			Delta: frontend.Variable(br_params.Delta + 255),
 		}
+	default:
+		return Permissible_Transformations{}, image.Z{}, nil, fmt.Errorf("[Prove()] %w: %q", ErrNotPermissible, tr.GetName())
+	}
+	circuit.Predicate, circuit.Location = No_Predicate()
 
-		// Set the security parameter and compile a constraint system (aka compliance_predicate)
-		compliance_predicate, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
-		if err != nil {
-			return image.Z{}, Proof{}, err
+	return circuit, z_out, signature_out, nil
+}
+
+// The output of applying tr with params to z_in, edited by editor: its image, provenance and editors are replaced.
+// The editor signs it with User.Sign_Edit().
+func Next_Z(z_in image.Z, tr Transformation, params Transformation_Parameters, editor signature.PublicKey) (image.Z, error) {
+	editors_out, err := Next_Editors(z_in.Editors, editor)
+	if err != nil {
+		return image.Z{}, err
+	}
+
+	z_out := z_in
+	z_out.Img = tr.Apply(z_in.Img, &params)
+	z_out.Provenance = Next_Provenance(z_in.Provenance, tr, params)
+	z_out.Editor = editor
+	z_out.Editors = editors_out
+	return z_out, nil
+}
+
+// Compile the main circuit with the constants of prover once, so that Prove() and Prove_Predicate() skip compiling it.
+// The compiled circuit is only read by the prover, so copies of prover can prove concurrently, see editor.Pool.
+func (prover *ProverKeys) Compile() error {
+	circuit := Permissible_Transformations{
+		Bounds:        prover.Bounds,
+		Params:        prover.Params,
+		Policy_ID:     prover.Policy_ID,
+		Hash_Function: prover.Hash_Function,
+	}
+
+	compliance_predicate, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	if err != nil {
+		return fmt.Errorf("[Compile()] %w: %w", ErrCompile, err)
+	}
+
+	prover.Compiled = compliance_predicate
+	return nil
+}
+
+// Prove the assignment of circuit with the proving key. The circuit is compiled first unless compiled is set.
+func prove_circuit(circuit frontend.Circuit, proving_key groth16.ProvingKey, compiled constraint.ConstraintSystem) (groth16.Proof, error) {
+	return prove_phases(context.Background(), &Timings{}, circuit, proving_key, compiled)
+}
+
+// Like prove_circuit(), in phases recorded in timings, see run_phase().
+func prove_phases(ctx context.Context, timings *Timings, circuit frontend.Circuit, proving_key groth16.ProvingKey, compiled constraint.ConstraintSystem) (groth16.Proof, error) {
+	// Create the secret witness from the circuit (runs Define())
+	var secret_witness_out witness.Witness
+	err := run_phase(ctx, timings, "witness", func() (err error) {
+		if secret_witness_out, err = frontend.NewWitness(circuit, ecc.BN254.ScalarField()); err != nil {
+			return fmt.Errorf("[Prove()] %w: %w", ErrWitness, err)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// Create proof_out that the secret witness adheres to the compliance predicate, using the given proving key
-		proof_out, err := groth16.Prove(compliance_predicate, prover.ProvingKey, secret_witness_out)
+	// Set the security parameter and compile a constraint system (aka compliance_predicate) (runs Define())
+	compliance_predicate := compiled
+	if compliance_predicate == nil {
+		err := run_phase(ctx, timings, "compile", func() (err error) {
+			if compliance_predicate, err = frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit); err != nil {
+				return fmt.Errorf("[Prove()] %w: %w", ErrCompile, err)
+			}
+			return nil
+		})
 		if err != nil {
-			return image.Z{}, Proof{}, err
+			return nil, err
 		}
-
-		// Replace image in z_in to create z_out
-		z_in.Img = img_out
-		z_out := z_in
-
-		return z_out, Proof{
-			PCD_Proof: proof_out,
-			Signature: proof_in.Signature,
-		}, err
 	}
+
+	// Create a proof that the secret witness adheres to the compliance predicate, using the given proving key (runs Define())
+	var pcd_proof_out groth16.Proof
+	err = run_phase(ctx, timings, "prove", func() (err error) {
+		// An unsatisfied circuit is a rejected edit, e.g. past the ProvenanceBounds, not a failure of the prover.
+		if pcd_proof_out, err = groth16.Prove(compliance_predicate, proving_key, secret_witness_out); err != nil {
+			Logger().Debug("proving failed", "err", err)
+			return fmt.Errorf("[Prove()] %w: %w", ErrProve, err)
+		}
+		return nil
+	})
+	return pcd_proof_out, err
 }
@@ -0,0 +1,60 @@
+//go:build seeded_setup
+
+package photoproof
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	mrand "math/rand/v2"
+	"sync"
+
+	ceddsa "github.com/consensys/gnark-crypto/signature/eddsa"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
+)
+
+/*
+Reproducible keys, for tests only: this file is only built with `go build -tags seeded_setup`.
+
+groth16.Setup() samples its toxic waste from crypto/rand.Reader and takes no other source, so a seeded
+setup swaps the process-wide reader for the length of the setup. Anything else reading crypto/rand.Reader
+at that time (NewUser(), metadata salts, keystore nonces, job IDs...) reads the predictable stream too,
+which is why a release binary must not contain it.
+*/
+
+// Derive the toxic waste of the setup, and the admin key unless With_Admin() is given, from seed,
+// so that the same circuit and seed always give the same keys. Anyone who knows the seed can forge proofs.
+func With_Seed(seed []byte) Generator_Option {
+	return func(options *generator_options) {
+		options.seed = append([]byte{}, seed...)
+	}
+}
+
+// A deterministic stream of bytes for one use (label) of a seed.
+func seed_stream(label string, seed []byte) *mrand.ChaCha8 {
+	return mrand.NewChaCha8(sha256.Sum256(append([]byte("photognark "+label+" "), seed...)))
+}
+
+func seeded_admin(caller string, seed []byte) (User, error) {
+	secret_key, err := ceddsa.New(1, seed_stream("admin", seed))
+	if err != nil {
+		return User{}, fmt.Errorf("[%s()] %w: generating the admin key from the seed: %w", caller, ErrSign, err)
+	}
+
+	return User{SecretKey: secret_key, PublicKey: secret_key.Public()}, nil
+}
+
+// Seeded setups swap crypto/rand.Reader, one at a time.
+var seeded_setup_lock sync.Mutex
+
+func seeded_setup(compliance_predicate_id constraint.ConstraintSystem, seed []byte) (groth16.ProvingKey, groth16.VerifyingKey, error) {
+	seeded_setup_lock.Lock()
+	defer seeded_setup_lock.Unlock()
+
+	reader := rand.Reader
+	rand.Reader = seed_stream("setup", seed)
+	defer func() { rand.Reader = reader }()
+
+	return groth16.Setup(compliance_predicate_id)
+}
@@ -0,0 +1,24 @@
+//go:build !seeded_setup
+
+package photoproof
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
+)
+
+// Without the seeded_setup build tag there is no With_Seed(), so the seed of a generator is always nil
+// and crypto/rand.Reader is never replaced. These only keep the generators building.
+
+var errNoSeededSetup = errors.New("built without the seeded_setup tag")
+
+func seeded_admin(caller string, seed []byte) (User, error) {
+	return User{}, fmt.Errorf("[%s()] %w: %w", caller, ErrSign, errNoSeededSetup)
+}
+
+func seeded_setup(compliance_predicate_id constraint.ConstraintSystem, seed []byte) (groth16.ProvingKey, groth16.VerifyingKey, error) {
+	return nil, nil, errNoSeededSetup
+}
@@ -0,0 +1,204 @@
+package photoproof
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/drakstik/Photognark_V3/src/image"
+)
+
+// A Signer signs digests on behalf of a User, so that the secret key can live outside of
+// this process: in a secure element, a PKCS#11-style module or a signing daemon.
+type Signer interface {
+	Public() signature.PublicKey
+	Sign_Digest(digest []byte, hash_function image.Hash_Function) ([]byte, error) // hash_function is H of H(R, A, M)
+}
+
+// A User whose secret key lives behind signer.
+func NewUser_From_Signer(signer Signer) User {
+	return User{
+		PublicKey: signer.Public(),
+		Signer:    signer,
+	}
+}
+
+// The Signer of the user: its external Signer if any, else its local SecretKey.
+func (user User) signer() Signer {
+	if user.Signer != nil {
+		return user.Signer
+	}
+	return LocalSigner{SecretKey: user.SecretKey}
+}
+
+/*------------------------------------------ Local software key --------------------------------------*/
+
+type LocalSigner struct {
+	SecretKey signature.Signer
+}
+
+func (local LocalSigner) Public() signature.PublicKey {
+	return local.SecretKey.Public()
+}
+
+func (local LocalSigner) Sign_Digest(digest []byte, hash_function image.Hash_Function) ([]byte, error) {
+	if local.SecretKey == nil {
+		return nil, errors.New("no secret key")
+	}
+
+	// Instantiate the hash function, to be used in signing the digest
+	hFunc := hash_function.New()
+
+	return local.SecretKey.Sign(digest, hFunc)
+}
+
+/*------------------------------------------ Signing daemon over a Unix socket --------------------------------------*/
+
+// One request per connection, answered by one response; both are JSON objects.
+type signer_request struct {
+	Op     string              `json:"op"` // "public" or "sign"
+	Digest string              `json:"digest,omitempty"`
+	Hash   image.Hash_Function `json:"hash,omitempty"` // Omitted for MiMC, which older daemons assume
+}
+
+type signer_response struct {
+	PublicKey string `json:"public_key,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// A Signer backed by a signing daemon listening on a Unix socket, see Serve_Signer().
+type SocketSigner struct {
+	Path       string
+	public_key signature.PublicKey
+}
+
+// Connect to the signing daemon at path and fetch its public key.
+func NewSocketSigner(path string) (*SocketSigner, error) {
+	socket := &SocketSigner{Path: path}
+
+	response, err := socket.call(signer_request{Op: "public"})
+	if err != nil {
+		return nil, err
+	}
+
+	public_key_bytes, err := hex.DecodeString(response.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if socket.public_key, err = PublicKey_From_Bytes(public_key_bytes); err != nil {
+		return nil, err
+	}
+
+	return socket, nil
+}
+
+func (socket *SocketSigner) call(request signer_request) (signer_response, error) {
+	conn, err := net.Dial("unix", socket.Path)
+	if err != nil {
+		return signer_response{}, err
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return signer_response{}, err
+	}
+
+	var response signer_response
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return signer_response{}, err
+	}
+	if response.Error != "" {
+		return signer_response{}, fmt.Errorf("signing daemon: %s", response.Error)
+	}
+
+	return response, nil
+}
+
+func (socket *SocketSigner) Public() signature.PublicKey {
+	return socket.public_key
+}
+
+func (socket *SocketSigner) Sign_Digest(digest []byte, hash_function image.Hash_Function) ([]byte, error) {
+	response, err := socket.call(signer_request{Op: "sign", Digest: hex.EncodeToString(digest), Hash: hash_function})
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(response.Signature)
+}
+
+// Serve signing requests on listener with signer, until the listener is closed.
+// This is the signing daemon; the secret key never leaves its process.
+func Serve_Signer(listener net.Listener, signer Signer) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		go serve_signer_conn(conn, signer)
+	}
+}
+
+func serve_signer_conn(conn net.Conn, signer Signer) {
+	defer conn.Close()
+
+	var request signer_request
+	var response signer_response
+
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		response.Error = "malformed request"
+	} else {
+		switch request.Op {
+		case "public":
+			response.PublicKey = hex.EncodeToString(signer.Public().Bytes())
+		case "sign":
+			digest, err := hex.DecodeString(request.Digest)
+			if err != nil {
+				response.Error = "malformed digest"
+				break
+			}
+
+			signature, err := signer.Sign_Digest(digest, request.Hash)
+			if err != nil {
+				response.Error = err.Error()
+				break
+			}
+			response.Signature = hex.EncodeToString(signature)
+		default:
+			response.Error = fmt.Sprintf("unknown op %q", request.Op)
+		}
+	}
+
+	json.NewEncoder(conn).Encode(response)
+}
+
+/*------------------------------------------ Signature made by a remote editor --------------------------------------*/
+
+// A Signer holding one signature, made by an editor who sends its edit to a proving service, so that the
+// service proves the edit without the editor's secret key. It only signs the digest the signature is valid for.
+type PresignedSigner struct {
+	PublicKey signature.PublicKey
+	Signature []byte
+}
+
+func (presigned PresignedSigner) Public() signature.PublicKey {
+	return presigned.PublicKey
+}
+
+func (presigned PresignedSigner) Sign_Digest(digest []byte, hash_function image.Hash_Function) ([]byte, error) {
+	ok, err := presigned.PublicKey.Verify(presigned.Signature, digest, hash_function.New())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: presigned signature is not for the digest", ErrBadSignature)
+	}
+	return presigned.Signature, nil
+}
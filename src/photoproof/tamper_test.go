@@ -0,0 +1,295 @@
+package photoproof_test
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/signature/eddsa"
+	"github.com/consensys/gnark/test"
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+The negative test suite of the whole pipeline. Each tamper case modifies one part of an honest
+Photograph, and both the circuit and User.Verify must reject the tampered photograph.
+*/
+
+type tamper_case struct {
+	name string
+	// Tamper with photo, using other (an honest photograph of the same camera) as a source of valid-looking values.
+	tamper func(photo camera.Photograph, other camera.Photograph) camera.Photograph
+}
+
+var tamper_cases = []tamper_case{
+	{
+		name: "flip a pixel",
+		tamper: func(photo camera.Photograph, other camera.Photograph) camera.Photograph {
+			photo.Z.Img.Pxls[0].RGB[0] ^= 1
+			return photo
+		},
+	},
+	{
+		name: "swap public key",
+		tamper: func(photo camera.Photograph, other camera.Photograph) camera.Photograph {
+			photo.Z.PublicKey = must_user().PublicKey
+			return photo
+		},
+	},
+	{
+		name: "replace original signature",
+		tamper: func(photo camera.Photograph, other camera.Photograph) camera.Photograph {
+			photo.Z.OriginalSignature = other.Z.OriginalSignature
+			return photo
+		},
+	},
+	{
+		name: "reuse proof with another image",
+		tamper: func(photo camera.Photograph, other camera.Photograph) camera.Photograph {
+			other.Proof = photo.Proof
+			return other
+		},
+	},
+	{
+		name: "replay an edit signature",
+		tamper: func(photo camera.Photograph, other camera.Photograph) camera.Photograph {
+			photo.Proof.Signature = other.Proof.Signature
+			return photo
+		},
+	},
+	{
+		name: "change capture time",
+		tamper: func(photo camera.Photograph, other camera.Photograph) camera.Photograph {
+			photo.Z.Timestamp -= 3600
+			return photo
+		},
+	},
+	{
+		name: "change counter",
+		tamper: func(photo camera.Photograph, other camera.Photograph) camera.Photograph {
+			photo.Z.Counter++
+			return photo
+		},
+	},
+	{
+		name: "swap metadata commitment",
+		tamper: func(photo camera.Photograph, other camera.Photograph) camera.Photograph {
+			photo.Z.MetadataCommitment = other.Z.MetadataCommitment
+			return photo
+		},
+	},
+	{
+		name: "mismatch original hash",
+		tamper: func(photo camera.Photograph, other camera.Photograph) camera.Photograph {
+			photo.Z.OriginalHash = other.Z.OriginalHash
+			return photo
+		},
+	},
+	{
+		name: "change edit count",
+		tamper: func(photo camera.Photograph, other camera.Photograph) camera.Photograph {
+			photo.Z.Provenance.Edits++
+			return photo
+		},
+	},
+	{
+		name: "swap editor",
+		tamper: func(photo camera.Photograph, other camera.Photograph) camera.Photograph {
+			photo.Z.Editor = must_user().PublicKey
+			return photo
+		},
+	},
+}
+
+// Recreate the circuit assignment a prover would need in order to prove photo.
+// The secret input is always the honest input, the public values come from photo.
+func tamper_assignment(photo camera.Photograph, input camera.Photograph, original bool) photoproof.Permissible_Transformations {
+	var eddsa_digSig, eddsa_sig_in eddsa.Signature
+	eddsa_digSig.Assign(1, photo.Proof.Signature)
+	eddsa_sig_in.Assign(1, input.Proof.Signature)
+
+	fr_z_out := photo.Z.ToFr()
+	predicate, location := photoproof.No_Predicate()
+
+	if original {
+		return photoproof.Permissible_Transformations{
+			Input:           fr_z_out,
+			Output:          fr_z_out,
+			Signature:       eddsa_digSig,
+			Parameters:      photoproof.Fr_Identity_Tr_Params{},
+			Input_Signature: eddsa_digSig,
+			Identity:        photoproof.Fr_Identity_Transformation{Flag: frontend.Variable(0)},
+			Brightness:      photoproof.Fr_Brightness_Transformation{Flag: frontend.Variable(0), Delta: frontend.Variable(0)},
+			Case_1:          frontend.Variable(1),
+			Predicate:       predicate,
+			Location:        location,
+
+			Policy_Hash: photoproof.Policy_Hash(nil),
+		}
+	}
+
+	return photoproof.Permissible_Transformations{
+		Input:           input.Z.ToFr(),
+		Output:          fr_z_out,
+		Signature:       eddsa_digSig,
+		Parameters:      photoproof.Fr_Identity_Tr_Params{},
+		Input_Signature: eddsa_sig_in,
+		Identity:        photoproof.Fr_Identity_Transformation{Flag: frontend.Variable(1)},
+		Brightness:      photoproof.Fr_Brightness_Transformation{Flag: frontend.Variable(0), Delta: frontend.Variable(0)},
+		Case_1:          frontend.Variable(0),
+		Predicate:       predicate,
+		Location:        location,
+
+		Policy_Hash: photoproof.Policy_Hash(nil),
+	}
+}
+
+// An identity edit of photo signed by editor, without its PCD proof: the circuit only needs the signatures.
+func signed_edit(t *testing.T, editor photoproof.User, photo camera.Photograph) camera.Photograph {
+	t.Helper()
+
+	z_out, err := photoproof.Next_Z(photo.Z, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, editor.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := editor.Sign_Edit(z_out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	photo.Z = z_out
+	photo.Proof = photoproof.Proof{Signature: signature}
+	return photo
+}
+
+// Two original photographs of a camera without keys, and an edit of each signed by the camera.
+func signed_photos(t *testing.T) (originals [2]camera.Photograph, edits [2]camera.Photograph) {
+	t.Helper()
+
+	cam := camera.Camera{Admin: must_user()}
+	for i := range originals {
+		photo, err := cam.TakePhotograph("random")
+		if err != nil {
+			t.Fatal(err)
+		}
+		originals[i] = photo
+		edits[i] = signed_edit(t, cam.Admin, photo) // The input of an edit of a converted photo is its original
+	}
+	return originals, edits
+}
+
+func TestTamperingCircuit(t *testing.T) {
+	originals, edits := signed_photos(t)
+
+	type assignment struct {
+		name       string
+		assignment photoproof.Permissible_Transformations
+		expect_ok  bool
+	}
+	assignments := []assignment{
+		{"honest original", tamper_assignment(originals[0], originals[0], true), true},
+		{"honest edit", tamper_assignment(edits[0], originals[0], false), true},
+	}
+	// An edit signed by its editor, whose input was not signed by the camera.
+	forged := originals[0]
+	forged.Z.Img = originals[1].Z.Img
+	assignments = append(assignments, assignment{"edit of an unsigned input", tamper_assignment(signed_edit(t, must_user(), forged), forged, false), false})
+
+	// An edit of the camera-signed image of photo 1, with the signed capture time, counter and metadata of photo 0.
+	transplant := originals[1]
+	transplant.Z.OriginalHash, transplant.Z.OriginalSignature = originals[0].Z.OriginalHash, originals[0].Z.OriginalSignature
+	transplant.Z.Timestamp, transplant.Z.Counter = originals[0].Z.Timestamp, originals[0].Z.Counter
+	transplant.Z.MetadataCommitment = originals[0].Z.MetadataCommitment
+	assignments = append(assignments, assignment{"transplant original metadata", tamper_assignment(signed_edit(t, must_user(), transplant), transplant, false), false})
+
+	for _, tc := range tamper_cases {
+		assignments = append(assignments,
+			assignment{tc.name + " (original)", tamper_assignment(tc.tamper(originals[0], originals[1]), originals[0], true), false},
+			assignment{tc.name + " (edit)", tamper_assignment(tc.tamper(edits[0], edits[1]), originals[0], false), false},
+		)
+	}
+
+	// Solve each assignment with the test engine, which names the failing case.
+	options := []test.TestingOption{test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16), test.NoFuzzing(), test.NoSerializationChecks(), test.NoTestEngine()}
+	for _, a := range assignments {
+		t.Run(a.name, func(t *testing.T) {
+			err := test.IsSolved(&photoproof.Permissible_Transformations{}, &a.assignment, ecc.BN254.ScalarField())
+			if (err == nil) != a.expect_ok {
+				t.Errorf("solved=%t, expected %t (err: %v)", err == nil, a.expect_ok, err)
+			}
+		})
+
+		if a.expect_ok {
+			options = append(options, test.WithValidAssignment(&a.assignment))
+		} else {
+			options = append(options, test.WithInvalidAssignment(&a.assignment))
+		}
+	}
+
+	// Then solve them all with the compiled constraint system, which is what a prover runs.
+	test.NewAssert(t).CheckCircuit(&photoproof.Permissible_Transformations{}, options...)
+}
+
+func TestTamperingVerify(t *testing.T) {
+	if testing.Short() {
+		t.Skip("generates the keys and proves four photographs")
+	}
+
+	circuit := photoproof.Permissible_Transformations{}
+	cam, err := camera.NewCamera(&circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep the trusted verifier keys, a tampered photograph may carry any keys it likes.
+	verifier_keys := cam.Verifier
+	viewer := must_user()
+
+	// Two original photographs, and both of them edited once, after converting their signature to a PCD proof.
+	var originals, edits [2]camera.Photograph
+	for i := range originals {
+		photo, err := cam.TakePhotograph("random")
+		if err != nil {
+			t.Fatal(err)
+		}
+		z, proof, err := cam.Admin.Prove(cam.Prover, photo.Z, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, photo.Proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		z, proof, err = cam.Admin.Prove(cam.Prover, z, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		originals[i] = photo
+		edits[i] = photo
+		edits[i].Z, edits[i].Proof = z, proof
+	}
+
+	check := func(t *testing.T, photo camera.Photograph, expect_ok bool) {
+		ok, err := viewer.Verify(verifier_keys, photo.Z, photo.Proof)
+		if (ok && err == nil) != expect_ok {
+			t.Errorf("accepted=%t, expected %t (err: %v)", ok && err == nil, expect_ok, err)
+		}
+	}
+
+	// The honest photographs must be accepted, otherwise the rejections below mean nothing.
+	t.Run("honest original", func(t *testing.T) { check(t, originals[0], true) })
+	t.Run("honest edit", func(t *testing.T) { check(t, edits[0], true) })
+
+	for _, tc := range tamper_cases {
+		t.Run(tc.name+" (original)", func(t *testing.T) { check(t, tc.tamper(originals[0], originals[1]), false) })
+		t.Run(tc.name+" (edit)", func(t *testing.T) { check(t, tc.tamper(edits[0], edits[1]), false) })
+	}
+}
+
+// A new User, for tests and their tamper cases.
+func must_user() photoproof.User {
+	user, err := photoproof.NewUser()
+	if err != nil {
+		panic(err)
+	}
+	return user
+}
@@ -0,0 +1,33 @@
+package photoproof
+
+import (
+	"fmt"
+	"time"
+)
+
+// A verifier's policy on the signed capture time of photos. Zero fields are not checked.
+type TimePolicy struct {
+	NotBefore time.Time     // Reject photos taken before NotBefore
+	NotAfter  time.Time     // Reject photos taken after NotAfter
+	MaxAge    time.Duration // Reject photos older than MaxAge at verification time
+	MaxSkew   time.Duration // Reject photos taken more than MaxSkew in the future, if MaxAge is set
+}
+
+// Check the capture time of a photo verified at time now.
+func (policy TimePolicy) Check(capture_time time.Time, now time.Time) error {
+	if !policy.NotBefore.IsZero() && capture_time.Before(policy.NotBefore) {
+		return fmt.Errorf("photo was taken at %s, before %s", capture_time, policy.NotBefore)
+	}
+	if !policy.NotAfter.IsZero() && capture_time.After(policy.NotAfter) {
+		return fmt.Errorf("photo was taken at %s, after %s", capture_time, policy.NotAfter)
+	}
+	if policy.MaxAge != 0 {
+		if now.Sub(capture_time) > policy.MaxAge {
+			return fmt.Errorf("photo was taken at %s, more than %s ago", capture_time, policy.MaxAge)
+		}
+		if capture_time.Sub(now) > policy.MaxSkew {
+			return fmt.Errorf("photo was taken at %s, in the future", capture_time)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package photoproof
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The time spent in one phase of a proof or a verification.
+type Phase_Timing struct {
+	Phase    string // "assign", "witness", "compile", "prove"; or "checks", "signature", "proof"
+	Duration time.Duration
+}
+
+// The phases of a proof or a verification that ran, in order, see Prove_Context() and Verify_Context().
+type Timings []Phase_Timing
+
+func (timings Timings) Total() time.Duration {
+	var total time.Duration
+	for _, timing := range timings {
+		total += timing.Duration
+	}
+	return total
+}
+
+func (timings Timings) String() string {
+	phases := make([]string, len(timings))
+	for i, timing := range timings {
+		phases[i] = fmt.Sprintf("%s=%s", timing.Phase, timing.Duration.Round(time.Millisecond))
+	}
+	return strings.Join(phases, " ")
+}
+
+// Run one phase, unless ctx is done, and record its duration in timings.
+// Phases cannot be interrupted, so cancellation takes effect before the next phase.
+func run_phase(ctx context.Context, timings *Timings, phase string, run func() error) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("stopped before %s: %w", phase, err)
+	}
+
+	start := time.Now()
+	err := run()
+	*timings = append(*timings, Phase_Timing{Phase: phase, Duration: time.Since(start)})
+	return err
+}
@@ -24,3 +24,71 @@ func (id_tr Identity_Transformation) GetName() string {
 func (id_tr Identity_Transformation) Apply(img image.Image, params *Transformation_Parameters) image.Image {
 	return img
 }
+
+type Identity_Tr_Params struct{}
+
+func (params Identity_Tr_Params) GetName() string {
+	return "identity"
+}
+
+func (params Identity_Tr_Params) ToFr() Fr_Transformation_Parameters {
+	return Fr_Identity_Tr_Params{}
+}
+
+/*--------------------------------------------Transformation 2------------------------------------------*/
+// Shift every channel of every pixel by Delta, clamped to [0, 255].
+type Brightness_Transformation struct{}
+
+func (br_tr Brightness_Transformation) GetName() string {
+	return "brightness"
+}
+
+func (br_tr Brightness_Transformation) Apply(img image.Image, params *Transformation_Parameters) image.Image {
+	delta := 0
+	if br_params, ok := (*params).(Brightness_Tr_Params); ok {
+		delta = br_params.Delta
+	}
+
+	for i := range img.Pxls {
+		for c := range img.Pxls[i].RGB {
+			img.Pxls[i].RGB[c] = uint8(min(max(int(img.Pxls[i].RGB[c])+delta, 0), 255))
+		}
+	}
+	return img
+}
+
+type Brightness_Tr_Params struct {
+	Delta int // In [-255, 255]
+}
+
+func (params Brightness_Tr_Params) GetName() string {
+	return "brightness"
+}
+
+func (params Brightness_Tr_Params) ToFr() Fr_Transformation_Parameters {
+	return Fr_Brightness_Tr_Params{}
+}
+
+// The transformation named name, or nil if there is none.
+func Transformation_Named(name string) Transformation {
+	switch name {
+	case "identity":
+		return Identity_Transformation{}
+	case "brightness":
+		return Brightness_Transformation{}
+	}
+	return nil
+}
+
+/*--------------------------------------------Provenance------------------------------------------*/
+
+// The index of a transformation in image.Provenance.Uses, or -1 if it is not counted.
+func Transformation_Index(name string) int {
+	switch name {
+	case "identity":
+		return 0
+	case "brightness":
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,233 @@
+package photoproof
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/consensys/gnark-crypto/signature"
+)
+
+// A secure camera public key trusted by the verifier.
+type CameraKey struct {
+	DeviceID  string
+	PublicKey signature.PublicKey
+	NotBefore time.Time // The key is valid from NotBefore
+	NotAfter  time.Time // until NotAfter. A zero time means no limit.
+	Revoked   bool
+}
+
+// Check that the key may be used at time t.
+func (key CameraKey) Valid(t time.Time) error {
+	if key.Revoked {
+		return fmt.Errorf("camera key of device %q is revoked", key.DeviceID)
+	}
+	if !key.NotBefore.IsZero() && t.Before(key.NotBefore) {
+		return fmt.Errorf("camera key of device %q is not valid before %s", key.DeviceID, key.NotBefore)
+	}
+	if !key.NotAfter.IsZero() && t.After(key.NotAfter) {
+		return fmt.Errorf("camera key of device %q expired on %s", key.DeviceID, key.NotAfter)
+	}
+	return nil
+}
+
+// The camera public keys of a fleet of secure cameras, indexed by the public key bytes.
+// It also revokes the certificates of cameras certified by a manufacturer, see Revoke_Certificate().
+type TrustStore struct {
+	Keys                 map[string]CameraKey
+	Revoked_Certificates map[uint64]bool // By serial
+}
+
+func NewTrustStore() *TrustStore {
+	return &TrustStore{Keys: map[string]CameraKey{}, Revoked_Certificates: map[uint64]bool{}}
+}
+
+func trust_store_index(public_key signature.PublicKey) string {
+	return hex.EncodeToString(public_key.Bytes())
+}
+
+// Add (or replace) a camera key.
+func (store *TrustStore) Add(key CameraKey) {
+	store.Keys[trust_store_index(key.PublicKey)] = key
+}
+
+// Revoke every key of the device. Photos taken by a revoked key no longer verify.
+func (store *TrustStore) Revoke(device_id string) error {
+	found := false
+	for index, key := range store.Keys {
+		if key.DeviceID == device_id {
+			key.Revoked = true
+			store.Keys[index] = key
+			found = true
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("no camera key for device %q", device_id)
+	}
+	return nil
+}
+
+// Revoke the certificate with serial, e.g. of a leaked key that was rotated away from.
+// Photos of a revoked certificate no longer verify, including those taken before the revocation:
+// the capture time is signed by the leaked key itself, so it cannot tell them apart.
+func (store *TrustStore) Revoke_Certificate(serial uint64) {
+	if store.Revoked_Certificates == nil {
+		store.Revoked_Certificates = map[uint64]bool{}
+	}
+	store.Revoked_Certificates[serial] = true
+}
+
+// Check that neither the certificate nor its key are revoked, else fail with ErrUntrustedKey.
+func (store *TrustStore) Check_Certificate(cert Certificate) error {
+	if store.Revoked_Certificates[cert.Serial] {
+		return fmt.Errorf("%w: certificate %d is revoked", ErrUntrustedKey, cert.Serial)
+	}
+	if cert.PublicKey != nil {
+		if key, ok := store.Keys[trust_store_index(cert.PublicKey)]; ok && key.Revoked {
+			return fmt.Errorf("%w: the key of certificate %d is revoked", ErrUntrustedKey, cert.Serial)
+		}
+	}
+	return nil
+}
+
+// Find the camera key of public_key, and check that it may be used at time t.
+func (store *TrustStore) Lookup(public_key signature.PublicKey, t time.Time) (CameraKey, error) {
+	if public_key == nil {
+		return CameraKey{}, errors.New("photo has no public key")
+	}
+
+	key, ok := store.Keys[trust_store_index(public_key)]
+	if !ok {
+		return CameraKey{}, errors.New("camera key is not in the trust store")
+	}
+
+	return key, key.Valid(t)
+}
+
+// The public key of the camera that took the photo, if the verifier trusts it at time t.
+// With a Root_PublicKey, a photo with a certificate is trusted through it only, see Certificate.Verify(),
+// unless the TrustStore revokes it. Otherwise, without a TrustStore, only the Original_PublicKey is trusted.
+// Every failure is an ErrUntrustedKey.
+func (verifier_keys VerifierKeys) camera_public_key(public_key signature.PublicKey, certificate *Certificate, t time.Time) (signature.PublicKey, error) {
+	if certificate != nil && verifier_keys.Root_PublicKey != nil {
+		if err := certificate.Verify(verifier_keys.Root_PublicKey, t); err != nil {
+			return nil, err
+		}
+		if verifier_keys.TrustStore != nil {
+			if err := verifier_keys.TrustStore.Check_Certificate(*certificate); err != nil {
+				return nil, err
+			}
+		}
+		if public_key == nil || !bytes.Equal(public_key.Bytes(), certificate.PublicKey.Bytes()) {
+			return nil, fmt.Errorf("%w: public key of the photo is not the key of its certificate", ErrUntrustedKey)
+		}
+		return certificate.PublicKey, nil
+	}
+
+	if verifier_keys.TrustStore != nil {
+		key, err := verifier_keys.TrustStore.Lookup(public_key, t)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUntrustedKey, err)
+		}
+		return key.PublicKey, nil
+	}
+
+	if public_key == nil || !bytes.Equal(public_key.Bytes(), verifier_keys.Original_PublicKey.Bytes()) {
+		return nil, fmt.Errorf("%w: public key of the photo does not match the original public key", ErrUntrustedKey)
+	}
+	return verifier_keys.Original_PublicKey, nil
+}
+
+/*------------------------------------------ Trust Store File --------------------------------------*/
+
+type trust_store_file struct {
+	Keys                 []camera_key_file `json:"keys"`
+	Revoked_Certificates []uint64          `json:"revoked_certificates,omitempty"` // Serials
+}
+
+type camera_key_file struct {
+	DeviceID  string    `json:"device_id"`
+	PublicKey string    `json:"public_key"` // Hex encoded
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	Revoked   bool      `json:"revoked"`
+}
+
+// Write the trust store to a JSON file.
+func (store *TrustStore) Save(path string) error {
+	keys := make([]camera_key_file, 0, len(store.Keys))
+	for index, key := range store.Keys {
+		keys = append(keys, camera_key_file{
+			DeviceID:  key.DeviceID,
+			PublicKey: index,
+			NotBefore: key.NotBefore,
+			NotAfter:  key.NotAfter,
+			Revoked:   key.Revoked,
+		})
+	}
+
+	file := trust_store_file{Keys: keys}
+	for serial, revoked := range store.Revoked_Certificates {
+		if revoked {
+			file.Revoked_Certificates = append(file.Revoked_Certificates, serial)
+		}
+	}
+	slices.Sort(file.Revoked_Certificates)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Read a trust store written by Save().
+func LoadTrustStore(path string) (*TrustStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Trust stores written before certificates could be revoked are a list of keys.
+	var file trust_store_file
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &file.Keys)
+	} else {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	store := NewTrustStore()
+	for _, serial := range file.Revoked_Certificates {
+		store.Revoke_Certificate(serial)
+	}
+	for _, key := range file.Keys {
+		public_key_bytes, err := hex.DecodeString(key.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("public key of device %q: %w", key.DeviceID, err)
+		}
+
+		public_key, err := PublicKey_From_Bytes(public_key_bytes)
+		if err != nil {
+			return nil, fmt.Errorf("public key of device %q: %w", key.DeviceID, err)
+		}
+
+		store.Add(CameraKey{
+			DeviceID:  key.DeviceID,
+			PublicKey: public_key,
+			NotBefore: key.NotBefore,
+			NotAfter:  key.NotAfter,
+			Revoked:   key.Revoked,
+		})
+	}
+
+	return store, nil
+}
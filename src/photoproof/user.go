@@ -4,24 +4,24 @@ import (
 	"crypto/rand"
 	"fmt"
 
-	"github.com/consensys/gnark-crypto/hash"
+	eddsa_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
 	"github.com/consensys/gnark-crypto/signature"
 	ceddsa "github.com/consensys/gnark-crypto/signature/eddsa"
 	"github.com/drakstik/Photognark_V3/src/image"
 )
 
 type User struct {
-	SecretKey signature.Signer
+	SecretKey signature.Signer // Local secret key, nil if the key lives behind an external Signer
 	PublicKey signature.PublicKey
+	Signer    Signer // Signs on behalf of the user, if set. Otherwise the local SecretKey signs.
 }
 
-func NewUser() User {
+// A User with a new local secret key.
+func NewUser() (User, error) {
 	// 1. Generate a secret & public key using ceddsa.
 	secret_key, err := ceddsa.New(1, rand.Reader) // Generate a secret key for signing
 	if err != nil {
-		fmt.Println("func NewSecretKey(): Error while generating secret key using ceddsa...")
-		fmt.Print(err.Error())
-		return User{}
+		return User{}, fmt.Errorf("[NewUser()] %w: generating a secret key: %w", ErrSign, err)
 	}
 
 	public_key := secret_key.Public()
@@ -29,23 +29,39 @@ func NewUser() User {
 	return User{
 		SecretKey: secret_key,
 		PublicKey: public_key,
-	}
+	}, nil
+}
 
+// Read a public key from its compressed bytes, i.e. the output of PublicKey.Bytes().
+func PublicKey_From_Bytes(public_key_bytes []byte) (signature.PublicKey, error) {
+	public_key := new(eddsa_bn254.PublicKey)
+	if _, err := public_key.SetBytes(public_key_bytes); err != nil {
+		return nil, err
+	}
+	return public_key, nil
 }
 
-// Out-of-circuit signing function
+// Out-of-circuit signing function, with the hash function of img.
 func (user User) Sign(img image.Image) ([]byte, error) {
 	// Hash the img
 	digest := img.Hash()
 
-	// Instantiate MIMC BN254 hash function, to be used in signing the image
-	hFunc := hash.MIMC_BN254.New()
+	return user.Sign_Digest(digest, img.Hash_Function)
+}
+
+// Out-of-circuit signing function of an edit: sign z.Edit_Digest(), which covers the image, provenance and editors of z.
+func (user User) Sign_Edit(z image.Z) ([]byte, error) {
+	return user.Sign_Digest(z.Edit_Digest(), z.Img.Hash_Function)
+}
 
-	// Sign the digest with the hash function
-	signature, err := user.SecretKey.Sign(digest, hFunc)
+// Sign a digest, i.e. the big-endian bytes of a field element, like the output of a MiMC hash.
+// hash_function is the hash of the signature, see image.Hash_Function.
+func (user User) Sign_Digest(digest []byte, hash_function image.Hash_Function) ([]byte, error) {
+	// Sign the digest, wherever the secret key lives
+	signature, err := user.signer().Sign_Digest(digest, hash_function)
 	if err != nil {
-		fmt.Println("Error while signing image: " + err.Error())
-		return nil, err
+		Logger().Debug("signing a digest failed", "err", err)
+		return nil, fmt.Errorf("%w: %w", ErrSign, err)
 	}
 
 	return signature, err
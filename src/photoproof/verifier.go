@@ -1,10 +1,11 @@
 package photoproof
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/consensys/gnark-crypto/ecc"
-	"github.com/consensys/gnark-crypto/hash"
 	"github.com/consensys/gnark/backend/groth16"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/std/signature/eddsa"
@@ -16,57 +17,185 @@ import (
 //
 //	(a) the PCD Proof is valid for the image with its attached original hash, and
 //	(b) the signature of the original hash is valid under the signature scheme's public key.
+//
+// If proof_in has a Certificate and the verifier keys a Root_PublicKey, the public key is trusted through the
+// certificate, see Verify_Certified().
 func (user User) Verify(verifier_keys VerifierKeys, z_in image.Z, proof_in Proof) (bool, error) {
+	fr_predicate, _ := No_Predicate()
+	return user.verify(verifier_keys, z_in, proof_in, fr_predicate)
+}
 
-	hFunc := hash.NewHash("MIMC_BN254")
-
-	// (b) the signature of the original hash is valid under the signature scheme's public key.
-	verifier_keys.Original_PublicKey.Verify(z_in.OriginalSignature, z_in.OriginalHash, hFunc)
-
-	// If the proof does NOT have a PCD_Proof, i.e. it's just a signature, then it's an original iamge
+// Verify a photo, and that predicate holds for its hidden capture location, see Prove_Predicate().
+func (user User) Verify_Predicate(verifier_keys VerifierKeys, z_in image.Z, proof_in Proof, predicate Predicate) (bool, error) {
+	if err := predicate.location_only("Verify_Predicate"); err != nil {
+		return false, err
+	}
 	if proof_in.PCD_Proof == nil {
+		return false, fmt.Errorf("[Verify_Predicate()] %w: a predicate needs a PCD proof", ErrMalformed)
+	}
+	return user.verify(verifier_keys, z_in, proof_in, predicate.ToFr())
+}
 
-		// Then verify the signature with the image, using the original public key.
-		hFunc = hash.NewHash("MIMC_BN254")
-
-		verifier_keys.Original_PublicKey.Verify(proof_in.Signature, z_in.Img.Hash(), hFunc)
-
-	} else { // Else PCD_Proof exists, image has had at least identity transformation
+func (user User) verify(verifier_keys VerifierKeys, z_in image.Z, proof_in Proof, fr_predicate Fr_Predicate) (bool, error) {
+	_, err := user.verify_phases(context.Background(), &Timings{}, verifier_keys, z_in, proof_in, fr_predicate)
+	return err == nil, err
+}
 
-		// Assign the input signature to its eddsa equivilant
-		var eddsa_digSig eddsa.Signature
-		eddsa_digSig.Assign(1, proof_in.Signature)
+// Like Verify(), but stops between the checks, signature and proof phases once ctx is done,
+// and returns the time spent in each phase that ran. A phase that already started runs to its end.
+func (user User) Verify_Context(ctx context.Context, verifier_keys VerifierKeys, z_in image.Z, proof_in Proof) (bool, Timings, error) {
+	var timings Timings
+	fr_predicate, _ := No_Predicate()
+	_, err := user.verify_phases(ctx, &timings, verifier_keys, z_in, proof_in, fr_predicate)
+	return err == nil, timings, err
+}
 
-		/* (a) the PCD Proof is valid for the image with its attached original hash */
+func (user User) verify_phases(ctx context.Context, timings *Timings, verifier_keys VerifierKeys, z_in image.Z, proof_in Proof, fr_predicate Fr_Predicate) (bool, error) {
+	var checks []signature_check
+	var circuit *Permissible_Transformations
+	err := run_phase(ctx, timings, "checks", func() (err error) {
+		checks, circuit, err = verifier_keys.prepare(z_in, proof_in, fr_predicate)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
 
-		// Recreate the constraint system with public values for identity transformation
-		circuit := Permissible_Transformations{
-			Output:    z_in.ToFr(),  // Public values
-			Signature: eddsa_digSig, // Public values
+	err = run_phase(ctx, timings, "signature", func() error {
+		for _, check := range checks {
+			if err := check.verify(); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
 
-		// Recreate a secret witness
-		secret_witness, err := frontend.NewWitness(circuit, ecc.BN254.ScalarField())
+	// (a) the PCD Proof is valid for the image with its attached original hash
+	if circuit != nil {
+		err := run_phase(ctx, timings, "proof", func() error {
+			return verify_circuit(circuit, proof_in.PCD_Proof, verifier_keys.VerifyingKey)
+		})
 		if err != nil {
-			fmt.Println("ERROR: frontend.NewWitness() while verifying proof...\n" + err.Error())
 			return false, err
 		}
+	}
 
-		// Recreate the public witness
-		public_witness, err := secret_witness.Public()
-		if err != nil {
-			fmt.Println("ERROR: secret_witness.Public() while verifying proof..")
-			return false, err
+	return true, nil
+}
+
+// Run the checks of verify() that need neither a signature nor a proof verification, and return the
+// signatures to verify, and the circuit assignment of the PCD proof if there is one.
+func (verifier_keys VerifierKeys) prepare(z_in image.Z, proof_in Proof, fr_predicate Fr_Predicate) ([]signature_check, *Permissible_Transformations, error) {
+	// The capture time is signed with the original hash, so camera keys are checked at capture time.
+	capture_time := time.Unix(int64(z_in.Timestamp), 0)
+
+	// The photo must be hashed and signed like the circuit of the keys.
+	if z_in.Img.Hash_Function != verifier_keys.Hash_Function {
+		return nil, nil, fmt.Errorf("[Verify()] %w: the image is hashed with %s, the keys with %s", ErrMalformed, z_in.Img.Hash_Function, verifier_keys.Hash_Function)
+	}
+
+	// The photo must claim a camera public key that the verifier trusts, or that its certificate attests.
+	original_public_key, err := verifier_keys.camera_public_key(z_in.PublicKey, proof_in.Certificate, capture_time)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[Verify()] %w", err)
+	}
+
+	// (b) the signature of the original hash (with capture time and counter) is valid under the signature scheme's public key.
+	checks := []signature_check{{
+		public_key: original_public_key,
+		signature:  z_in.OriginalSignature,
+		message:    z_in.Original_Digest(),
+		hash:       z_in.Img.Hash_Function,
+		err:        fmt.Errorf("[Verify()] %w: original signature is not valid for the original hash", ErrBadSignature),
+	}}
+
+	// The capture time must satisfy the verifier's policy.
+	if verifier_keys.TimePolicy != nil {
+		if err := verifier_keys.TimePolicy.Check(capture_time, time.Now()); err != nil {
+			return nil, nil, fmt.Errorf("[Verify()] %w", err)
 		}
+	}
 
-		// Verify the proof with the recreated public witness and verifying key
-		err = groth16.Verify(proof_in.PCD_Proof, verifier_keys.VerifyingKey, public_witness)
-		if err != nil {
-			fmt.Println("ERROR: VerifyGnarkProof failed.")
-			return false, err
+	// If the proof does NOT have a PCD_Proof, i.e. it's just a signature, then it's an original iamge
+	if proof_in.PCD_Proof == nil {
+
+		// An original image has no edits, and no editor.
+		if z_in.Provenance != (image.Provenance{}) {
+			return nil, nil, fmt.Errorf("[Verify()] %w: original image has a provenance", ErrMalformed)
+		}
+		if z_in.Editor != nil || z_in.Editors != nil {
+			return nil, nil, fmt.Errorf("[Verify()] %w: original image has an editor", ErrMalformed)
 		}
+
+		// Then verify the signature with the image, using the original public key.
+		checks = append(checks, signature_check{
+			public_key: original_public_key,
+			signature:  proof_in.Signature,
+			message:    z_in.Img.Hash(),
+			hash:       z_in.Img.Hash_Function,
+			err:        fmt.Errorf("[Verify()] %w: signature is not valid for the original image", ErrBadSignature),
+		})
+
+		return checks, nil, nil
 	}
 
-	return true, nil
+	// Else PCD_Proof exists, image has had at least identity transformation
+
+	// The editor of the last edit must be authorised.
+	if err := verifier_keys.Check_Editor(z_in, time.Now()); err != nil {
+		return nil, nil, fmt.Errorf("[Verify()] %w: %w", ErrUntrustedKey, err)
+	}
+
+	// Assign the input signature to its eddsa equivilant
+	var eddsa_digSig eddsa.Signature
+	eddsa_digSig.Assign(1, proof_in.Signature)
+
+	// Recreate the constraint system with public values for identity transformation
+	fr_z_in := z_in.ToFr()
+	_, location := No_Predicate()
+	circuit := Permissible_Transformations{
+		Output:    fr_z_in,      // Public values
+		Signature: eddsa_digSig, // Public values
+		Predicate: fr_predicate, // Public values
+
+		Policy_Hash: Policy_Hash(verifier_keys.Policy_ID), // Public values
+
+		// The secret values do not matter when verifying, but they cannot be nil.
+		Input:           fr_z_in,
+		Input_Signature: eddsa_digSig,
+		Parameters:      Fr_Identity_Tr_Params{},
+		Identity:        Fr_Identity_Transformation{Flag: frontend.Variable(0)},
+		Brightness:      Fr_Brightness_Transformation{Flag: frontend.Variable(0), Delta: frontend.Variable(0)},
+		Case_1:          frontend.Variable(0),
+		Location:        location,
+	}
+
+	return checks, &circuit, nil
+}
+
+// Verify the proof against the public values of the circuit's assignment.
+func verify_circuit(circuit frontend.Circuit, pcd_proof groth16.Proof, verifying_key groth16.VerifyingKey) error {
+	// Recreate a secret witness
+	secret_witness, err := frontend.NewWitness(circuit, ecc.BN254.ScalarField())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWitness, err)
+	}
+
+	// Recreate the public witness
+	public_witness, err := secret_witness.Public()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWitness, err)
+	}
+
+	// Verify the proof with the recreated public witness and verifying key
+	err = groth16.Verify(pcd_proof, verifying_key, public_witness)
+	if err != nil {
+		Logger().Debug("PCD proof rejected", "err", err)
+		return fmt.Errorf("%w: %w", ErrProofInvalid, err)
+	}
 
+	return nil
 }
@@ -0,0 +1,226 @@
+package photoproof
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/drakstik/Photognark_V3/src/image"
+)
+
+/*
+VerifierKeys are saved in a directory, so that a verification service can load them, see package server:
+
+	verifying_key.bin     The Groth16 verifying key, as written by ceremony.Finalize()
+	verifier_keys.json    The other keys and policies
+	trust_store.json      The TrustStore, if any
+	editors.json          The Editors, if any (same format as a TrustStore)
+*/
+
+const (
+	Verifying_Key_File = "verifying_key.bin"
+	Verifier_Keys_File = "verifier_keys.json"
+	Trust_Store_File   = "trust_store.json"
+	Editors_File       = "editors.json"
+)
+
+type verifier_keys_file struct {
+	Original_PublicKey string              `json:"original_public_key,omitempty"` // Hex encoded, like the other keys
+	Keys_Root          string              `json:"keys_root,omitempty"`
+	Keys_Depth         int                 `json:"keys_depth,omitempty"` // Omitted for Default_Keys_Depth
+	Root_PublicKey     string              `json:"root_public_key,omitempty"`
+	Policy_ID          string              `json:"policy_id,omitempty"`
+	TimePolicy         *time_policy_file   `json:"time_policy,omitempty"`
+	Hash               image.Hash_Function `json:"hash,omitempty"` // Omitted for MiMC
+}
+
+type time_policy_file struct {
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	MaxAge    string    `json:"max_age,omitempty"` // A time.Duration, e.g. "720h"
+	MaxSkew   string    `json:"max_skew,omitempty"`
+}
+
+// Write the verifier keys to dir, see LoadVerifierKeys().
+func (verifier_keys VerifierKeys) Save(dir string) error {
+	if verifier_keys.VerifyingKey == nil {
+		return errors.New("[VerifierKeys.Save()] no verifying key")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(dir, Verifying_Key_File))
+	if err != nil {
+		return err
+	}
+	if _, err := verifier_keys.VerifyingKey.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	public_key_hex := func(public_key signature.PublicKey) string {
+		if public_key == nil {
+			return ""
+		}
+		return hex.EncodeToString(public_key.Bytes())
+	}
+
+	file := verifier_keys_file{
+		Original_PublicKey: public_key_hex(verifier_keys.Original_PublicKey),
+		Keys_Root:          hex.EncodeToString(verifier_keys.Keys_Root),
+		Keys_Depth:         verifier_keys.Keys_Depth,
+		Root_PublicKey:     public_key_hex(verifier_keys.Root_PublicKey),
+		Policy_ID:          hex.EncodeToString(verifier_keys.Policy_ID),
+		Hash:               verifier_keys.Hash_Function,
+	}
+	if policy := verifier_keys.TimePolicy; policy != nil {
+		file.TimePolicy = &time_policy_file{NotBefore: policy.NotBefore, NotAfter: policy.NotAfter}
+		if policy.MaxAge != 0 {
+			file.TimePolicy.MaxAge = policy.MaxAge.String()
+		}
+		if policy.MaxSkew != 0 {
+			file.TimePolicy.MaxSkew = policy.MaxSkew.String()
+		}
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, Verifier_Keys_File), data, 0o644); err != nil {
+		return err
+	}
+
+	if verifier_keys.TrustStore != nil {
+		if err := verifier_keys.TrustStore.Save(filepath.Join(dir, Trust_Store_File)); err != nil {
+			return err
+		}
+	}
+	if verifier_keys.Editors != nil {
+		if err := verifier_keys.Editors.Save(filepath.Join(dir, Editors_File)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read verifier keys written by VerifierKeys.Save(). A ceremony directory also works, with only its verifying key.
+func LoadVerifierKeys(dir string) (VerifierKeys, error) {
+	var verifier_keys VerifierKeys
+
+	f, err := os.Open(filepath.Join(dir, Verifying_Key_File))
+	if err != nil {
+		return VerifierKeys{}, err
+	}
+	defer f.Close()
+
+	verifier_keys.VerifyingKey = groth16.NewVerifyingKey(ecc.BN254)
+	if _, err := verifier_keys.VerifyingKey.ReadFrom(f); err != nil {
+		return VerifierKeys{}, fmt.Errorf("[LoadVerifierKeys()] %s: %w", Verifying_Key_File, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, Verifier_Keys_File))
+	if err != nil && !os.IsNotExist(err) {
+		return VerifierKeys{}, err
+	}
+	if err == nil {
+		var file verifier_keys_file
+		if err := json.Unmarshal(data, &file); err != nil {
+			return VerifierKeys{}, fmt.Errorf("[LoadVerifierKeys()] %s: %w", Verifier_Keys_File, err)
+		}
+		if err := file.assign(&verifier_keys); err != nil {
+			return VerifierKeys{}, fmt.Errorf("[LoadVerifierKeys()] %s: %w", Verifier_Keys_File, err)
+		}
+	}
+
+	load_store := func(name string) (*TrustStore, error) {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, nil
+		}
+		store, err := LoadTrustStore(path)
+		if err != nil {
+			return nil, fmt.Errorf("[LoadVerifierKeys()] %s: %w", name, err)
+		}
+		return store, nil
+	}
+	if verifier_keys.TrustStore, err = load_store(Trust_Store_File); err != nil {
+		return VerifierKeys{}, err
+	}
+	if verifier_keys.Editors, err = load_store(Editors_File); err != nil {
+		return VerifierKeys{}, err
+	}
+
+	return verifier_keys, nil
+}
+
+// Decode the fields of the file into verifier_keys.
+func (file verifier_keys_file) assign(verifier_keys *VerifierKeys) error {
+	public_key := func(name string, s string) (signature.PublicKey, error) {
+		if s == "" {
+			return nil, nil
+		}
+		public_key_bytes, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return PublicKey_From_Bytes(public_key_bytes)
+	}
+	decode := func(name string, s string) ([]byte, error) {
+		if s == "" {
+			return nil, nil
+		}
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return b, nil
+	}
+
+	verifier_keys.Hash_Function = file.Hash
+	verifier_keys.Keys_Depth = file.Keys_Depth
+
+	var err error
+	if verifier_keys.Original_PublicKey, err = public_key("original_public_key", file.Original_PublicKey); err != nil {
+		return err
+	}
+	if verifier_keys.Root_PublicKey, err = public_key("root_public_key", file.Root_PublicKey); err != nil {
+		return err
+	}
+	if verifier_keys.Keys_Root, err = decode("keys_root", file.Keys_Root); err != nil {
+		return err
+	}
+	if verifier_keys.Policy_ID, err = decode("policy_id", file.Policy_ID); err != nil {
+		return err
+	}
+
+	if file.TimePolicy != nil {
+		policy := TimePolicy{NotBefore: file.TimePolicy.NotBefore, NotAfter: file.TimePolicy.NotAfter}
+		if file.TimePolicy.MaxAge != "" {
+			if policy.MaxAge, err = time.ParseDuration(file.TimePolicy.MaxAge); err != nil {
+				return fmt.Errorf("max_age: %w", err)
+			}
+		}
+		if file.TimePolicy.MaxSkew != "" {
+			if policy.MaxSkew, err = time.ParseDuration(file.TimePolicy.MaxSkew); err != nil {
+				return fmt.Errorf("max_skew: %w", err)
+			}
+		}
+		verifier_keys.TimePolicy = &policy
+	}
+
+	return nil
+}
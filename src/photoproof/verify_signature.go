@@ -3,38 +3,59 @@ package photoproof
 import (
 	tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
 	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/accumulator/merkle"
 	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+	"github.com/consensys/gnark/std/hash"
 	"github.com/consensys/gnark/std/hash/mimc"
 	"github.com/consensys/gnark/std/signature/eddsa"
 	"github.com/drakstik/Photognark_V3/src/image"
 )
 
+// return 1 if the image of z is its original image, 0 otherwise.
+// The original signature is asserted in any case: it is passed unchanged through every edit.
 func Verify_Original_Signature(api frontend.API, z image.Fr_Z) frontend.Variable {
 
 	// Hash the fr_image
-	digest, mimc := z.Img.Hash(api)
+	digest, h := z.Img.Hash(api)
+
+	// Verify the original hash, capture time, counter and metadata against the original signature
+	Verify_Signature(api, z.Original_Digest(api), z.OriginalSignature, z.PublicKey, h)
 
 	// Section V-F: the original hash either matches the image or ...
 	// Check if image's hash is original image hash
-	api.AssertIsEqual(z.OriginalHash, digest)
+	return api.IsZero(api.Sub(z.OriginalHash, digest))
+}
+
+// Verify that public_key is the leaf at keys_index of the Merkle tree of authorised camera keys, see KeySet.
+// keys_path holds the leaf value MiMC(A.X, A.Y), followed by the sibling of each level: its length sets the depth of the tree.
+func Verify_Camera_Membership(api frontend.API, public_key eddsa.PublicKey, keys_root frontend.Variable, keys_path []frontend.Variable, keys_index frontend.Variable) frontend.Variable {
+	h, _ := mimc.NewMiMC(api)
 
-	// Verify the image against the original signature)
-	Verify_Signature(api, digest, z.OriginalSignature, z.PublicKey, mimc)
+	// The leaf must be the public key
+	h.Write(public_key.A.X, public_key.A.Y)
+	api.AssertIsEqual(keys_path[0], h.Sum())
+
+	// and the leaf must be in the tree.
+	proof := merkle.MerkleProof{
+		RootHash: keys_root,
+		Path:     keys_path,
+	}
+	proof.VerifyProof(api, &h, keys_index)
 
 	return 1
 }
 
-// Verify z.img, as a digest, against the dig_sig and z.PublicKey
-func Verify_Signature(api frontend.API, digest frontend.Variable, dig_sig eddsa.Signature, public_key eddsa.PublicKey, mimc mimc.MiMC) frontend.Variable {
+// Verify z.img, as a digest, against the dig_sig and z.PublicKey, with h the hasher of the image (see Fr_Image.Hash()).
+func Verify_Signature(api frontend.API, digest frontend.Variable, dig_sig eddsa.Signature, public_key eddsa.PublicKey, h hash.FieldHasher) frontend.Variable {
 
-	// Hash the fr_image
-	// digest, mimc := z.Img.Hash(api)
+	// Reset the hasher, so that H(R, A, M) is not seeded with the state left over from hashing the image.
+	h.Reset()
 
 	// Set the twisted edwards curve to use
 	curve, _ := twistededwards.NewEdCurve(api, tedwards.BN254)
 
 	// verify the digest against the signature, using the public key
-	eddsa.Verify(curve, dig_sig, digest, public_key, &mimc)
+	eddsa.Verify(curve, dig_sig, digest, public_key, h)
 
 	return 1
 }
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+// A client of a Proving_Server, which applies and signs edits locally with the key of Editor.
+type Client struct {
+	URL    string // e.g. http://127.0.0.1:8081
+	Editor photoproof.User
+}
+
+// Sign the edit of photo and submit it, and return its queued job.
+func (client Client) Submit(photo camera.Photograph, tr photoproof.Transformation, params photoproof.Transformation_Parameters) (Job, error) {
+	container, err := photo.Container("")
+	if err != nil {
+		return Job{}, err
+	}
+	parameters, err := Encode_Parameters(params)
+	if err != nil {
+		return Job{}, err
+	}
+
+	request := Edit_Request{Photo: container, Transformation: tr.GetName(), Parameters: parameters}
+
+	// Only edits of a PCD proof are signed by the editor, see photoproof.User.Prove().
+	if photo.Proof.PCD_Proof != nil {
+		z_out, err := photoproof.Next_Z(photo.Z, tr, params, client.Editor.PublicKey)
+		if err != nil {
+			return Job{}, err
+		}
+		signature, err := client.Editor.Sign_Edit(z_out)
+		if err != nil {
+			return Job{}, err
+		}
+		request.Editor = hex.EncodeToString(client.Editor.PublicKey.Bytes())
+		request.Signature = hex.EncodeToString(signature)
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return Job{}, err
+	}
+
+	var job Job
+	err = client.call(http.MethodPost, "/edit", body, http.StatusAccepted, &job)
+	return job, err
+}
+
+func (client Client) Job(id string) (Job, error) {
+	var job Job
+	err := client.call(http.MethodGet, "/jobs/"+id, nil, http.StatusOK, &job)
+	return job, err
+}
+
+// The edited photo of a finished job. It has no prover or verifier keys.
+func (client Client) Result(id string) (camera.Photograph, error) {
+	var container json.RawMessage
+	if err := client.call(http.MethodGet, "/jobs/"+id+"/result", nil, http.StatusOK, &container); err != nil {
+		return camera.Photograph{}, err
+	}
+	photo, _, err := camera.Open_Container(container)
+	return photo, err
+}
+
+// Poll the job every interval until it is finished, and return its edited photo.
+func (client Client) Wait(ctx context.Context, id string, interval time.Duration) (camera.Photograph, error) {
+	for {
+		job, err := client.Job(id)
+		if err != nil {
+			return camera.Photograph{}, err
+		}
+		switch job.Status {
+		case Job_Done:
+			return client.Result(id)
+		case Job_Failed:
+			return camera.Photograph{}, fmt.Errorf("job %s failed: %s", id, job.Error)
+		}
+
+		select {
+		case <-ctx.Done():
+			return camera.Photograph{}, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (client Client) call(method string, path string, body []byte, expect_status int, response_body any) error {
+	request, err := http.NewRequest(method, client.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != expect_status {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(data, &failure)
+		return fmt.Errorf("%s %s: %s: %s", method, path, response.Status, failure.Error)
+	}
+
+	return json.Unmarshal(data, response_body)
+}
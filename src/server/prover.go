@@ -0,0 +1,495 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+)
+
+/*
+A local HTTP proving service, for editors whose machines are too slow to prove:
+
+	POST /edit                An Edit_Request. Answers the queued Job, with status 202.
+	GET  /jobs/{id}           The Job, without its photos.
+	GET  /jobs/{id}/result    The photo container of the edited photo, once the job is done.
+
+The editor applies and signs the edit itself, see Client, and only sends its public key and signature:
+the service never holds editor secrets. Only the edits of the Editors are proven, of photos whose last editor
+is one of them too, so that the service does not prove edits that viewers would not trust. Every job is saved
+in Dir, and queued again after a restart if it was not finished. At most Max_Queued jobs wait, and only the
+last Max_Finished finished jobs are kept.
+*/
+
+const (
+	Job_Queued  = "queued"
+	Job_Running = "running"
+	Job_Done    = "done"
+	Job_Failed  = "failed"
+
+	Default_Max_Queued   = 64  // See Proving_Server.Max_Queued
+	Default_Max_Finished = 256 // See Proving_Server.Max_Finished
+)
+
+var ErrQueueFull = errors.New("too many queued jobs")
+
+// An edit to prove.
+type Edit_Request struct {
+	Photo          json.RawMessage `json:"photo"`                // Photo container of the input, see camera.Photograph.Container()
+	Transformation string          `json:"transformation"`       // "identity" or "brightness"
+	Parameters     json.RawMessage `json:"parameters,omitempty"` // e.g. {"delta": 10} for brightness
+	Editor         string          `json:"editor,omitempty"`     // Hex encoded public key of the editor
+	Signature      string          `json:"signature,omitempty"`  // Hex encoded signature of the edit by the editor, see photoproof.User.Sign_Edit()
+}
+
+type Job struct {
+	ID        string             `json:"id"`
+	Status    string             `json:"status"`
+	Error     string             `json:"error,omitempty"`
+	Submitted time.Time          `json:"submitted"`
+	Finished  time.Time          `json:"finished,omitzero"`
+	Timings   photoproof.Timings `json:"timings,omitempty"`
+	Request   *Edit_Request      `json:"request,omitempty"`
+	Result    json.RawMessage    `json:"result,omitempty"` // Photo container of the edited photo, once done
+}
+
+type Proving_Server struct {
+	Key_ID  string // The key ID of the edited photos, see Server
+	Prover  photoproof.ProverKeys
+	Dir     string // Where jobs are saved
+	Workers int
+	Editors *photoproof.TrustStore // The editors whose edits are proven. If nil, only originals are converted
+
+	Max_Queued   int // Submit() refuses jobs beyond this many queued ones
+	Max_Finished int // The oldest finished jobs beyond this many are removed, with their files
+
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	queue []string // IDs of the queued jobs, in order
+	ready *sync.Cond
+}
+
+// Create a proving service saving its jobs in dir, and load the jobs of a previous run.
+// The circuit of prover is compiled once for every job.
+func NewProving_Server(key_id string, prover photoproof.ProverKeys, dir string) (*Proving_Server, error) {
+	if prover.Compiled == nil {
+		if err := prover.Compile(); err != nil {
+			return nil, fmt.Errorf("[NewProving_Server()] %w", err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	server := &Proving_Server{Key_ID: key_id, Prover: prover, Dir: dir, Workers: 1, Max_Queued: Default_Max_Queued, Max_Finished: Default_Max_Finished, jobs: map[string]*Job{}}
+	server.ready = sync.NewCond(&server.mu)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var unfinished []*Job
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			return nil, fmt.Errorf("[NewProving_Server()] job %s: %w", entry.Name(), err)
+		}
+		server.jobs[job.ID] = &job
+		if job.Status == Job_Queued || job.Status == Job_Running {
+			unfinished = append(unfinished, &job)
+		}
+	}
+
+	// Queue the unfinished jobs again, in submission order.
+	for len(unfinished) > 0 {
+		first := 0
+		for i, job := range unfinished {
+			if job.Submitted.Before(unfinished[first].Submitted) {
+				first = i
+			}
+		}
+		unfinished[first].Status = Job_Queued
+		server.queue = append(server.queue, unfinished[first].ID)
+		unfinished = append(unfinished[:first], unfinished[first+1:]...)
+	}
+	server.evict()
+
+	return server, nil
+}
+
+// Prove the queued jobs with Workers workers until ctx is done.
+// A proof stops between its phases when ctx is done, and its job is queued again at the next start.
+func (server *Proving_Server) Start(ctx context.Context) {
+	context.AfterFunc(ctx, func() {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		server.ready.Broadcast()
+	})
+
+	for w := 0; w < max(server.Workers, 1); w++ {
+		go func() {
+			for {
+				job, ok := server.next(ctx)
+				if !ok {
+					return
+				}
+				server.run(ctx, job)
+			}
+		}()
+	}
+}
+
+// Wait for a queued job and mark it running, or return false once ctx is done.
+func (server *Proving_Server) next(ctx context.Context) (Job, bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	for len(server.queue) == 0 {
+		if ctx.Err() != nil {
+			return Job{}, false
+		}
+		server.ready.Wait()
+	}
+	if ctx.Err() != nil {
+		return Job{}, false
+	}
+
+	job := server.jobs[server.queue[0]]
+	server.queue = server.queue[1:]
+	job.Status = Job_Running
+	if err := server.save(job); err != nil {
+		photoproof.Logger().Error("saving a job failed", "job", job.ID, "err", err)
+	}
+	return *job, true
+}
+
+func (server *Proving_Server) run(ctx context.Context, job Job) {
+	result, timings, err := server.prove(ctx, *job.Request)
+	if ctx.Err() != nil {
+		return // Still running on disk, so queued again at the next start
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	saved := server.jobs[job.ID]
+	saved.Timings = timings
+	saved.Finished = time.Now()
+	if err != nil {
+		saved.Status = Job_Failed
+		saved.Error = err.Error()
+	} else {
+		saved.Status = Job_Done
+		saved.Result = result
+	}
+	if err := server.save(saved); err != nil {
+		photoproof.Logger().Error("saving a job failed", "job", saved.ID, "err", err)
+	}
+	photoproof.Logger().Info("job finished", "job", saved.ID, "status", saved.Status, "error", saved.Error, "duration", timings.Total())
+	server.evict()
+}
+
+// Remove the oldest finished jobs beyond Max_Finished, from memory and from Dir. Called with mu held.
+func (server *Proving_Server) evict() {
+	var finished []*Job
+	for _, job := range server.jobs {
+		if job.Status == Job_Done || job.Status == Job_Failed {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= server.Max_Finished {
+		return
+	}
+
+	slices.SortFunc(finished, func(a, b *Job) int { return a.Finished.Compare(b.Finished) })
+	for _, job := range finished[:len(finished)-server.Max_Finished] {
+		delete(server.jobs, job.ID)
+		if err := os.Remove(filepath.Join(server.Dir, job.ID+".json")); err != nil && !os.IsNotExist(err) {
+			photoproof.Logger().Error("removing a job failed", "job", job.ID, "err", err)
+		}
+	}
+}
+
+// Prove the edit of request, signed by its editor, and return the container of the edited photo.
+func (server *Proving_Server) prove(ctx context.Context, request Edit_Request) ([]byte, photoproof.Timings, error) {
+	photo, _, err := camera.Open_Container(request.Photo)
+	if err != nil {
+		return nil, nil, err
+	}
+	tr, params, err := Parse_Transformation(request.Transformation, request.Parameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// An original photo is only converted to a PCD proof, which needs no editor signature.
+	// The editors are checked again, they may have been revoked since the job was queued.
+	user := photoproof.User{}
+	if photo.Proof.PCD_Proof != nil {
+		if user, err = request.editor(); err != nil {
+			return nil, nil, err
+		}
+		if err := server.authorised(photo, user); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	z_out, proof_out, timings, err := user.Prove_Context(ctx, server.Prover, photo.Z, tr, params, photo.Proof)
+	if err != nil {
+		return nil, timings, err
+	}
+
+	// proof_out keeps the certificate of the camera key, so that the edited photo still links to the root key.
+	edited := camera.Photograph{Z: z_out, Proof: proof_out, Disclosure: photo.Disclosure}
+	result, err := edited.Container(server.Key_ID)
+	return result, timings, err
+}
+
+// The editor of the request, who can only sign with the signature of the request.
+func (request Edit_Request) editor() (photoproof.User, error) {
+	public_key_bytes, err := hex.DecodeString(request.Editor)
+	if err != nil || len(public_key_bytes) == 0 {
+		return photoproof.User{}, errors.New("an edit needs the hex encoded public key of its editor")
+	}
+	public_key, err := photoproof.PublicKey_From_Bytes(public_key_bytes)
+	if err != nil {
+		return photoproof.User{}, fmt.Errorf("editor: %w", err)
+	}
+	signature, err := hex.DecodeString(request.Signature)
+	if err != nil || len(signature) == 0 {
+		return photoproof.User{}, errors.New("an edit needs the hex encoded signature of its editor")
+	}
+	return photoproof.NewUser_From_Signer(photoproof.PresignedSigner{PublicKey: public_key, Signature: signature}), nil
+}
+
+// Check that editor, and the last editor of photo, are Editors.
+func (server *Proving_Server) authorised(photo camera.Photograph, editor photoproof.User) error {
+	if server.Editors == nil {
+		return fmt.Errorf("%w: the service proves no edits", photoproof.ErrUntrustedKey)
+	}
+	if _, err := server.Editors.Lookup(editor.PublicKey, time.Now()); err != nil {
+		return fmt.Errorf("%w: editor: %w", photoproof.ErrUntrustedKey, err)
+	}
+	if err := (photoproof.VerifierKeys{Editors: server.Editors}).Check_Editor(photo.Z, time.Now()); err != nil {
+		return fmt.Errorf("%w: input: %w", photoproof.ErrUntrustedKey, err)
+	}
+	return nil
+}
+
+// Check the request before queuing it, so that bad requests are answered at once.
+func (server *Proving_Server) check(request Edit_Request) error {
+	photo, _, err := camera.Open_Container(request.Photo)
+	if err != nil {
+		return err
+	}
+	tr, params, err := Parse_Transformation(request.Transformation, request.Parameters)
+	if err != nil {
+		return err
+	}
+
+	if photo.Proof.PCD_Proof == nil {
+		if tr.GetName() != "identity" {
+			return errors.New("an original photo can only be converted with the identity, prove it before editing it")
+		}
+		return nil
+	}
+
+	editor, err := request.editor()
+	if err != nil {
+		return err
+	}
+	if err := server.authorised(photo, editor); err != nil {
+		return err
+	}
+	z_out, err := photoproof.Next_Z(photo.Z, tr, params, editor.PublicKey)
+	if err != nil {
+		return err
+	}
+	if _, err := editor.Sign_Edit(z_out); err != nil {
+		return fmt.Errorf("editor signature: %w", err)
+	}
+	return nil
+}
+
+// Save the job to Dir, replacing the previous version of the file at once. Called with mu held.
+func (server *Proving_Server) save(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(server.Dir, job.ID+".json")
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Queue the edit of request, and return its job.
+func (server *Proving_Server) Submit(request Edit_Request) (Job, error) {
+	if err := server.check(request); err != nil {
+		return Job{}, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Job{}, err
+	}
+	job := &Job{ID: hex.EncodeToString(id), Status: Job_Queued, Submitted: time.Now(), Request: &request}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if len(server.queue) >= server.Max_Queued {
+		return Job{}, ErrQueueFull
+	}
+	if err := server.save(job); err != nil {
+		return Job{}, err
+	}
+	server.jobs[job.ID] = job
+	server.queue = append(server.queue, job.ID)
+	server.ready.Signal()
+
+	return *job, nil
+}
+
+// The job with id, if any.
+func (server *Proving_Server) Job(id string) (Job, bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	job, ok := server.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+func (server *Proving_Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /edit", server.edit)
+	mux.HandleFunc("GET /jobs/{id}", server.status)
+	mux.HandleFunc("GET /jobs/{id}/result", server.result)
+	return mux
+}
+
+func (server *Proving_Server) edit(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, Max_Body))
+	if err != nil {
+		write_error(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+
+	var request Edit_Request
+	if err := json.Unmarshal(body, &request); err != nil {
+		write_error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	job, err := server.Submit(request)
+	switch {
+	case errors.Is(err, ErrQueueFull):
+		w.Header().Set("Retry-After", "60")
+		write_error(w, http.StatusServiceUnavailable, err)
+		return
+	case errors.Is(err, photoproof.ErrUntrustedKey):
+		write_error(w, http.StatusForbidden, err)
+		return
+	case err != nil:
+		write_error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	write_json(w, http.StatusAccepted, job.summary())
+}
+
+func (server *Proving_Server) status(w http.ResponseWriter, r *http.Request) {
+	job, ok := server.Job(r.PathValue("id"))
+	if !ok {
+		write_error(w, http.StatusNotFound, fmt.Errorf("unknown job %q", r.PathValue("id")))
+		return
+	}
+	write_json(w, http.StatusOK, job.summary())
+}
+
+func (server *Proving_Server) result(w http.ResponseWriter, r *http.Request) {
+	job, ok := server.Job(r.PathValue("id"))
+	if !ok {
+		write_error(w, http.StatusNotFound, fmt.Errorf("unknown job %q", r.PathValue("id")))
+		return
+	}
+
+	switch job.Status {
+	case Job_Done:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(job.Result)
+	case Job_Failed:
+		write_error(w, http.StatusUnprocessableEntity, fmt.Errorf("job failed: %s", job.Error))
+	default:
+		write_error(w, http.StatusConflict, fmt.Errorf("job is %s", job.Status))
+	}
+}
+
+// The job without its photos.
+func (job Job) summary() Job {
+	job.Request = nil
+	job.Result = nil
+	return job
+}
+
+/*------------------------------------------ Transformations on the wire --------------------------------------*/
+
+type brightness_parameters struct {
+	Delta int `json:"delta"`
+}
+
+// The transformation named name, with its JSON parameters.
+func Parse_Transformation(name string, parameters []byte) (photoproof.Transformation, photoproof.Transformation_Parameters, error) {
+	switch name {
+	case "identity":
+		return photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}, nil
+	case "brightness":
+		var params brightness_parameters
+		if len(parameters) > 0 {
+			if err := json.Unmarshal(parameters, &params); err != nil {
+				return nil, nil, fmt.Errorf("brightness parameters: %w", err)
+			}
+		}
+		if params.Delta < -255 || params.Delta > 255 {
+			return nil, nil, errors.New("brightness needs a delta in [-255, 255]")
+		}
+		return photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: params.Delta}, nil
+	}
+	return nil, nil, fmt.Errorf("unknown transformation %q", name)
+}
+
+// The JSON parameters of params, see Parse_Transformation().
+func Encode_Parameters(params photoproof.Transformation_Parameters) ([]byte, error) {
+	switch params := params.(type) {
+	case photoproof.Identity_Tr_Params:
+		return nil, nil
+	case photoproof.Brightness_Tr_Params:
+		return json.Marshal(brightness_parameters{Delta: params.Delta})
+	}
+	return nil, fmt.Errorf("unknown parameters %q", params.GetName())
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+	"github.com/drakstik/Photognark_V3/src/viewer"
+)
+
+/*
+A local HTTP verification service, e.g. for a CMS to check photos before publishing them:
+
+	POST /verify    A photo container (application/json) or a PNG with an embedded container (image/png),
+	                see camera.Photograph.Container() and camera.Photograph.Embed_PNG().
+	                Answers a Result, with status 200 whether the photo verifies or not.
+
+The photo is verified with the keys named by the "key" query parameter, else by the key ID of its container,
+else with the only keys of the server.
+*/
+
+// Largest request body, far above the size of a container.
+const Max_Body = 1 << 20
+
+// The verification result of a photo.
+type Result struct {
+	OK         bool                  `json:"ok"`
+	Key_ID     string                `json:"key_id"`
+	Stage      string                `json:"stage,omitempty"` // Where verification failed, see photoproof.Verification_Result
+	Error      string                `json:"error,omitempty"`
+	Original   bool                  `json:"original"` // Signed by the camera, not edited
+	Captured   time.Time             `json:"captured"` // Public in the main circuit, only the private circuit hides it
+	Provenance Provenance            `json:"provenance"`
+	Editor     string                `json:"editor,omitempty"`    // Hex encoded key of the last editor
+	Certified  string                `json:"certified,omitempty"` // Device ID of the certificate that attests the camera key, if any
+	Predicate  *photoproof.Predicate `json:"predicate,omitempty"`
+	Disclosed  map[string]string     `json:"disclosed,omitempty"` // The disclosed metadata fields
+}
+
+type Provenance struct {
+	Edits      uint64            `json:"edits"`
+	Uses       map[string]uint64 `json:"uses"`
+	Brightness uint64            `json:"brightness"`
+}
+
+type Server struct {
+	Keys   map[string]photoproof.VerifierKeys // By key ID
+	Viewer viewer.Viewer
+}
+
+func New(keys map[string]photoproof.VerifierKeys) *Server {
+	return &Server{
+		Keys:   keys,
+		Viewer: viewer.Viewer{Viewer: photoproof.User{}}, // Verifying needs no key of the viewer
+	}
+}
+
+// Load the keys of every subdirectory of dir, named by their key ID, see photoproof.VerifierKeys.Save().
+func Load(dir string) (*Server, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]photoproof.VerifierKeys{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		verifier_keys, err := photoproof.LoadVerifierKeys(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("[server.Load()] keys %q: %w", entry.Name(), err)
+		}
+		keys[entry.Name()] = verifier_keys
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("[server.Load()] no keys in %s", dir)
+	}
+	return New(keys), nil
+}
+
+// The IDs of the keys of the server, sorted.
+func (server *Server) Key_IDs() []string {
+	ids := make([]string, 0, len(server.Keys))
+	for id := range server.Keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (server *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /verify", server.verify)
+	return mux
+}
+
+func (server *Server) verify(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, Max_Body))
+	if err != nil {
+		write_error(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+
+	var photo camera.Photograph
+	var key_id string
+	content_type := strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0])
+	switch {
+	case content_type == "image/png" || (content_type == "" && bytes.HasPrefix(body, []byte("\x89PNG"))):
+		photo, key_id, err = camera.Extract_PNG(body)
+	case content_type == "application/json" || content_type == "":
+		photo, key_id, err = camera.Open_Container(body)
+	default:
+		write_error(w, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q", content_type))
+		return
+	}
+	if err != nil {
+		write_error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if query := r.URL.Query().Get("key"); query != "" {
+		key_id = query
+	}
+	if key_id == "" && len(server.Keys) == 1 {
+		key_id = server.Key_IDs()[0]
+	}
+	verifier_keys, ok := server.Keys[key_id]
+	if !ok {
+		write_error(w, http.StatusNotFound, fmt.Errorf("unknown key ID %q", key_id))
+		return
+	}
+
+	write_json(w, http.StatusOK, server.Verify(key_id, verifier_keys, photo))
+}
+
+// Verify photo with verifier_keys, named key_id.
+func (server *Server) Verify(key_id string, verifier_keys photoproof.VerifierKeys, photo camera.Photograph) Result {
+	verification := server.Viewer.VerifyBatch(verifier_keys, []camera.Photograph{photo})[0]
+
+	z := photo.Z
+	result := Result{
+		OK:       verification.OK,
+		Key_ID:   key_id,
+		Stage:    verification.Stage,
+		Original: photo.Proof.PCD_Proof == nil,
+		Captured: time.Unix(int64(z.Timestamp), 0).UTC(),
+		Provenance: Provenance{
+			Edits:      z.Provenance.Edits,
+			Uses:       map[string]uint64{},
+			Brightness: z.Provenance.Brightness,
+		},
+		Predicate: photo.Predicate,
+	}
+	if verification.Err != nil {
+		result.Error = verification.Err.Error()
+	}
+	for _, name := range []string{"identity", "brightness"} {
+		result.Provenance.Uses[name] = z.Provenance.Uses[photoproof.Transformation_Index(name)]
+	}
+	if z.Editor != nil {
+		result.Editor = hex.EncodeToString(z.Editor.Bytes())
+	}
+	if certificate := photo.Proof.Certificate; verification.OK && certificate != nil && verifier_keys.Root_PublicKey != nil {
+		result.Certified = certificate.DeviceID
+	}
+
+	// Only report metadata that matches the signed commitment
+	if verification.OK && photo.Disclosure != nil {
+		result.Disclosed = map[string]string{}
+		for _, field := range photo.Disclosure.Fields {
+			if field.Disclosed {
+				result.Disclosed[field.Name] = field.Value
+			}
+		}
+	}
+
+	return result
+}
+
+func write_json(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func write_error(w http.ResponseWriter, status int, err error) {
+	var too_large *http.MaxBytesError
+	if status == http.StatusRequestEntityTooLarge && !errors.As(err, &too_large) {
+		status = http.StatusBadRequest
+	}
+	write_json(w, status, map[string]string{"error": err.Error()})
+}
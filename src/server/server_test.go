@@ -0,0 +1,301 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drakstik/Photognark_V3/src/camera"
+	"github.com/drakstik/Photognark_V3/src/editor"
+	"github.com/drakstik/Photognark_V3/src/photoproof"
+	"github.com/drakstik/Photognark_V3/src/server"
+)
+
+/*
+The endpoints of the verification and proving services, each with honest, tampered and malformed requests.
+Original photos need no keys and are checked in short mode, the photos proven with the main circuit are not.
+*/
+
+// Post body to path of handler, and return the status and body of the response.
+func post(t *testing.T, handler http.Handler, path string, content_type string, body []byte) (int, []byte) {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	if content_type != "" {
+		request.Header.Set("Content-Type", content_type)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder.Code, recorder.Body.Bytes()
+}
+
+// Post the container of photo to POST /verify, and check the status and result.
+func verify(t *testing.T, handler http.Handler, photo camera.Photograph, expect_ok bool) server.Result {
+	t.Helper()
+
+	container, err := photo.Container("newsroom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, body := post(t, handler, "/verify", "application/json", container)
+	var result server.Result
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("status %d: %v: %s", status, err, body)
+	}
+	if status != http.StatusOK || result.OK != expect_ok {
+		t.Errorf("status %d, ok=%t, expected ok=%t (%s: %s)", status, result.OK, expect_ok, result.Stage, result.Error)
+	}
+	return result
+}
+
+// A camera certified by a manufacturer, without circuit keys, and the verifier keys that trust the manufacturer.
+func certified_camera(t *testing.T) (camera.Camera, photoproof.VerifierKeys) {
+	t.Helper()
+
+	manufacturer, err := photoproof.NewManufacturer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cam := camera.Camera{Admin: must_user()}
+	if err := cam.Rotate(manufacturer, "camera-1", 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	verifier_keys := cam.Verifier
+	verifier_keys.Root_PublicKey = manufacturer.Root.PublicKey
+	return cam, verifier_keys
+}
+
+// The keys of the main circuit are generated once, for every test that proves.
+var proven struct {
+	once      sync.Once
+	err       error
+	cam       camera.Camera
+	converted camera.Photograph // The PCD proof of a certified original
+}
+
+// A certified camera with keys of the main circuit, and a converted photo of it.
+func proven_camera(t *testing.T) (camera.Camera, camera.Photograph) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("generates the keys of the main circuit and proves photographs")
+	}
+
+	proven.once.Do(func() {
+		manufacturer, err := photoproof.NewManufacturer()
+		if err != nil {
+			proven.err = err
+			return
+		}
+		circuit := photoproof.Permissible_Transformations{}
+		if proven.cam, err = camera.NewCamera(&circuit); err != nil {
+			proven.err = err
+			return
+		}
+		if proven.err = proven.cam.Rotate(manufacturer, "camera-1", 24*time.Hour); proven.err != nil {
+			return
+		}
+		proven.cam.Verifier.Root_PublicKey = manufacturer.Root.PublicKey
+
+		photo, err := proven.cam.TakePhotograph("random")
+		if err != nil {
+			proven.err = err
+			return
+		}
+		ed := editor.Editor{Editor: proven.cam.Admin}
+		proven.converted, proven.err = ed.Edit(photo, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+	})
+	if proven.err != nil {
+		t.Fatal(proven.err)
+	}
+	return proven.cam, proven.converted
+}
+
+func TestVerifyEndpoint(t *testing.T) {
+	cam, verifier_keys := certified_camera(t)
+	handler := server.New(map[string]photoproof.VerifierKeys{"newsroom": verifier_keys}).Handler()
+
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("honest original", func(t *testing.T) {
+		result := verify(t, handler, photo, true)
+		if !result.Original || result.Certified != "camera-1" {
+			t.Errorf("result of a certified original: %+v", result)
+		}
+	})
+
+	t.Run("tampered original", func(t *testing.T) {
+		tampered := photo
+		tampered.Z.Img.Pxls[0].RGB[0] ^= 1
+		if result := verify(t, handler, tampered, false); result.Certified != "" {
+			t.Errorf("a tampered photo is certified by %q", result.Certified)
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, request := range []struct {
+			name         string
+			content_type string
+			body         []byte
+			status       int
+		}{
+			{"truncated JSON", "application/json", []byte(`{"pixels": `), http.StatusBadRequest},
+			{"not a container", "application/json", []byte(`{"pixels": "00"}`), http.StatusBadRequest},
+			{"PNG without container", "image/png", []byte("\x89PNG\r\n\x1a\n"), http.StatusBadRequest},
+			{"unsupported content", "text/plain", []byte("hello"), http.StatusUnsupportedMediaType},
+			{"too large", "application/json", make([]byte, server.Max_Body+1), http.StatusRequestEntityTooLarge},
+		} {
+			if status, body := post(t, handler, "/verify", request.content_type, request.body); status != request.status {
+				t.Errorf("%s: status %d, expected %d: %s", request.name, status, request.status, body)
+			}
+		}
+	})
+
+	t.Run("honest edit", func(t *testing.T) {
+		cam, converted := proven_camera(t)
+		handler := server.New(map[string]photoproof.VerifierKeys{"newsroom": cam.Verifier}).Handler()
+		result := verify(t, handler, converted, true)
+		if result.Original || result.Certified != "camera-1" {
+			t.Errorf("result of a certified edit: %+v", result)
+		}
+	})
+
+	t.Run("tampered edit", func(t *testing.T) {
+		cam, converted := proven_camera(t)
+		handler := server.New(map[string]photoproof.VerifierKeys{"newsroom": cam.Verifier}).Handler()
+		tampered := converted
+		tampered.Z.Img.Pxls[0].RGB[0] ^= 1
+		verify(t, handler, tampered, false)
+	})
+}
+
+func TestEditEndpoint(t *testing.T) {
+	cam, _ := certified_camera(t)
+	photo, err := cam.TakePhotograph("random")
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := photo.Container("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Malformed requests are answered before any job is queued, so the service needs no keys.
+	t.Run("malformed", func(t *testing.T) {
+		handler := (&server.Proving_Server{}).Handler()
+		for _, request := range []struct {
+			name string
+			body []byte
+		}{
+			{"truncated JSON", []byte(`{"photo": `)},
+			{"not a container", []byte(`{"photo": {"pixels": "00"}, "transformation": "identity"}`)},
+			{"unknown transformation", []byte(`{"photo": ` + string(original) + `, "transformation": "blur"}`)},
+			{"bad parameters", []byte(`{"photo": ` + string(original) + `, "transformation": "brightness", "parameters": {"delta": 300}}`)},
+			{"edit of an original", []byte(`{"photo": ` + string(original) + `, "transformation": "brightness", "parameters": {"delta": 10}}`)},
+		} {
+			if status, body := post(t, handler, "/edit", "application/json", request.body); status != http.StatusBadRequest {
+				t.Errorf("%s: status %d, expected %d: %s", request.name, status, http.StatusBadRequest, body)
+			}
+		}
+	})
+
+	t.Run("proven", func(t *testing.T) {
+		cam, converted := proven_camera(t)
+
+		prover, err := server.NewProving_Server("newsroom", cam.Prover, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		client_editor := must_user()
+		prover.Editors = photoproof.NewTrustStore()
+		prover.Editors.Add(photoproof.CameraKey{DeviceID: "remote", PublicKey: client_editor.PublicKey})
+		prover.Editors.Add(photoproof.CameraKey{DeviceID: "camera-1", PublicKey: cam.Admin.PublicKey})
+		prover.Start(t.Context())
+		service := httptest.NewServer(prover.Handler())
+		defer service.Close()
+		client := server.Client{URL: service.URL, Editor: client_editor}
+
+		verifier_keys := cam.Verifier
+		verifier_keys.Editors = prover.Editors
+		verifier := server.New(map[string]photoproof.VerifierKeys{"newsroom": verifier_keys}).Handler()
+
+		t.Run("honest edit", func(t *testing.T) {
+			job, err := client.Submit(converted, photoproof.Brightness_Transformation{}, photoproof.Brightness_Tr_Params{Delta: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			edited, err := client.Wait(t.Context(), job.ID, 200*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The edited photo keeps the certificate of the camera key.
+			if edited.Proof.Certificate == nil || edited.Proof.Certificate.Serial != converted.Proof.Certificate.Serial {
+				t.Errorf("the edited photo lost the certificate of the camera key")
+			}
+			result := verify(t, verifier, edited, true)
+			if result.Certified != "camera-1" || result.Provenance.Brightness != 10 || result.Editor != hex.EncodeToString(client_editor.PublicKey.Bytes()) {
+				t.Errorf("result of the edited photo: %+v", result)
+			}
+		})
+
+		t.Run("forged signature", func(t *testing.T) {
+			container, err := converted.Container("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			signature, err := must_user().Sign(converted.Z.Img)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := json.Marshal(server.Edit_Request{
+				Photo:          container,
+				Transformation: "identity",
+				Editor:         hex.EncodeToString(client_editor.PublicKey.Bytes()),
+				Signature:      hex.EncodeToString(signature),
+			})
+			if status, body := post(t, prover.Handler(), "/edit", "application/json", body); status != http.StatusBadRequest {
+				t.Errorf("status %d, expected %d: %s", status, http.StatusBadRequest, body)
+			}
+		})
+
+		t.Run("unauthorised editor", func(t *testing.T) {
+			outsider := server.Client{URL: service.URL, Editor: must_user()}
+			if _, err := outsider.Submit(converted, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{}); err == nil {
+				t.Errorf("the edit of an unauthorised editor was queued")
+			}
+		})
+
+		// The service does not verify the input when queuing it, but the circuit does when proving it.
+		t.Run("tampered input", func(t *testing.T) {
+			tampered := converted
+			tampered.Z.Img.Pxls[0].RGB[0] ^= 1
+			job, err := client.Submit(tampered, photoproof.Identity_Transformation{}, photoproof.Identity_Tr_Params{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.Wait(t.Context(), job.ID, 200*time.Millisecond); err == nil {
+				t.Errorf("the edit of a tampered photo was proven")
+			}
+			if job, _ := client.Job(job.ID); job.Status != server.Job_Failed {
+				t.Errorf("job is %s, expected %s", job.Status, server.Job_Failed)
+			}
+		})
+	})
+}
+
+// A new User, for tests.
+func must_user() photoproof.User {
+	user, err := photoproof.NewUser()
+	if err != nil {
+		panic(err)
+	}
+	return user
+}
@@ -13,15 +13,37 @@ type Viewer struct {
 
 func (v Viewer) View(photo camera.Photograph) error {
 	// Run photoproof.Verify() on the given photograph
-	ok, err := v.Viewer.Verify(photo.VerifierKeys, photo.Z, photo.Proof)
+	var ok bool
+	var err error
+	if photo.Predicate != nil {
+		// Check the claim about the hidden capture location and time
+		ok, err = v.Viewer.Verify_Predicate(photo.VerifierKeys, photo.Z, photo.Proof, *photo.Predicate)
+	} else {
+		// With a certificate, this validates the chain photo -> device key -> manufacturer root key
+		ok, err = v.Viewer.Verify(photo.VerifierKeys, photo.Z, photo.Proof)
+	}
 	if err != nil {
 		return err
 	}
 
+	// Only show metadata that matches the signed commitment
+	if ok && photo.Disclosure != nil {
+		if err := photo.Disclosure.Verify(photo.Z.MetadataCommitment); err != nil {
+			return err
+		}
+	}
+
 	// Display image if successful
 	if ok {
 		fmt.Println("********Viewer SUCCESSFUL viewed photo********")
 		photo.Z.Img.PrintImage()
+		if photo.Disclosure != nil {
+			for _, field := range photo.Disclosure.Fields {
+				if field.Disclosed {
+					fmt.Printf("%s: %s\n", field.Name, field.Value)
+				}
+			}
+		}
 		return nil
 	} else { // Do not display image if unsuccessful
 		fmt.Println("********Viewer FAILED to view photo********")
@@ -29,3 +51,24 @@ func (v Viewer) View(photo camera.Photograph) error {
 
 	return nil
 }
+
+// Verify many photographs under the same verifier keys, see photoproof.User.VerifyBatch().
+// Photographs with a Predicate are verified against it, and disclosed metadata against the signed commitment.
+func (v Viewer) VerifyBatch(verifier_keys photoproof.VerifierKeys, photos []camera.Photograph) []photoproof.Verification_Result {
+	items := make([]photoproof.Batch_Item, len(photos))
+	for i, photo := range photos {
+		items[i] = photoproof.Batch_Item{Z: photo.Z, Proof: photo.Proof, Predicate: photo.Predicate}
+	}
+
+	results := v.Viewer.VerifyBatch(verifier_keys, items)
+
+	for i, photo := range photos {
+		if results[i].OK && photo.Disclosure != nil {
+			if err := photo.Disclosure.Verify(photo.Z.MetadataCommitment); err != nil {
+				results[i] = photoproof.Verification_Result{Stage: "disclosure", Err: err}
+			}
+		}
+	}
+
+	return results
+}